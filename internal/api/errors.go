@@ -0,0 +1,56 @@
+// Package api holds definitions shared by the HTTP handlers and middleware.
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned in the APIError envelope
+const (
+	CodeInvalidRequest       = "INVALID_REQUEST"
+	CodeInvalidID            = "INVALID_ID"
+	CodeInvalidName          = "INVALID_NAME"
+	CodeInvalidExpiry        = "INVALID_EXPIRY"
+	CodeSecretTooLarge       = "SECRET_TOO_LARGE"
+	CodeNameTaken            = "NAME_TAKEN"
+	CodeCaptchaInvalid       = "CAPTCHA_INVALID"
+	CodeCaptchaUnavailable   = "CAPTCHA_UNAVAILABLE"
+	CodeNotFound             = "NOT_FOUND"
+	CodeExpired              = "EXPIRED"
+	CodeInvalidData          = "INVALID_DATA"
+	CodeDecryptionFailed     = "DECRYPTION_FAILED"
+	CodeStorageFull          = "STORAGE_FULL"
+	CodeMaintenance          = "MAINTENANCE"
+	CodeStorageUnavailable   = "STORAGE_UNAVAILABLE"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeRequestTooLarge      = "REQUEST_TOO_LARGE"
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodeTooManyRequests      = "TOO_MANY_REQUESTS"
+	CodeServerBusy           = "SERVER_BUSY"
+	CodeInternal             = "INTERNAL_ERROR"
+)
+
+// APIError is the error envelope returned by every API endpoint
+type APIError struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes a single invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// RespondError writes an APIError with the given status
+func RespondError(c *gin.Context, status int, code string, message string) {
+	JSON(c, status, APIError{Error: message, Code: code})
+}
+
+// AbortWithError writes an APIError and stops the middleware chain
+func AbortWithError(c *gin.Context, status int, code string, message string) {
+	c.Abort()
+	JSON(c, status, APIError{Error: message, Code: code})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/config"
+	"secrets-share/internal/encryption"
+	"secrets-share/internal/logger"
+	"secrets-share/internal/storage/file"
+	"secrets-share/internal/storage/redis"
+)
+
+// AdminAPIHandler handles operator-only HTTP requests
+type AdminAPIHandler struct {
+	fileStore   *file.FileStore
+	redisStore  *redis.RedisStore
+	encryptor   *encryption.Encryptor
+	maintenance *Maintenance
+	config      *config.Config
+}
+
+// NewAdminAPIHandler creates a new AdminAPIHandler. redisStore may be nil when
+// Redis is not in use. maintenance is the switch the secret handler checks.
+func NewAdminAPIHandler(fileStore *file.FileStore, redisStore *redis.RedisStore, encryptor *encryption.Encryptor, maintenance *Maintenance, config *config.Config) *AdminAPIHandler {
+	return &AdminAPIHandler{
+		fileStore:   fileStore,
+		redisStore:  redisStore,
+		encryptor:   encryptor,
+		maintenance: maintenance,
+		config:      config,
+	}
+}
+
+// APICleanupStatsResponse represents the cleanup statistics in responses
+type APICleanupStatsResponse struct {
+	LastRun        *time.Time `json:"lastRun,omitempty"`
+	SecretsCleaned int        `json:"secretsCleaned"`
+	BytesCleaned   int64      `json:"bytesCleaned"`
+	WouldClean     int        `json:"wouldClean"`
+	Errors         int        `json:"errors"`
+	TotalCleaned   int        `json:"totalCleaned"`
+	TotalBytes     int64      `json:"totalBytes"`
+	TotalRuns      int        `json:"totalRuns"`
+	TotalErrors    int        `json:"totalErrors"`
+}
+
+// APIHistogramBucket represents a cumulative histogram bucket in responses
+type APIHistogramBucket struct {
+	LE    float64 `json:"le"` // Upper bound in seconds
+	Count int64   `json:"count"`
+}
+
+// APIHistogramResponse represents a distribution of durations in seconds
+type APIHistogramResponse struct {
+	Buckets []APIHistogramBucket `json:"buckets"`
+	Sum     float64              `json:"sum"`
+	Count   int64                `json:"count"`
+}
+
+// APIStatsResponse represents aggregate service statistics. It never contains
+// information about individual viewers.
+type APIStatsResponse struct {
+	TotalViews               int64                   `json:"totalViews"`
+	DecryptionFailures       int64                   `json:"decryptionFailures"`
+	MaintenanceMode          bool                    `json:"maintenanceMode"`
+	Redis                    string                  `json:"redis,omitempty"` // "ok" or "down", omitted without Redis
+	Cleanup                  APICleanupStatsResponse `json:"cleanup"`
+	SecretAgeAtViewSeconds   APIHistogramResponse    `json:"secretAgeAtViewSeconds"`
+	SecretAgeAtExpirySeconds APIHistogramResponse    `json:"secretAgeAtExpirySeconds"`
+}
+
+// histogramResponse converts a histogram snapshot for responses
+func histogramResponse(snapshot file.HistogramSnapshot) APIHistogramResponse {
+	response := APIHistogramResponse{
+		Buckets: make([]APIHistogramBucket, len(snapshot.Buckets)),
+		Sum:     snapshot.Sum,
+		Count:   snapshot.Count,
+	}
+	for i, bucket := range snapshot.Buckets {
+		response.Buckets[i] = APIHistogramBucket{LE: bucket.UpperBound, Count: bucket.Count}
+	}
+	return response
+}
+
+// GetStats returns aggregate statistics about the secret store
+func (h *AdminAPIHandler) GetStats(c *gin.Context) {
+	cleanupStats := h.fileStore.GetCleanupStats()
+
+	response := APIStatsResponse{
+		TotalViews:         h.fileStore.TotalViews(),
+		DecryptionFailures: h.encryptor.DecryptFailures(),
+		MaintenanceMode:    h.maintenance.Enabled(),
+		Cleanup: APICleanupStatsResponse{
+			SecretsCleaned: cleanupStats.SecretsCleaned,
+			BytesCleaned:   cleanupStats.BytesCleaned,
+			WouldClean:     cleanupStats.WouldClean,
+			Errors:         cleanupStats.Errors,
+			TotalCleaned:   cleanupStats.TotalCleaned,
+			TotalBytes:     cleanupStats.TotalBytes,
+			TotalRuns:      cleanupStats.TotalRuns,
+			TotalErrors:    cleanupStats.TotalErrors,
+		},
+		SecretAgeAtViewSeconds:   histogramResponse(h.fileStore.ViewAges()),
+		SecretAgeAtExpirySeconds: histogramResponse(h.fileStore.ExpiryAges()),
+	}
+	if !cleanupStats.LastRun.IsZero() {
+		response.Cleanup.LastRun = &cleanupStats.LastRun
+	}
+	if h.redisStore != nil {
+		response.Redis = redisStatus(h.redisStore)
+	}
+
+	api.JSON(c, http.StatusOK, response)
+}
+
+// APIImportResponse represents the outcome of a secret import
+type APIImportResponse struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// ExportSecrets streams a gzipped tar backup of all current secrets
+func (h *AdminAPIHandler) ExportSecrets(c *gin.Context) {
+	filename := fmt.Sprintf("secrets-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only be logged
+	exported, err := h.fileStore.Export(c.Writer)
+	if err != nil {
+		logger.Error("Failed to export secrets", map[string]interface{}{
+			"error":    err.Error(),
+			"exported": exported,
+		})
+		return
+	}
+
+	logger.Info("Exported secrets", map[string]interface{}{
+		"exported": exported,
+	})
+}
+
+// ImportSecrets restores secrets from a backup produced by ExportSecrets,
+// skipping any that collide with existing secrets
+func (h *AdminAPIHandler) ImportSecrets(c *gin.Context) {
+	result, err := h.fileStore.Import(c.Request.Body, file.ImportLimits{
+		MaxTotal:      h.config.Secrets.MaxTotal,
+		MaxEntryBytes: h.config.Secrets.MaxImportEntryBytes,
+	})
+	if err != nil {
+		logger.Error("Failed to import secrets", map[string]interface{}{
+			"error":    err.Error(),
+			"imported": result.Imported,
+		})
+		switch {
+		case errors.Is(err, file.ErrInvalidArchive):
+			api.RespondError(c, http.StatusBadRequest, api.CodeInvalidData, "Failed to import secrets: invalid archive")
+		case errors.Is(err, file.ErrStoreAtCapacity):
+			api.RespondError(c, http.StatusServiceUnavailable, api.CodeStorageFull, fmt.Sprintf("Secret storage is full after importing %d secrets", result.Imported))
+		default:
+			respondStoreError(c, err)
+		}
+		return
+	}
+
+	logger.Info("Imported secrets", map[string]interface{}{
+		"imported": result.Imported,
+		"skipped":  result.Skipped,
+	})
+	api.JSON(c, http.StatusOK, APIImportResponse{Imported: result.Imported, Skipped: result.Skipped})
+}
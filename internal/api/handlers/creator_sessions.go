@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+const (
+	// CreatorTokenHeader carries the creator token when listing own secrets
+	CreatorTokenHeader = "X-Creator-Token"
+
+	// creatorTokenBytes is the amount of randomness in a generated creator token
+	creatorTokenBytes = 24
+
+	// defaultCreatorSessionTTL keeps creator sessions when max_expiry_days is unset
+	defaultCreatorSessionTTL = 7 * 24 * time.Hour
+)
+
+// APICreatorSecret describes a live secret without its content
+type APICreatorSecret struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxViews  *int       `json:"maxViews,omitempty"`
+	ViewCount int        `json:"viewCount"`
+}
+
+// APICreatorSecretsResponse lists the live secrets of a creator session
+type APICreatorSecretsResponse struct {
+	Secrets []APICreatorSecret `json:"secrets"`
+}
+
+// creatorSessionsEnabled reports whether secrets can be grouped by creator,
+// which needs Redis to hold the associations
+func (h *SecretAPIHandler) creatorSessionsEnabled() bool {
+	return h.config.Secrets.CreatorSessions && h.redisStore != nil
+}
+
+// creatorSession derives the Redis key for a creator token, so raw tokens are
+// never stored
+func creatorSession(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// creatorSessionTTL outlives the longest-lived secret a session can hold
+func (h *SecretAPIHandler) creatorSessionTTL() time.Duration {
+	if days := h.config.Secrets.MaxExpiryDays; days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return defaultCreatorSessionTTL
+}
+
+// recordCreator associates a new secret with the client's creator token,
+// issuing a token if none was sent. It returns the token to hand back, or an
+// empty string when creator sessions are unavailable. Failures only cost the
+// listing, so the secret is still created.
+func (h *SecretAPIHandler) recordCreator(c *gin.Context, token string, id string) string {
+	if !h.creatorSessionsEnabled() {
+		return ""
+	}
+
+	if token == "" {
+		buf := make([]byte, creatorTokenBytes)
+		if _, err := rand.Read(buf); err != nil {
+			logger.Error("Failed to generate creator token", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return ""
+		}
+		token = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	if err := h.redisStore.AddCreatorSecret(c.Request.Context(), creatorSession(token), id, h.creatorSessionTTL()); err != nil {
+		logger.Error("Failed to record creator secret", map[string]interface{}{
+			"error": err.Error(),
+			"id":    id,
+		})
+		return ""
+	}
+	return token
+}
+
+// ListCreatorSecrets lists the live secrets created with the creator token in
+// the X-Creator-Token header. Content is never included.
+func (h *SecretAPIHandler) ListCreatorSecrets(c *gin.Context) {
+	if !h.creatorSessionsEnabled() {
+		api.RespondError(c, http.StatusNotFound, api.CodeNotFound, "Not found")
+		return
+	}
+
+	token := c.GetHeader(CreatorTokenHeader)
+	if token == "" {
+		api.JSON(c, http.StatusBadRequest, api.APIError{
+			Error:  "Invalid request format",
+			Code:   api.CodeInvalidRequest,
+			Fields: []api.FieldError{{Field: CreatorTokenHeader, Message: "is required"}},
+		})
+		return
+	}
+
+	session := creatorSession(token)
+	ids, err := h.redisStore.CreatorSecrets(c.Request.Context(), session)
+	if err != nil {
+		logger.Error("Failed to list creator secrets", map[string]interface{}{
+			"error": err.Error(),
+		})
+		api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to list secrets")
+		return
+	}
+
+	secrets := make([]APICreatorSecret, 0, len(ids))
+	var gone []string
+	for _, id := range ids {
+		secret, err := h.fileStore.Get(id)
+		if err != nil {
+			api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to list secrets")
+			return
+		}
+		if secret == nil || secret.IsExpired(h.clock.Now()) || secret.ViewsExhausted() {
+			gone = append(gone, id)
+			continue
+		}
+		secrets = append(secrets, APICreatorSecret{
+			ID:        secret.ID,
+			Name:      secret.CustomName,
+			ExpiresAt: secret.ExpiresAt,
+			MaxViews:  secret.MaxViews,
+			ViewCount: secret.ViewCount,
+		})
+	}
+
+	// Forget secrets that were burned or expired since they were created
+	if err := h.redisStore.RemoveCreatorSecrets(c.Request.Context(), session, gone...); err != nil {
+		logger.Error("Failed to prune creator secrets", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].ID < secrets[j].ID })
+	api.JSON(c, http.StatusOK, APICreatorSecretsResponse{Secrets: secrets})
+}
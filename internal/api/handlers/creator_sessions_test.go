@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"secrets-share/internal/captcha"
+	"secrets-share/internal/storage/redis"
+)
+
+func TestCreatorSessions(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+	router.GET("/api/secrets/mine", handler.ListCreatorSecrets)
+
+	list := func(token string) (int, APICreatorSecretsResponse) {
+		req := httptest.NewRequest("GET", "/api/secrets/mine", nil)
+		if token != "" {
+			req.Header.Set(CreatorTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response APICreatorSecretsResponse
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+	create := func(creatorToken string) APISecretResponse {
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CreatorToken:     creatorToken,
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response APISecretResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("Disabled without Redis", func(t *testing.T) {
+		handler.config.Secrets.CreatorSessions = true
+		defer func() { handler.config.Secrets.CreatorSessions = false }()
+
+		assert.Empty(t, create("").CreatorToken)
+		code, _ := list("anything-at-all-123")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	port, _ := strconv.Atoi(mr.Port())
+	redisStore, err := redis.NewRedisStore(mr.Host(), port, "", "", 0)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer redisStore.Close()
+
+	handler.redisStore = redisStore
+	handler.config.Secrets.CreatorSessions = true
+
+	first := create("")
+	if !assert.NotEmpty(t, first.CreatorToken) {
+		return
+	}
+	second := create(first.CreatorToken)
+	assert.Equal(t, first.CreatorToken, second.CreatorToken)
+	other := create("")
+
+	t.Run("Lists secrets created with the token", func(t *testing.T) {
+		code, response := list(first.CreatorToken)
+		assert.Equal(t, http.StatusOK, code)
+
+		var ids []string
+		for _, secret := range response.Secrets {
+			ids = append(ids, secret.ID)
+			assert.NotNil(t, secret.ExpiresAt)
+		}
+		assert.ElementsMatch(t, []string{first.ID, second.ID}, ids)
+		assert.NotContains(t, ids, other.ID)
+	})
+
+	t.Run("Wrong token lists nothing", func(t *testing.T) {
+		code, response := list("not-a-real-creator-token")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, response.Secrets)
+	})
+
+	t.Run("Burned secrets drop out", func(t *testing.T) {
+		assert.NoError(t, handler.fileStore.Delete(second.ID))
+
+		_, response := list(first.CreatorToken)
+		assert.Len(t, response.Secrets, 1)
+		assert.Equal(t, first.ID, response.Secrets[0].ID)
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		code, _ := list("")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Raw tokens are not stored", func(t *testing.T) {
+		for _, key := range mr.Keys() {
+			assert.NotContains(t, key, first.CreatorToken)
+		}
+	})
+}
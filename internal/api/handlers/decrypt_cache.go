@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"secrets-share/internal/models"
+)
+
+// decryptCacheTTL bounds how long decrypted content stays in memory, also for
+// secrets deleted by cleanup rather than by a request
+const decryptCacheTTL = 30 * time.Second
+
+// decryptCache keeps the decrypted content of recently read multi-view
+// secrets, so repeated reads skip server-side decryption. It holds at most
+// size entries and evicts the least recently used one first. A nil cache is
+// disabled.
+type decryptCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // Front is the most recently used
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+}
+
+type decryptCacheEntry struct {
+	id        string
+	content   models.EncryptedContent
+	expiresAt time.Time
+}
+
+// newDecryptCache returns a cache of the given size, or nil when size is not
+// positive
+func newDecryptCache(size int) *decryptCache {
+	if size <= 0 {
+		return nil
+	}
+	return &decryptCache{
+		size:    size,
+		ttl:     decryptCacheTTL,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// cacheable reports whether a secret's content may be cached. Secrets that can
+// only be read once gain nothing and must not linger in memory.
+func cacheable(secret *models.Secret) bool {
+	return !secret.IsBurnAfterReading && (secret.MaxViews == nil || *secret.MaxViews > 1)
+}
+
+// get returns the cached content for a secret, if it is still fresh
+func (d *decryptCache) get(id string) (models.EncryptedContent, bool) {
+	if d == nil {
+		return models.EncryptedContent{}, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	element, ok := d.entries[id]
+	if !ok {
+		d.misses++
+		return models.EncryptedContent{}, false
+	}
+	entry := element.Value.(*decryptCacheEntry)
+	if !d.now().Before(entry.expiresAt) {
+		d.removeElement(element)
+		d.misses++
+		return models.EncryptedContent{}, false
+	}
+	d.order.MoveToFront(element)
+	d.hits++
+	return entry.content, true
+}
+
+// put caches a secret's decrypted content, evicting the least recently used
+// entry when the cache is full
+func (d *decryptCache) put(id string, content models.EncryptedContent) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expiresAt := d.now().Add(d.ttl)
+	if element, ok := d.entries[id]; ok {
+		entry := element.Value.(*decryptCacheEntry)
+		entry.content, entry.expiresAt = content, expiresAt
+		d.order.MoveToFront(element)
+		return
+	}
+	d.entries[id] = d.order.PushFront(&decryptCacheEntry{id: id, content: content, expiresAt: expiresAt})
+	for d.order.Len() > d.size {
+		d.removeElement(d.order.Back())
+	}
+}
+
+// remove drops a secret's content, e.g. once it is burned or deleted
+func (d *decryptCache) remove(id string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if element, ok := d.entries[id]; ok {
+		d.removeElement(element)
+	}
+}
+
+// removeElement unlinks an entry; callers must hold d.mu
+func (d *decryptCache) removeElement(element *list.Element) {
+	d.order.Remove(element)
+	delete(d.entries, element.Value.(*decryptCacheEntry).id)
+}
+
+// stats returns the number of cache hits and misses so far
+func (d *decryptCache) stats() (hits, misses int64) {
+	if d == nil {
+		return 0, 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hits, d.misses
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/storage/file"
+	"secrets-share/internal/storage/redis"
+)
+
+// HealthAPIHandler reports whether the service can handle traffic
+type HealthAPIHandler struct {
+	fileStore  *file.FileStore
+	redisStore *redis.RedisStore
+}
+
+// NewHealthAPIHandler creates a new HealthAPIHandler. redisStore may be nil
+// when Redis is not in use.
+func NewHealthAPIHandler(fileStore *file.FileStore, redisStore *redis.RedisStore) *HealthAPIHandler {
+	return &HealthAPIHandler{
+		fileStore:  fileStore,
+		redisStore: redisStore,
+	}
+}
+
+// APIReadinessResponse represents the readiness status and its individual checks
+type APIReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Ready returns 503 when a required readiness check fails and 200 otherwise. The
+// service isn't ready until the first cleanup has scanned the storage
+// directory, which can take a while at startup when it holds many secrets.
+// Redis is optional, so losing it only reports the service as degraded.
+func (h *HealthAPIHandler) Ready(c *gin.Context) {
+	response := APIReadinessResponse{
+		Status: "ok",
+		Checks: map[string]string{"storage": "ok", "cleanup": "ok"},
+	}
+
+	if !h.fileStore.Initialized() {
+		response.Checks["cleanup"] = "pending"
+		response.Status = "unavailable"
+	}
+	if !h.fileStore.Writable() {
+		response.Checks["storage"] = "unwritable"
+		response.Status = "unavailable"
+	}
+	if h.redisStore != nil {
+		response.Checks["redis"] = redisStatus(h.redisStore)
+		if response.Checks["redis"] != "ok" && response.Status == "ok" {
+			response.Status = "degraded"
+		}
+	}
+
+	status := http.StatusOK
+	if response.Status == "unavailable" {
+		status = http.StatusServiceUnavailable
+	}
+	api.JSON(c, status, response)
+}
+
+// redisStatus describes the result of the last Redis health check
+func redisStatus(store *redis.RedisStore) string {
+	if store.Healthy() {
+		return "ok"
+	}
+	return "down"
+}
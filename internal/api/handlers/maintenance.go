@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+// Maintenance is the maintenance mode switch. While it is on, new secrets are
+// refused and reads keep working. It starts from server.maintenance_mode and
+// can be flipped through the admin API, so a migration needs no restart.
+type Maintenance struct {
+	enabled atomic.Bool
+}
+
+// NewMaintenance returns a switch in the given state
+func NewMaintenance(enabled bool) *Maintenance {
+	m := &Maintenance{}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether maintenance mode is on
+func (m *Maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set turns maintenance mode on or off
+func (m *Maintenance) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// APIMaintenanceRequest represents a request to toggle maintenance mode
+type APIMaintenanceRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// APIMaintenanceResponse represents the maintenance mode after a change
+type APIMaintenanceResponse struct {
+	MaintenanceMode bool `json:"maintenanceMode"`
+}
+
+// SetMaintenance turns maintenance mode on or off at runtime
+func (h *AdminAPIHandler) SetMaintenance(c *gin.Context) {
+	var req APIMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	h.maintenance.Set(*req.Enabled)
+	logger.Warn("Maintenance mode changed", map[string]interface{}{
+		"maintenance_mode": *req.Enabled,
+	})
+	api.JSON(c, http.StatusOK, APIMaintenanceResponse{MaintenanceMode: *req.Enabled})
+}
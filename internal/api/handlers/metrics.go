@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"secrets-share/internal/storage/file"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsContentType is the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// GetMetrics exposes the admin statistics in the Prometheus text format, so
+// they can be scraped with the admin token as a bearer token
+func (h *AdminAPIHandler) GetMetrics(c *gin.Context) {
+	cleanupStats := h.fileStore.GetCleanupStats()
+
+	var b strings.Builder
+	writeCounter(&b, "secrets_cleaned_total", "Expired secrets removed by cleanup.", float64(cleanupStats.TotalCleaned))
+	writeCounter(&b, "secrets_cleaned_bytes_total", "Size on disk of the expired secrets removed by cleanup.", float64(cleanupStats.TotalBytes))
+	writeCounter(&b, "cleanup_runs_total", "Cleanup runs.", float64(cleanupStats.TotalRuns))
+	writeCounter(&b, "cleanup_errors_total", "Errors during cleanup runs.", float64(cleanupStats.TotalErrors))
+	writeHistogram(&b, "secret_age_at_view_seconds", "Age of secrets when they were viewed.", h.fileStore.ViewAges())
+	writeHistogram(&b, "secret_age_at_expiry_seconds", "Lifetime of secrets deleted because they expired.", h.fileStore.ExpiryAges())
+
+	c.Data(http.StatusOK, metricsContentType, []byte(b.String()))
+}
+
+// writeCounter writes a single counter value
+func writeCounter(b *strings.Builder, name, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n%s %s\n", name, help, name, name, formatMetricValue(value))
+}
+
+// writeHistogram writes a histogram snapshot with its cumulative buckets, the
+// +Inf bucket, sum and count
+func writeHistogram(b *strings.Builder, name, help string, snapshot file.HistogramSnapshot) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, bucket := range snapshot.Buckets {
+		fmt.Fprintf(b, "%s_bucket{le=%q} %d\n", name, formatMetricValue(bucket.UpperBound), bucket.Count)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, snapshot.Count)
+	fmt.Fprintf(b, "%s_sum %s\n", name, formatMetricValue(snapshot.Sum))
+	fmt.Fprintf(b, "%s_count %d\n", name, snapshot.Count)
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+// nameLookupKind identifies by-name lookups in the failed lookup counters
+const nameLookupKind = "name"
+
+// nameLookupLimitEnabled reports whether failed name lookups are limited,
+// which needs Redis to hold the counters
+func (h *SecretAPIHandler) nameLookupLimitEnabled() bool {
+	limits := h.config.RateLimit.NameLookupFailures
+	return h.config.RateLimit.Enabled && h.redisStore != nil &&
+		(limits.RequestsPerHour > 0 || limits.RequestsPerMinute > 0)
+}
+
+// nameLookupsBlocked refuses clients that have guessed too many unknown names,
+// responding with 429. Redis errors let the lookup through, like the request
+// rate limit.
+func (h *SecretAPIHandler) nameLookupsBlocked(c *gin.Context) bool {
+	if !h.nameLookupLimitEnabled() {
+		return false
+	}
+
+	limits := h.config.RateLimit.NameLookupFailures
+	blocked, err := h.redisStore.FailedLookupsExceeded(c.Request.Context(), c.ClientIP(), nameLookupKind, limits.RequestsPerHour, limits.RequestsPerMinute)
+	if err != nil {
+		logger.Error("Failed name lookup check failed", err)
+		return false
+	}
+	if !blocked {
+		return false
+	}
+
+	logger.RateLimit("Failed name lookup limit exceeded", map[string]interface{}{
+		"route": c.FullPath(),
+		"ip":    c.ClientIP(),
+	})
+	api.RespondError(c, http.StatusTooManyRequests, api.CodeTooManyRequests, "Too many failed lookups. Please try again later.")
+	return true
+}
+
+// recordFailedNameLookup counts a lookup of an unknown name against the client
+func (h *SecretAPIHandler) recordFailedNameLookup(c *gin.Context) {
+	if !h.nameLookupLimitEnabled() {
+		return
+	}
+	if err := h.redisStore.RecordFailedLookup(c.Request.Context(), c.ClientIP(), nameLookupKind); err != nil {
+		logger.Error("Failed to record failed name lookup", err)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIDocument describes the public secrets API. It is kept in sync with the
+// request and response structs by the tests in openapi_test.go.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// openAPIETag identifies the embedded document. It is weak, since response
+// compression changes the bytes sent but not their meaning.
+var openAPIETag = documentETag(openAPIDocument)
+
+// staticCacheControl lets browsers and proxies reuse documents that only
+// change with a new release, revalidating them by ETag afterwards
+const staticCacheControl = "public, max-age=3600"
+
+// GetOpenAPI serves the OpenAPI 3 document for the API
+func GetOpenAPI(c *gin.Context) {
+	serveStatic(c, "application/json; charset=utf-8", openAPIDocument, openAPIETag)
+}
+
+// documentETag returns a weak ETag derived from the document's contents
+func documentETag(document []byte) string {
+	sum := sha256.Sum256(document)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// serveStatic writes a fixed document with caching headers, answering
+// conditional requests that already hold it with 304 Not Modified
+func serveStatic(c *gin.Context, contentType string, document []byte, etag string) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", staticCacheControl)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, document)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/models"
+)
+
+type openAPISchema struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+type openAPIDoc struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// jsonFieldNames returns the JSON property names of a struct type
+func jsonFieldNames(v interface{}) []string {
+	var names []string
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/openapi.json", GetOpenAPI)
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var doc openAPIDoc
+	if !assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc)) {
+		return
+	}
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	t.Run("Lists the secret routes", func(t *testing.T) {
+		assert.Contains(t, doc.Paths["/api/secrets"], "post")
+		assert.Contains(t, doc.Paths["/api/secrets/{id}"], "post")
+		assert.Contains(t, doc.Paths["/api/secrets/name/{name}"], "post")
+	})
+
+	t.Run("Schemas match the API structs", func(t *testing.T) {
+		structs := map[string]interface{}{
+			"EncryptedContent":       models.EncryptedContent{},
+			"CreateSecretRequest":    APICreateSecretRequest{},
+			"ViewSecretRequest":      APIViewSecretRequest{},
+			"SecretResponse":         APISecretResponse{},
+			"SecretContentResponse":  APISecretContentResponse{},
+			"SecretStatusResponse":   APISecretStatusResponse{},
+			"CreatorSecret":          APICreatorSecret{},
+			"CreatorSecretsResponse": APICreatorSecretsResponse{},
+			"Error":                  api.APIError{},
+			"FieldError":             api.FieldError{},
+		}
+
+		for name, v := range structs {
+			schema, ok := doc.Components.Schemas[name]
+			if !assert.True(t, ok, "missing schema %s", name) {
+				continue
+			}
+			var properties []string
+			for property := range schema.Properties {
+				properties = append(properties, property)
+			}
+			sort.Strings(properties)
+			assert.Equal(t, jsonFieldNames(v), properties, "schema %s", name)
+		}
+	})
+}
+
+func TestOpenAPIConditionalGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/openapi.json", GetOpenAPI)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("")
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Contains(t, first.Header().Get("Cache-Control"), "max-age=")
+
+	// Repeated requests with the ETag are answered without the document
+	for i := 0; i < 2; i++ {
+		w := get(etag)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.Bytes())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		expected    int
+	}{
+		{"Strong form of the ETag", strings.TrimPrefix(etag, "W/"), http.StatusNotModified},
+		{"ETag in a list", `"other", ` + etag, http.StatusNotModified},
+		{"Wildcard", "*", http.StatusNotModified},
+		{"Stale ETag", `W/"outdated"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.ifNoneMatch)
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.expected == http.StatusOK {
+				assert.Equal(t, first.Body.Bytes(), w.Body.Bytes())
+			}
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"secrets-share/internal/api"
+	"secrets-share/internal/api/middleware"
 	"secrets-share/internal/captcha"
 	"secrets-share/internal/config"
 	"secrets-share/internal/encryption"
@@ -18,9 +22,24 @@ import (
 	"secrets-share/internal/storage/redis"
 )
 
-var (
-	// UUID regex pattern
-	uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+const (
+	// Fallbacks for generated custom names when the config leaves them unset
+	defaultAutonameLength      = 8
+	defaultAutonameMaxAttempts = 5
+
+	// defaultExpirySkew is how far a requested expiry may drift from an allowed
+	// duration when the config leaves the tolerance unset
+	defaultExpirySkew = time.Second
+
+	// maxExpiryJitter bounds the random expiry offset to less than half a
+	// minute, keeping the user-facing expiry accurate
+	maxExpiryJitter = 29 * time.Second
+
+	// maxExpiresInDays keeps day durations far from overflowing time.Duration
+	maxExpiresInDays = 100 * 365
+
+	// maxIDAttempts bounds how often a colliding secret ID is regenerated
+	maxIDAttempts = 3
 )
 
 // SecretAPIHandler handles HTTP requests for secrets
@@ -30,6 +49,12 @@ type SecretAPIHandler struct {
 	encryptor     *encryption.Encryptor
 	captchaClient captcha.TurnstileVerifier
 	config        *config.Config
+	generateName  func(length int) (string, error)
+	viewTokens    *viewTokenCache
+	decryptCache  *decryptCache
+	clock         models.Clock
+	namePattern   *models.NamePattern
+	maintenance   *Maintenance
 }
 
 // NewSecretAPIHandler creates a new SecretAPIHandler
@@ -46,80 +71,195 @@ func NewSecretAPIHandler(
 		encryptor:     encryptor,
 		captchaClient: captchaClient,
 		config:        config,
+		generateName:  models.GenerateCustomName,
+		viewTokens:    newViewTokenCache(time.Duration(config.Secrets.ViewTokenTTLSec) * time.Second),
+		decryptCache:  newDecryptCache(config.Secrets.DecryptCacheSize),
+		clock:         models.SystemClock{},
+		namePattern:   namePattern(config),
+		maintenance:   NewMaintenance(config.Server.MaintenanceMode),
+	}
+}
+
+// Maintenance returns the handler's maintenance mode switch
+func (h *SecretAPIHandler) Maintenance() *Maintenance {
+	return h.maintenance
+}
+
+// namePattern compiles the configured custom name pattern, falling back to
+// the alphanumeric default if it is invalid (startup rejects invalid patterns)
+func namePattern(config *config.Config) *models.NamePattern {
+	pattern, err := models.ParseNamePattern(config.Secrets.CustomNamePattern)
+	if err != nil {
+		return models.DefaultNamePattern()
 	}
+	return pattern
 }
 
 // APISecretResponse represents a secret in responses
 type APISecretResponse struct {
-	ID string `json:"id"`
+	ID                 string     `json:"id"`
+	Name               string     `json:"name,omitempty"`
+	ExpiresAt          *time.Time `json:"expiresAt,omitempty"` // Expiry as stored, after server-side normalization
+	IsBurnAfterReading bool       `json:"isBurnAfterReading"`
+	CreatorToken       string     `json:"creatorToken,omitempty"`
 }
 
 // APISecretContentResponse represents a secret's content in responses
 type APISecretContentResponse struct {
 	EncryptedContent   models.EncryptedContent `json:"encryptedContent"`
+	CreatedAt          *time.Time              `json:"createdAt,omitempty"` // Only with secrets.expose_created_at
 	ExpiresAt          *time.Time              `json:"expiresAt,omitempty"`
 	IsBurnAfterReading bool                    `json:"isBurnAfterReading"`
+	MaxViews           *int                    `json:"maxViews,omitempty"`
+	ViewCount          int                     `json:"viewCount"`
+	ContentKind        string                  `json:"contentKind,omitempty"`
+}
+
+// APISecretStatusResponse represents a secret's availability without its content
+type APISecretStatusResponse struct {
+	Viewable           bool       `json:"viewable"`
+	Expired            bool       `json:"expired"`
+	Exists             bool       `json:"exists"`
+	CreatedAt          *time.Time `json:"createdAt,omitempty"` // Only with secrets.expose_created_at
+	ContentLength      int        `json:"contentLength,omitempty"`
+	PassphraseRequired bool       `json:"passphraseRequired,omitempty"`
 }
 
 // APICreateSecretRequest represents a request to create a secret
 type APICreateSecretRequest struct {
-	EncryptedContent models.EncryptedContent `json:"encryptedContent" binding:"required"`
-	CustomName       string                  `json:"customName,omitempty"`
-	ExpiresAt        *time.Time              `json:"expiresAt,omitempty"`
-	MaxViews         *int                    `json:"maxViews,omitempty"`
-	CaptchaToken     string                  `json:"captchaToken" binding:"required"`
+	EncryptedContent  models.EncryptedContent `json:"encryptedContent" binding:"required"`
+	CustomName        string                  `json:"customName,omitempty"`
+	ExpiresAt         *time.Time              `json:"expiresAt,omitempty"`
+	ExpiresIn         string                  `json:"expiresIn,omitempty" binding:"omitempty,max=16"` // Duration such as "10m", "1h" or "7d", preferred over ExpiresAt
+	MaxViews          *int                    `json:"maxViews,omitempty"`
+	GenerateName      bool                    `json:"generateName,omitempty"`
+	ContentLength     int                     `json:"contentLength,omitempty"`
+	ContentKind       string                  `json:"contentKind,omitempty" binding:"omitempty,oneof=text markdown binary"`
+	CreatorToken      string                  `json:"creatorToken,omitempty" binding:"omitempty,min=16,max=128"`
+	ViewPassphrase    string                  `json:"viewPassphrase,omitempty" binding:"omitempty,max=128"` // Checked by the server before a view, separate from the E2E key
+	ServerSideEncrypt *bool                   `json:"serverSideEncrypt,omitempty"`                          // True opts this secret in when security.server_side_encryption is off
+	CaptchaToken      string                  `json:"captchaToken,omitempty"`
 }
 
 // APIViewSecretRequest represents a request to view a secret
 type APIViewSecretRequest struct {
-	CaptchaToken string `json:"captchaToken" binding:"required"`
+	CaptchaToken   string `json:"captchaToken,omitempty"`
+	ViewToken      string `json:"viewToken,omitempty" binding:"omitempty,max=128"` // Client nonce identifying one read attempt
+	ViewPassphrase string `json:"viewPassphrase,omitempty" binding:"omitempty,max=128"`
 }
 
+// ViewPassphraseHeader carries the view passphrase on GET requests, which have
+// no body
+const ViewPassphraseHeader = "X-View-Passphrase"
+
 // CreateSecret handles the creation of a new secret
 func (h *SecretAPIHandler) CreateSecret(c *gin.Context) {
 	var req APICreateSecretRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		respondBindingError(c, err)
+		return
+	}
+
+	// Reads keep working during maintenance, only new secrets are refused
+	if h.maintenance.Enabled() {
+		api.RespondError(c, http.StatusServiceUnavailable, api.CodeMaintenance, "The service is under maintenance and not accepting new secrets. Please try again later.")
 		return
 	}
 
 	// Check encrypted content size
 	encryptedSize := len(req.EncryptedContent.Encrypted) + len(req.EncryptedContent.Salt) + len(req.EncryptedContent.IV)
 	if encryptedSize > h.config.Secrets.MaxSizeBytes {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Secret size exceeds maximum allowed size of %d bytes", h.config.Secrets.MaxSizeBytes)})
+		api.RespondError(c, http.StatusBadRequest, api.CodeSecretTooLarge, fmt.Sprintf("Secret size exceeds maximum allowed size of %d bytes", h.config.Secrets.MaxSizeBytes))
+		return
+	}
+
+	// Reject encrypted content that can't have come from a well-behaved client
+	if fields := encryptedContentErrors(req.EncryptedContent, &h.config.Secrets); len(fields) > 0 {
+		api.JSON(c, http.StatusBadRequest, api.APIError{
+			Error:  "Invalid encrypted content",
+			Code:   api.CodeInvalidRequest,
+			Fields: fields,
+		})
+		return
+	}
+
+	// A view limit must burn the secret eventually
+	if fieldErr := maxViewsError(req.MaxViews, h.config.Secrets.MaxViewsLimit); fieldErr != nil {
+		api.JSON(c, http.StatusBadRequest, api.APIError{
+			Error:  fieldErr.Field + " " + fieldErr.Message,
+			Code:   api.CodeInvalidRequest,
+			Fields: []api.FieldError{*fieldErr},
+		})
 		return
 	}
 
 	// Validate custom name if provided
-	if err := models.ValidateCustomName(req.CustomName); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.namePattern.Validate(req.CustomName); err != nil {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidName, err.Error())
+		return
+	}
+	if req.CustomName != "" && h.validSecretID(req.CustomName) {
+		// A name shaped like a secret ID would be ambiguous in links and lookups
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidName, "Custom name cannot have the format of a secret ID")
 		return
 	}
 
-	// Verify captcha token
-	if h.config.Security.EnableCaptcha {
-		result, err := h.captchaClient.Verify(req.CaptchaToken, c.ClientIP())
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify captcha"})
+	// Server-generated names are opt-in and exclusive with a custom name
+	if req.GenerateName {
+		if !h.config.Secrets.Autoname.Enabled {
+			api.RespondError(c, http.StatusBadRequest, api.CodeInvalidName, "Name generation is disabled")
 			return
 		}
-		if !result.Success {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid captcha"})
+		if req.CustomName != "" {
+			api.RespondError(c, http.StatusBadRequest, api.CodeInvalidName, "customName and generateName cannot be used together")
 			return
 		}
 	}
 
+	// Refuse new secrets while the storage directory is known to be unwritable
+	if !h.fileStore.Writable() {
+		api.RespondError(c, http.StatusServiceUnavailable, api.CodeStorageUnavailable, "Secret storage is temporarily unavailable. Please try again later.")
+		return
+	}
+
+	// Refuse new secrets while the store is at capacity
+	if h.config.Secrets.MaxTotal > 0 && h.fileStore.Count() >= h.config.Secrets.MaxTotal {
+		api.RespondError(c, http.StatusServiceUnavailable, api.CodeStorageFull, "Secret storage is full. Please try again later.")
+		return
+	}
+
+	// Verify captcha token
+	if !h.verifyCaptcha(c, req.CaptchaToken, h.config.Security.Captcha.Create, h.config.Security.Captcha.CreateAction) {
+		return
+	}
+
 	// Create secret input
 	input := &models.SecretInput{
 		EncryptedContent:   req.EncryptedContent,
 		CustomName:         req.CustomName,
 		ExpiresAt:          req.ExpiresAt,
 		IsBurnAfterReading: req.MaxViews != nil && *req.MaxViews == 1,
+		ContentLength:      h.contentLengthHint(req.ContentLength),
+		ContentKind:        req.ContentKind,
 		CaptchaToken:       req.CaptchaToken,
 	}
 
+	// Multi-view secrets are burned once their view limit is reached
+	if req.MaxViews != nil && *req.MaxViews > 1 {
+		input.MaxViews = req.MaxViews
+	}
+
 	// Create secret model
-	secret := models.NewSecret(input)
+	now := h.clock.Now()
+	secret := models.NewSecret(input, now)
+	if req.ViewPassphrase != "" {
+		hash, err := models.HashPassphrase(req.ViewPassphrase)
+		if err != nil {
+			api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to store secret")
+			return
+		}
+		secret.PassphraseHash = hash
+	}
 
 	// Handle expiry time based on whether it's a burn-after-reading secret
 	if input.IsBurnAfterReading {
@@ -127,7 +267,6 @@ func (h *SecretAPIHandler) CreateSecret(c *gin.Context) {
 		secret.ExpiresAt = nil
 	} else {
 		// Calculate allowed expiry times
-		now := time.Now()
 		allowedExpiryTimes := map[time.Duration]bool{
 			10 * time.Minute:   true,
 			30 * time.Minute:   true,
@@ -136,19 +275,47 @@ func (h *SecretAPIHandler) CreateSecret(c *gin.Context) {
 			7 * 24 * time.Hour: true,
 		}
 
-		if secret.ExpiresAt == nil {
+		if req.ExpiresIn != "" {
+			// A relative expiry doesn't depend on the client's clock
+			duration, err := parseExpiresIn(req.ExpiresIn)
+			if err != nil {
+				api.RespondError(c, http.StatusBadRequest, api.CodeInvalidExpiry, err.Error())
+				return
+			}
+			if maxDays := h.config.Secrets.MaxExpiryDays; maxDays > 0 && duration > time.Duration(maxDays)*24*time.Hour {
+				api.RespondError(c, http.StatusBadRequest, api.CodeInvalidExpiry, fmt.Sprintf("Expiry time exceeds the maximum of %d days", maxDays))
+				return
+			}
+			if !allowedExpiryTimes[duration] {
+				api.RespondError(c, http.StatusBadRequest, api.CodeInvalidExpiry, "Invalid expiry time. Allowed values are: 10 minutes, 30 minutes, 1 hour, 1 day, or 7 days")
+				return
+			}
+			exactExpiry := now.Add(duration)
+			secret.ExpiresAt = &exactExpiry
+		} else if secret.ExpiresAt == nil {
 			// Set default expiry (10 minutes)
 			defaultExpiry := now.Add(10 * time.Minute)
 			secret.ExpiresAt = &defaultExpiry
 		} else {
 			// Calculate the duration between now and the requested expiry time
 			duration := secret.ExpiresAt.Sub(now)
+			skew := h.expirySkew()
+
+			// Reject clearly wrong clocks instead of normalizing them silently
+			if duration < -skew {
+				api.RespondError(c, http.StatusBadRequest, api.CodeInvalidExpiry, "Expiry time is in the past")
+				return
+			}
+			if maxDays := h.config.Secrets.MaxExpiryDays; maxDays > 0 && duration > time.Duration(maxDays)*24*time.Hour+skew {
+				api.RespondError(c, http.StatusBadRequest, api.CodeInvalidExpiry, fmt.Sprintf("Expiry time exceeds the maximum of %d days", maxDays))
+				return
+			}
 
 			// Check if the duration matches any of the allowed options
 			isAllowedDuration := false
 			for allowedDuration := range allowedExpiryTimes {
-				// Allow for 1-second precision to handle slight timing differences
-				if duration >= allowedDuration-time.Second && duration <= allowedDuration+time.Second {
+				// Allow for slight timing differences between client and server
+				if duration >= allowedDuration-skew && duration <= allowedDuration+skew {
 					isAllowedDuration = true
 					// Normalize the expiry time to exact duration
 					exactExpiry := now.Add(allowedDuration)
@@ -158,225 +325,634 @@ func (h *SecretAPIHandler) CreateSecret(c *gin.Context) {
 			}
 
 			if !isAllowedDuration {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry time. Allowed values are: 10 minutes, 30 minutes, 1 hour, 1 day, or 7 days"})
+				api.RespondError(c, http.StatusBadRequest, api.CodeInvalidExpiry, "Invalid expiry time. Allowed values are: 10 minutes, 30 minutes, 1 hour, 1 day, or 7 days")
 				return
 			}
 		}
+
+		// Spread out secrets created together so they don't all expire in
+		// the same cleanup run
+		jittered := h.jitterExpiry(*secret.ExpiresAt)
+		secret.ExpiresAt = &jittered
+	}
+
+	// The hard maximum age overrides whatever expiry was asked for, and gives
+	// burn-after-reading secrets one so they can't wait unread forever
+	if maxAge := h.config.Secrets.HardMaxAge; maxAge > 0 && (secret.ExpiresAt == nil || secret.ExpiresAt.Sub(now) > maxAge) {
+		capped := now.Add(maxAge)
+		secret.ExpiresAt = &capped
 	}
 
-	// Combine all client-side encrypted data into a single string
-	combinedData := fmt.Sprintf("%s.%s.%s",
-		input.EncryptedContent.Encrypted,
-		input.EncryptedContent.Salt,
-		input.EncryptedContent.IV,
-	)
+	// Combine all client-side encrypted data into a single record
+	combinedData, err := input.EncryptedContent.MarshalBinary()
+	if err != nil {
+		api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to encrypt data")
+		return
+	}
+
+	// Server-side encryption of the combined data. Creators can opt in but
+	// never out, so anonymous clients can't weaken the operator's setting. The
+	// choice is recorded on the secret so it can be read back whatever the
+	// global setting is later.
+	serverEncrypt := h.config.Security.ServerSideEncryption
+	if req.ServerSideEncrypt != nil && *req.ServerSideEncrypt {
+		serverEncrypt = true
+	}
+	secret.ServerEncrypted = &serverEncrypt
+	if serverEncrypt {
+		encrypt := h.encryptor.Encrypt
+		if h.config.Secrets.CompressAtRest {
+			encrypt = h.encryptor.EncryptCompressed
+		}
 
-	// Server-side encryption of the combined data
-	if h.config.Security.ServerSideEncryption {
-		encryptedData, err := h.encryptor.Encrypt([]byte(combinedData), "")
+		encryptedData, err := encrypt(combinedData, "")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt data"})
+			api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to encrypt data")
 			return
 		}
-		secret.EncryptedData = []byte(encryption.EncodeToString(encryptedData))
+		secret.EncryptedData = []byte(h.ciphertextEncoding().EncodeToString(encryptedData))
 	} else {
-		secret.EncryptedData = []byte(combinedData)
+		secret.EncryptedData = combinedData
 	}
 
 	// Store the secret
-	if err := h.fileStore.Store(secret); err != nil {
+	var response APISecretResponse
+	if req.GenerateName {
+		if !h.storeWithGeneratedName(c, secret) {
+			return
+		}
+		response.Name = secret.CustomName
+	} else if err := h.createSecret(secret); err != nil {
 		if strings.Contains(err.Error(), "already taken") {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			api.RespondError(c, http.StatusConflict, api.CodeNameTaken, err.Error())
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store secret"})
+		respondStoreError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, APISecretResponse{ID: secret.ID.String()})
+	response.ID = secret.ID
+	response.ExpiresAt = secret.ExpiresAt
+	response.IsBurnAfterReading = secret.IsBurnAfterReading
+	response.CreatorToken = h.recordCreator(c, req.CreatorToken, secret.ID)
+
+	logger.Audit("create", secret.ID, c.ClientIP())
+	api.JSON(c, http.StatusOK, response)
+}
+
+// createSecret stores a new secret under a freshly generated ID, picking another
+// ID in the unlikely event of a collision
+func (h *SecretAPIHandler) createSecret(secret *models.Secret) error {
+	scheme := h.idScheme()
+	for i := 0; i < maxIDAttempts; i++ {
+		id, err := scheme.NewID(h.config.Secrets.IDBytes)
+		if err != nil {
+			return err
+		}
+
+		secret.ID = id
+		if err := h.fileStore.Create(secret); !errors.Is(err, file.ErrIDExists) {
+			return err
+		}
+	}
+	return fmt.Errorf("failed to allocate a unique secret ID")
+}
+
+// respondStoreError reports a failure to store a secret, distinguishing a full
+// or unwritable storage directory from other errors
+func respondStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, file.ErrDiskFull):
+		logStorageUnwritable(err)
+		api.RespondError(c, http.StatusInsufficientStorage, api.CodeStorageUnavailable, "Secret storage is temporarily unavailable. Please try again later.")
+	case errors.Is(err, file.ErrStorageUnwritable):
+		logStorageUnwritable(err)
+		api.RespondError(c, http.StatusServiceUnavailable, api.CodeStorageUnavailable, "Secret storage is temporarily unavailable. Please try again later.")
+	default:
+		api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to store secret")
+	}
+}
+
+// logStorageUnwritable logs storage write failures with their own error type,
+// so they can be alerted on separately from ordinary errors
+func logStorageUnwritable(err error) {
+	logger.Error("Secret storage is not writable", map[string]interface{}{
+		"error_type": "storage_unwritable",
+		"error":      err.Error(),
+	})
+}
+
+// idScheme returns the configured secret ID scheme
+func (h *SecretAPIHandler) idScheme() models.IDScheme {
+	scheme, err := models.ParseIDScheme(h.config.Secrets.IDScheme)
+	if err != nil {
+		return models.IDSchemeUUID
+	}
+	return scheme
+}
+
+// validSecretID reports whether id is well-formed for the configured ID scheme
+func (h *SecretAPIHandler) validSecretID(id string) bool {
+	return h.idScheme().ValidID(id, h.config.Secrets.IDBytes)
+}
+
+// storeWithGeneratedName stores a secret under a random custom name, picking a
+// new name whenever the previous one is already taken. It writes an error
+// response and returns false if no free name was found within the retry budget.
+func (h *SecretAPIHandler) storeWithGeneratedName(c *gin.Context, secret *models.Secret) bool {
+	length := h.config.Secrets.Autoname.Length
+	if length <= 0 {
+		length = defaultAutonameLength
+	}
+	attempts := h.config.Secrets.Autoname.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultAutonameMaxAttempts
+	}
+
+	for i := 0; i < attempts; i++ {
+		name, err := h.generateName(length)
+		if err != nil {
+			api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to generate name")
+			return false
+		}
+		if h.validSecretID(name) || !h.namePattern.MatchString(name) {
+			continue
+		}
+
+		secret.CustomName = name
+		err = h.createSecret(secret)
+		if err == nil {
+			return true
+		}
+		if !strings.Contains(err.Error(), "already taken") {
+			respondStoreError(c, err)
+			return false
+		}
+
+		logger.Debug("Generated name collision, retrying", map[string]interface{}{
+			"attempt": i + 1,
+		})
+	}
+
+	api.RespondError(c, http.StatusConflict, api.CodeNameTaken, "Failed to generate a unique name. Please try again.")
+	return false
+}
+
+// verifyCaptcha checks the request's captcha token when captcha is enabled for
+// the route, requiring the token to carry action unless it is empty. Requests
+// carrying the configured API token skip the check. It writes an error
+// response and returns false if the request must be rejected.
+func (h *SecretAPIHandler) verifyCaptcha(c *gin.Context, token string, routeEnabled bool, action string) bool {
+	if !h.config.Security.EnableCaptcha || !routeEnabled {
+		return true
+	}
+	if middleware.TokenMatches(middleware.BearerToken(c), h.config.Security.APIToken) {
+		return true
+	}
+	if c.GetBool(middleware.CaptchaVerifiedKey) {
+		// Already verified by the RequireCaptcha middleware
+		return true
+	}
+
+	if token == "" {
+		api.JSON(c, http.StatusBadRequest, api.APIError{
+			Error:  "Invalid request format",
+			Code:   api.CodeInvalidRequest,
+			Fields: []api.FieldError{{Field: "captchaToken", Message: "is required"}},
+		})
+		return false
+	}
+
+	result, err := h.captchaClient.Verify(token, c.ClientIP())
+	if err != nil {
+		api.RespondError(c, middleware.CaptchaErrorStatus(err), api.CodeCaptchaUnavailable, "Failed to verify captcha")
+		return false
+	}
+	if !result.Success || !result.MatchesAction(action) {
+		middleware.LogCaptchaFailure(c, result)
+		api.RespondError(c, http.StatusBadRequest, api.CodeCaptchaInvalid, "Invalid captcha")
+		return false
+	}
+	return true
+}
+
+// expirySkew returns the tolerated clock skew when matching requested expiry times
+func (h *SecretAPIHandler) expirySkew() time.Duration {
+	if h.config.Secrets.ExpirySkewSec <= 0 {
+		return defaultExpirySkew
+	}
+	return time.Duration(h.config.Secrets.ExpirySkewSec) * time.Second
+}
+
+// exposedCreatedAt returns the creation time to include in responses, or nil
+// unless secrets.expose_created_at is set, since it is metadata some
+// deployments would rather not reveal
+func (h *SecretAPIHandler) exposedCreatedAt(secret *models.Secret) *time.Time {
+	if !h.config.Secrets.ExposeCreatedAt {
+		return nil
+	}
+	createdAt := secret.CreatedAt
+	return &createdAt
+}
+
+// deleteExpired deletes a secret found to have expired when it was requested,
+// unless secrets.expired_retention_minutes says to keep it for now
+func (h *SecretAPIHandler) deleteExpired(c *gin.Context, secret *models.Secret) {
+	h.decryptCache.remove(secret.ID)
+	if h.fileStore.RetainsExpired(secret) {
+		return
+	}
+	if err := h.fileStore.DeleteExpired(secret); err != nil {
+		logger.Error("Failed to delete expired secret", map[string]interface{}{
+			"error": err.Error(),
+			"id":    secret.ID,
+		})
+	}
+	logger.AuditAge("expire", secret.ID, c.ClientIP(), secret.Lifetime())
+}
+
+// pastExpiryGrace reports whether a secret expired longer ago than
+// secrets.expiry_grace_seconds, so it can no longer be read
+func (h *SecretAPIHandler) pastExpiryGrace(secret *models.Secret) bool {
+	grace := time.Duration(h.config.Secrets.ExpiryGraceSeconds) * time.Second
+	if grace < 0 {
+		grace = 0
+	}
+	return secret.IsExpired(h.clock.Now().Add(-grace))
+}
+
+// jitterExpiry moves expiresAt by a random offset of up to
+// secrets.expiry_jitter_seconds in either direction. The offset is capped at
+// maxExpiryJitter, so the expiry still rounds to the whole minute the user chose.
+func (h *SecretAPIHandler) jitterExpiry(expiresAt time.Time) time.Time {
+	jitter := time.Duration(h.config.Secrets.ExpiryJitterSeconds) * time.Second
+	if jitter <= 0 {
+		return expiresAt
+	}
+	if jitter > maxExpiryJitter {
+		jitter = maxExpiryJitter
+	}
+	return expiresAt.Add(time.Duration(rand.Int64N(int64(2*jitter)+1)) - jitter)
+}
+
+// parseExpiresIn parses a positive duration such as "90s", "10m", "1h" or
+// "7d". Days aren't supported by time.ParseDuration so they are handled here.
+func parseExpiresIn(value string) (time.Duration, error) {
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n > maxExpiresInDays {
+			return 0, fmt.Errorf("invalid expiresIn %q", value)
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if duration, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("invalid expiresIn %q", value)
+		}
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("expiresIn must be positive")
+	}
+	return duration, nil
+}
+
+// contentLengthHint clamps the creator-supplied plaintext size to what the
+// server would accept. The hint can't be verified since the server never sees
+// the plaintext.
+func (h *SecretAPIHandler) contentLengthHint(length int) int {
+	if length < 0 {
+		return 0
+	}
+	if maxSize := h.config.Secrets.MaxSizeBytes; maxSize > 0 && length > maxSize {
+		return maxSize
+	}
+	return length
+}
+
+// ciphertextEncoding returns the configured encoding for server-side encrypted data
+func (h *SecretAPIHandler) ciphertextEncoding() encryption.Encoding {
+	encoding, err := encryption.ParseEncoding(h.config.Security.CiphertextEncoding)
+	if err != nil {
+		return encryption.EncodingBase64
+	}
+	return encoding
+}
+
+// errDecryptionFailed marks stored data that the server key can't decrypt,
+// as opposed to data in an invalid format
+var errDecryptionFailed = errors.New("failed to decrypt server-side encryption")
+
+// serverEncrypted reports whether a secret's data is server-encrypted. Records
+// written before the choice was stored follow the global setting.
+func (h *SecretAPIHandler) serverEncrypted(secret *models.Secret) bool {
+	if secret.ServerEncrypted != nil {
+		return *secret.ServerEncrypted
+	}
+	return h.config.Security.ServerSideEncryption
 }
 
 func (h *SecretAPIHandler) decryptAndPrepareSecret(secret *models.Secret) (*APISecretContentResponse, error) {
-	var combinedData string
+	content, ok := h.decryptCache.get(secret.ID)
+	if !ok {
+		var err error
+		if content, err = h.decryptContent(secret); err != nil {
+			return nil, err
+		}
+		if cacheable(secret) {
+			h.decryptCache.put(secret.ID, content)
+		}
+	}
+
+	return &APISecretContentResponse{
+		EncryptedContent:   content,
+		CreatedAt:          h.exposedCreatedAt(secret),
+		ExpiresAt:          secret.ExpiresAt,
+		IsBurnAfterReading: secret.IsBurnAfterReading,
+		MaxViews:           secret.MaxViews,
+		ViewCount:          secret.ViewCount,
+		ContentKind:        secret.ContentKind,
+	}, nil
+}
 
-	if h.config.Security.ServerSideEncryption {
-		// Decode the base64-encoded encrypted data
-		encryptedBytes, err := encryption.DecodeString(string(secret.EncryptedData))
+// decryptContent removes the server-side encryption from a secret's stored
+// data, returning the client-side encrypted content
+func (h *SecretAPIHandler) decryptContent(secret *models.Secret) (models.EncryptedContent, error) {
+	var combinedData []byte
+
+	if h.serverEncrypted(secret) {
+		// Decode the encrypted data
+		encryptedBytes, err := h.ciphertextEncoding().DecodeString(string(secret.EncryptedData))
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode encrypted data: %w", err)
+			return models.EncryptedContent{}, fmt.Errorf("failed to decode encrypted data: %w", err)
 		}
 
 		// Decrypt using server key
 		decryptedBytes, err := h.encryptor.Decrypt(encryptedBytes, "")
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt server-side encryption: %w", err)
+			return models.EncryptedContent{}, fmt.Errorf("%w: %w", errDecryptionFailed, err)
 		}
-		combinedData = string(decryptedBytes)
+
+		// Decrypt decompresses by the envelope flag regardless of the current
+		// setting, so secrets stay readable after compress_at_rest is switched off
+		combinedData = decryptedBytes
 	} else {
-		combinedData = string(secret.EncryptedData)
+		combinedData = secret.EncryptedData
 	}
 
 	// Split the combined data into its components
-	parts := strings.Split(combinedData, ".")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid data format")
+	var content models.EncryptedContent
+	if err := content.UnmarshalBinary(combinedData); err != nil {
+		return models.EncryptedContent{}, err
 	}
+	return content, nil
+}
 
-	return &APISecretContentResponse{
-		EncryptedContent: models.EncryptedContent{
-			Encrypted: parts[0],
-			Salt:      parts[1],
-			IV:        parts[2],
-		},
-		ExpiresAt:          secret.ExpiresAt,
-		IsBurnAfterReading: secret.IsBurnAfterReading,
-	}, nil
+// replayView serves the response recorded for a repeated view token, without
+// counting another view
+func (h *SecretAPIHandler) replayView(c *gin.Context, viewKey string) bool {
+	response := h.viewTokens.get(viewKey)
+	if response == nil {
+		return false
+	}
+	api.JSON(c, http.StatusOK, response)
+	return true
+}
+
+// respondWithSecret serves a secret that was looked up by ID or name, deleting
+// it if it has expired and counting the view towards its view limit
+func (h *SecretAPIHandler) respondWithSecret(c *gin.Context, secret *models.Secret, viewKey, passphrase string) {
+	// Check if secret is expired, tolerating reads just past the boundary
+	if h.pastExpiryGrace(secret) {
+		h.deleteExpired(c, secret)
+		if h.config.Security.UniformNotFound {
+			// Don't reveal that the secret ever existed
+			api.RespondError(c, http.StatusNotFound, api.CodeNotFound, "Secret not found")
+			return
+		}
+		api.RespondError(c, http.StatusGone, api.CodeExpired, "Secret has expired")
+		return
+	}
+
+	// A wrong passphrase doesn't use up a view
+	if !secret.CheckPassphrase(passphrase) {
+		api.RespondError(c, http.StatusUnauthorized, api.CodeUnauthorized, "Incorrect view passphrase")
+		return
+	}
+
+	// Prepare the response
+	response, err := h.decryptAndPrepareSecret(secret)
+	if errors.Is(err, errDecryptionFailed) {
+		// Usually a server key mismatch, so don't blame the client
+		logger.Error("Failed to decrypt secret", map[string]interface{}{
+			"error":      err.Error(),
+			"error_type": "decryption_failed",
+			"id":         secret.ID,
+		})
+		api.RespondError(c, http.StatusInternalServerError, api.CodeDecryptionFailed, "Failed to decrypt secret")
+		return
+	}
+	if err != nil {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidData, err.Error())
+		return
+	}
+
+	// Count the view, burning the secret once its view limit is reached
+	viewed, err := h.fileStore.RecordView(secret.ID)
+	if err != nil {
+		logger.Error("Failed to record secret view", map[string]interface{}{
+			"error": err.Error(),
+			"id":    secret.ID,
+		})
+		api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to get secret")
+		return
+	}
+	if viewed == nil {
+		// A concurrent request consumed the last view
+		api.RespondError(c, http.StatusNotFound, api.CodeNotFound, "Secret not found")
+		return
+	}
+	response.ViewCount = viewed.ViewCount
+
+	logger.AuditAge("view", secret.ID, c.ClientIP(), h.clock.Now().Sub(secret.CreatedAt))
+	if viewed.ViewsExhausted() {
+		h.decryptCache.remove(secret.ID)
+	}
+	if viewed.ViewsExhausted() && viewed.BurnPendingSince == nil {
+		logger.Audit("burn", secret.ID, c.ClientIP())
+	} else if secret.IsExpired(h.clock.Now()) {
+		// Served inside the expiry grace window, which only allows one read
+		h.decryptCache.remove(secret.ID)
+		if err := h.fileStore.DeleteExpired(secret); err != nil {
+			logger.Error("Failed to delete expired secret", map[string]interface{}{
+				"error": err.Error(),
+				"id":    secret.ID,
+			})
+		}
+		logger.AuditAge("expire", secret.ID, c.ClientIP(), secret.Lifetime())
+	}
+
+	h.viewTokens.put(viewKey, response)
+	api.JSON(c, http.StatusOK, response)
 }
 
 // GetSecret retrieves a secret by ID
 func (h *SecretAPIHandler) GetSecret(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing secret ID"})
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidID, "Missing secret ID")
 		return
 	}
 
-	// Validate UUID format
-	if !uuidPattern.MatchString(strings.ToLower(id)) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid secret ID format"})
+	// Validate ID format
+	if !h.validSecretID(id) {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidID, "Invalid secret ID format")
 		return
 	}
 
 	var req APIViewSecretRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		respondBindingError(c, err)
 		return
 	}
 
 	// Verify captcha
-	resp, err := h.captchaClient.Verify(req.CaptchaToken, c.ClientIP())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify captcha"})
+	if !h.verifyCaptcha(c, req.CaptchaToken, h.config.Security.Captcha.View, h.config.Security.Captcha.ViewAction) {
+		return
+	}
+
+	h.respondWithSecretByID(c, id, req.ViewToken, req.ViewPassphrase)
+}
+
+// GetSecretWithToken retrieves a secret by ID for API clients. The route is
+// protected by the API token instead of a captcha, so it can be a plain GET.
+func (h *SecretAPIHandler) GetSecretWithToken(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidID, "Missing secret ID")
+		return
+	}
+
+	// Validate ID format
+	if !h.validSecretID(id) {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidID, "Invalid secret ID format")
 		return
 	}
-	if !resp.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid captcha"})
+
+	h.respondWithSecretByID(c, id, c.Query("viewToken"), c.GetHeader(ViewPassphraseHeader))
+}
+
+// respondWithSecretByID looks up a secret by ID and serves it
+func (h *SecretAPIHandler) respondWithSecretByID(c *gin.Context, id, viewToken, passphrase string) {
+	viewKey := viewTokenKey("id", id, viewToken, passphrase)
+	if h.replayView(c, viewKey) {
 		return
 	}
 
-	// Get secret
 	secret, err := h.fileStore.Get(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get secret"})
+		api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to get secret")
 		return
 	}
 	if secret == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Secret not found"})
+		api.RespondError(c, http.StatusNotFound, api.CodeNotFound, "Secret not found")
 		return
 	}
 
-	// Check if secret is expired
-	if secret.IsExpired() {
-		if err := h.fileStore.Delete(id); err != nil {
-			logger.Error("Failed to delete expired secret", map[string]interface{}{
-				"error": err.Error(),
-				"id":    id,
-			})
-		}
-		c.JSON(http.StatusGone, gin.H{"error": "Secret has expired"})
+	h.respondWithSecret(c, secret, viewKey, passphrase)
+}
+
+// GetSecretStatus reports whether a secret exists and can be viewed right now,
+// without requiring a captcha, decrypting, or consuming the secret
+func (h *SecretAPIHandler) GetSecretStatus(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidID, "Missing secret ID")
 		return
 	}
 
-	// Prepare the response
-	response, err := h.decryptAndPrepareSecret(secret)
+	// Validate ID format
+	if !h.validSecretID(id) {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidID, "Invalid secret ID format")
+		return
+	}
+
+	secret, err := h.fileStore.Get(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to get secret")
+		return
+	}
+	if secret == nil {
+		api.JSON(c, http.StatusOK, APISecretStatusResponse{})
 		return
 	}
 
-	// Delete the secret if it's burn-after-reading
-	if secret.IsBurnAfterReading {
-		if err := h.fileStore.Delete(secret.ID.String()); err != nil {
-			logger.Error("Failed to delete burn-after-reading secret", map[string]interface{}{
-				"error": err.Error(),
-				"id":    secret.ID,
-			})
+	// Clean up expired secrets as they are encountered
+	if h.pastExpiryGrace(secret) {
+		h.deleteExpired(c, secret)
+		if h.config.Security.UniformNotFound {
+			api.JSON(c, http.StatusOK, APISecretStatusResponse{})
+			return
 		}
+		api.JSON(c, http.StatusOK, APISecretStatusResponse{Exists: true, Expired: true})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	api.JSON(c, http.StatusOK, APISecretStatusResponse{
+		Exists:             true,
+		Viewable:           true,
+		CreatedAt:          h.exposedCreatedAt(secret),
+		ContentLength:      secret.ContentLength,
+		PassphraseRequired: secret.PassphraseHash != "",
+	})
 }
 
 // GetSecretByName retrieves a secret by custom name
 func (h *SecretAPIHandler) GetSecretByName(c *gin.Context) {
 	name := c.Param("name")
 	if name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing secret name"})
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidName, "Missing secret name")
 		return
 	}
 
-	// Validate name format (alphanumeric only)
-	if !models.CustomNameRegex.MatchString(name) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Secret name can only contain letters and numbers (A-Z, a-z, 0-9)"})
+	// Validate name format
+	if !h.namePattern.MatchString(name) {
+		api.RespondError(c, http.StatusBadRequest, api.CodeInvalidName, "Secret name can only contain "+h.namePattern.Description())
 		return
 	}
 
 	var req APIViewSecretRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		respondBindingError(c, err)
+		return
+	}
+
+	// Slow down name guessing before spending a captcha verification on it
+	if h.nameLookupsBlocked(c) {
 		return
 	}
 
 	// Verify captcha
-	resp, err := h.captchaClient.Verify(req.CaptchaToken, c.ClientIP())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify captcha"})
+	if !h.verifyCaptcha(c, req.CaptchaToken, h.config.Security.Captcha.View, h.config.Security.Captcha.ViewAction) {
 		return
 	}
-	if !resp.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid captcha"})
+
+	viewKey := viewTokenKey("name", name, req.ViewToken, req.ViewPassphrase)
+	if h.replayView(c, viewKey) {
 		return
 	}
 
 	// Get secret by name
 	secret, err := h.fileStore.GetByCustomName(name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get secret"})
+		api.RespondError(c, http.StatusInternalServerError, api.CodeInternal, "Failed to get secret")
 		return
 	}
 	if secret == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Secret not found"})
+		h.recordFailedNameLookup(c)
+		api.RespondError(c, http.StatusNotFound, api.CodeNotFound, "Secret not found")
 		return
 	}
 
-	// Check if secret is expired
-	if secret.IsExpired() {
-		if err := h.fileStore.Delete(secret.ID.String()); err != nil {
-			logger.Error("Failed to delete expired secret", map[string]interface{}{
-				"error": err.Error(),
-				"id":    secret.ID,
-			})
-		}
-		c.JSON(http.StatusGone, gin.H{"error": "Secret has expired"})
-		return
-	}
-
-	// Prepare the response
-	response, err := h.decryptAndPrepareSecret(secret)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Delete the secret if it's burn-after-reading
-	if secret.IsBurnAfterReading {
-		if err := h.fileStore.Delete(secret.ID.String()); err != nil {
-			logger.Error("Failed to delete burn-after-reading secret", map[string]interface{}{
-				"error": err.Error(),
-				"id":    secret.ID,
-			})
-		}
-	}
-
-	c.JSON(http.StatusOK, response)
+	h.respondWithSecret(c, secret, viewKey, req.ViewPassphrase)
 }
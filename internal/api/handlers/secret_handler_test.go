@@ -2,24 +2,31 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"secrets-share/internal/api"
+	"secrets-share/internal/api/middleware"
 	"secrets-share/internal/captcha"
 	"secrets-share/internal/config"
 	"secrets-share/internal/encryption"
 	"secrets-share/internal/models"
 	"secrets-share/internal/storage/file"
+	"secrets-share/internal/storage/redis"
 
 	"secrets-share/internal/logger"
 
@@ -50,7 +57,8 @@ func setupTestEnvironment(t *testing.T) (*gin.Engine, *SecretAPIHandler, *MockTu
 		},
 	}
 
-	// Initialize logger
+	// Initialize logger, replacing one configured by an earlier test
+	logger.Reset()
 	err := logger.Init(cfg, false)
 	if err != nil {
 		t.Fatalf("Failed to initialize logger: %v", err)
@@ -69,9 +77,16 @@ func setupTestEnvironment(t *testing.T) (*gin.Engine, *SecretAPIHandler, *MockTu
 		Security: config.SecurityConfig{
 			EnableCaptcha:        true,
 			ServerSideEncryption: true,
+			Captcha: config.CaptchaConfig{
+				Create: true,
+				View:   true,
+			},
 		},
 		Secrets: config.SecretsConfig{
 			MaxSizeBytes:         500,
+			MinCiphertextBytes:   16,
+			SaltBytes:            16,
+			IVBytes:              12,
 			StoragePath:          testDir,
 			MaxExpiryDays:        7,
 			DefaultExpiryMinutes: 60,
@@ -99,7 +114,9 @@ func setupTestEnvironment(t *testing.T) (*gin.Engine, *SecretAPIHandler, *MockTu
 
 	router.POST("/api/secrets", handler.CreateSecret)
 	router.POST("/api/secrets/:id", handler.GetSecret)
+	router.GET("/api/secrets/:id/status", handler.GetSecretStatus)
 	router.POST("/api/secrets/name/:name", handler.GetSecretByName)
+	router.GET("/api/admin/stats", NewAdminAPIHandler(fileStore, nil, encryptor, handler.Maintenance(), testConfig).GetStats)
 
 	cleanup := func() {
 		os.RemoveAll(testDir)
@@ -118,9 +135,9 @@ func TestCreateSecret(t *testing.T) {
 	t.Run("Create secret with valid data", func(t *testing.T) {
 		// Create test data with base64 encoded values
 		encryptedContent := models.EncryptedContent{
-			Encrypted: base64.StdEncoding.EncodeToString([]byte("test-data")),
-			Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt")),
-			IV:        base64.StdEncoding.EncodeToString([]byte("test-iv")),
+			Encrypted: base64.StdEncoding.EncodeToString([]byte("test-encrypted-data")),
+			Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt-16byte")),
+			IV:        base64.StdEncoding.EncodeToString([]byte("test-iv-12by")),
 		}
 
 		reqBody := APICreateSecretRequest{
@@ -178,15 +195,15 @@ func TestGetSecret(t *testing.T) {
 
 	// Create a test secret first
 	secret := &models.Secret{
-		ID:        uuid.New(),
+		ID:        uuid.NewString(),
 		CreatedAt: time.Now(),
 	}
 
 	// Prepare the encrypted content with base64 encoded values
 	encryptedContent := models.EncryptedContent{
-		Encrypted: base64.StdEncoding.EncodeToString([]byte("test-data")),
-		Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt")),
-		IV:        base64.StdEncoding.EncodeToString([]byte("test-iv")),
+		Encrypted: base64.StdEncoding.EncodeToString([]byte("test-encrypted-data")),
+		Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt-16byte")),
+		IV:        base64.StdEncoding.EncodeToString([]byte("test-iv-12by")),
 	}
 
 	// Combine the encrypted content
@@ -230,7 +247,7 @@ func TestGetSecret(t *testing.T) {
 	})
 
 	t.Run("Get non-existent secret", func(t *testing.T) {
-		nonExistentID := uuid.New()
+		nonExistentID := uuid.NewString()
 		reqBody := APIViewSecretRequest{
 			CaptchaToken: "valid-token",
 		}
@@ -257,16 +274,16 @@ func TestGetSecretByName(t *testing.T) {
 
 	// Create a test secret first
 	secret := &models.Secret{
-		ID:         uuid.New(),
+		ID:         uuid.NewString(),
 		CustomName: "test123", // Valid alphanumeric name
 		CreatedAt:  time.Now(),
 	}
 
 	// Prepare the encrypted content with base64 encoded values
 	encryptedContent := models.EncryptedContent{
-		Encrypted: base64.StdEncoding.EncodeToString([]byte("test-data")),
-		Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt")),
-		IV:        base64.StdEncoding.EncodeToString([]byte("test-iv")),
+		Encrypted: base64.StdEncoding.EncodeToString([]byte("test-encrypted-data")),
+		Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt-16byte")),
+		IV:        base64.StdEncoding.EncodeToString([]byte("test-iv-12by")),
 	}
 
 	// Combine the encrypted content
@@ -360,8 +377,8 @@ func TestServerSideEncryption(t *testing.T) {
 			originalData := "test-secret-data"
 			encryptedContent := models.EncryptedContent{
 				Encrypted: base64.StdEncoding.EncodeToString([]byte(originalData)),
-				Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt")),
-				IV:        base64.StdEncoding.EncodeToString([]byte("test-iv")),
+				Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt-16byte")),
+				IV:        base64.StdEncoding.EncodeToString([]byte("test-iv-12by")),
 			}
 
 			reqBody := APICreateSecretRequest{
@@ -391,16 +408,13 @@ func TestServerSideEncryption(t *testing.T) {
 			assert.NotNil(t, secret)
 
 			// Check if data is encrypted as expected
-			combinedOriginal := fmt.Sprintf("%s.%s.%s",
-				encryptedContent.Encrypted,
-				encryptedContent.Salt,
-				encryptedContent.IV,
-			)
+			combinedOriginal, err := encryptedContent.MarshalBinary()
+			assert.NoError(t, err)
 
 			if tt.wantEncrypted {
-				assert.NotEqual(t, combinedOriginal, string(secret.EncryptedData), "Data should be server-side encrypted")
+				assert.NotEqual(t, combinedOriginal, secret.EncryptedData, "Data should be server-side encrypted")
 			} else {
-				assert.Equal(t, combinedOriginal, string(secret.EncryptedData), "Data should not be server-side encrypted")
+				assert.Equal(t, combinedOriginal, secret.EncryptedData, "Data should not be server-side encrypted")
 			}
 
 			// Verify the secret can be retrieved and decrypted
@@ -429,3 +443,2442 @@ func TestServerSideEncryption(t *testing.T) {
 		})
 	}
 }
+
+func TestPerSecretServerSideEncryption(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	content := testEncryptedContent()
+	plain, err := content.MarshalBinary()
+	assert.NoError(t, err)
+	on, off := true, false
+
+	// Secrets with and without server-side encryption share one store
+	handler.config.Security.ServerSideEncryption = false
+	ids := map[string]bool{
+		createTestSecret(t, router, APICreateSecretRequest{EncryptedContent: content, CaptchaToken: "valid-token"}):                         false,
+		createTestSecret(t, router, APICreateSecretRequest{EncryptedContent: content, ServerSideEncrypt: &on, CaptchaToken: "valid-token"}): true,
+	}
+	handler.config.Security.ServerSideEncryption = true
+	ids[createTestSecret(t, router, APICreateSecretRequest{EncryptedContent: content, CaptchaToken: "valid-token"})] = true
+	// Opting out is ignored, so clients can't weaken the operator's setting
+	ids[createTestSecret(t, router, APICreateSecretRequest{EncryptedContent: content, ServerSideEncrypt: &off, CaptchaToken: "valid-token"})] = true
+
+	for id, wantEncrypted := range ids {
+		secret, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		if assert.NotNil(t, secret.ServerEncrypted) {
+			assert.Equal(t, wantEncrypted, *secret.ServerEncrypted)
+		}
+		if wantEncrypted {
+			assert.NotEqual(t, plain, secret.EncryptedData, "Data should be server-side encrypted")
+		} else {
+			assert.Equal(t, plain, secret.EncryptedData, "Data should not be server-side encrypted")
+		}
+	}
+
+	// Records without the flag follow the global setting
+	legacy := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now(), EncryptedData: plain}
+	assert.NoError(t, handler.fileStore.Store(legacy))
+	handler.config.Security.ServerSideEncryption = false
+	ids[legacy.ID] = false
+
+	// Every secret reads back correctly whatever the global setting is now
+	for id := range ids {
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			var response APISecretContentResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, content, response.EncryptedContent)
+		}
+	}
+}
+
+func TestCompressAtRest(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	content := testEncryptedContent()
+	content.Encrypted = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("compressible "), 20))
+
+	handler.config.Secrets.CompressAtRest = true
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: content,
+		CaptchaToken:     "valid-token",
+	})
+
+	// The stored envelope header carries the compressed flag
+	stored, err := handler.fileStore.Get(id)
+	assert.NoError(t, err)
+	encrypted, err := handler.ciphertextEncoding().DecodeString(string(stored.EncryptedData))
+	assert.NoError(t, err)
+	assert.True(t, encryption.IsCompressed(encrypted))
+
+	// Secrets written compressed stay readable after the option is turned off
+	handler.config.Secrets.CompressAtRest = false
+	w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response APISecretContentResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, content, response.EncryptedContent)
+}
+
+func TestGetSecretStatus(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	getStatus := func(id string) (*httptest.ResponseRecorder, APISecretStatusResponse) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/secrets/%s/status", id), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response APISecretStatusResponse
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w, response
+	}
+
+	t.Run("Existing secret is viewable", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		secret := &models.Secret{
+			ID:            uuid.NewString(),
+			CreatedAt:     time.Now(),
+			ExpiresAt:     &expiresAt,
+			EncryptedData: []byte("data"),
+		}
+		assert.NoError(t, handler.fileStore.Store(secret))
+
+		w, response := getStatus(secret.ID)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, APISecretStatusResponse{Exists: true, Viewable: true}, response)
+
+		// Checking the status must not consume the secret
+		stored, err := handler.fileStore.Get(secret.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, stored)
+	})
+
+	t.Run("Expired secret is reported and cleaned up", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Minute)
+		secret := &models.Secret{
+			ID:        uuid.NewString(),
+			CreatedAt: time.Now().Add(-time.Hour),
+			ExpiresAt: &expiresAt,
+		}
+		assert.NoError(t, handler.fileStore.Store(secret))
+
+		w, response := getStatus(secret.ID)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, APISecretStatusResponse{Exists: true, Expired: true}, response)
+
+		stored, err := handler.fileStore.Get(secret.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("Missing secret", func(t *testing.T) {
+		w, response := getStatus(uuid.NewString())
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, APISecretStatusResponse{}, response)
+	})
+
+	t.Run("Invalid ID format", func(t *testing.T) {
+		w, _ := getStatus("not-a-uuid")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// The status endpoint never asks for a captcha
+	mockTurnstileClient.AssertNotCalled(t, "Verify", mock.Anything, mock.Anything)
+}
+
+func TestContentLengthHint(t *testing.T) {
+	router, _, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	tests := []struct {
+		name          string
+		contentLength int
+		want          int
+	}{
+		{"Round-trips", 120, 120},
+		{"Clamped to max size", 4 << 20, 500},
+		{"Negative is dropped", -1, 0},
+		{"Omitted", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := createTestSecret(t, router, APICreateSecretRequest{
+				EncryptedContent: testEncryptedContent(),
+				ContentLength:    tt.contentLength,
+				CaptchaToken:     "valid-token",
+			})
+
+			req := httptest.NewRequest("GET", "/api/secrets/"+id+"/status", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response APISecretStatusResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, APISecretStatusResponse{Exists: true, Viewable: true, ContentLength: tt.want}, response)
+		})
+	}
+}
+
+func TestContentKind(t *testing.T) {
+	router, _, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	for _, kind := range []string{"text", "markdown", "binary", ""} {
+		t.Run("Round-trips "+kind, func(t *testing.T) {
+			id := createTestSecret(t, router, APICreateSecretRequest{
+				EncryptedContent: testEncryptedContent(),
+				ContentKind:      kind,
+				CaptchaToken:     "valid-token",
+			})
+
+			w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response APISecretContentResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, kind, response.ContentKind)
+		})
+	}
+
+	t.Run("Unknown kind is rejected", func(t *testing.T) {
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ContentKind:      "html",
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		apiErr := decodeError(t, w)
+		assert.Equal(t, api.CodeInvalidRequest, apiErr.Code)
+		assert.Equal(t, []api.FieldError{{Field: "contentKind", Message: `failed "oneof" validation`}}, apiErr.Fields)
+	})
+}
+
+func TestViewToken(t *testing.T) {
+	router, _, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	view := func(path, viewToken string) (int, APISecretContentResponse) {
+		w := postJSON(t, router, path, APIViewSecretRequest{CaptchaToken: "valid-token", ViewToken: viewToken})
+		var response APISecretContentResponse
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+
+	t.Run("Same token counts as one view", func(t *testing.T) {
+		maxViews := 3
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		code, first := view("/api/secrets/"+id, "attempt-1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, first.ViewCount)
+
+		code, retry := view("/api/secrets/"+id, "attempt-1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, first, retry)
+
+		code, second := view("/api/secrets/"+id, "attempt-2")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, second.ViewCount)
+	})
+
+	t.Run("Retry after the last view returns the same content", func(t *testing.T) {
+		maxViews := 2
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		view("/api/secrets/"+id, "attempt-1")
+		code, last := view("/api/secrets/"+id, "attempt-2")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, last.ViewCount)
+
+		code, retry := view("/api/secrets/"+id, "attempt-2")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, last, retry)
+
+		code, _ = view("/api/secrets/"+id, "attempt-3")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("Reads by name", func(t *testing.T) {
+		maxViews := 3
+		createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CustomName:       "viewtoken",
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		_, first := view("/api/secrets/name/viewtoken", "attempt-1")
+		_, retry := view("/api/secrets/name/viewtoken", "attempt-1")
+		assert.Equal(t, 1, first.ViewCount)
+		assert.Equal(t, 1, retry.ViewCount)
+
+		_, second := view("/api/secrets/name/viewtoken", "")
+		assert.Equal(t, 2, second.ViewCount, "reads without a token always count")
+	})
+}
+
+func TestViewPassphrase(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	status := func(id string) APISecretStatusResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/secrets/"+id+"/status", nil))
+		var response APISecretStatusResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("Incorrect passphrase is rejected without burning", func(t *testing.T) {
+		maxViews := 1
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			ViewPassphrase:   "open sesame",
+			CaptchaToken:     "valid-token",
+		})
+		assert.True(t, status(id).PassphraseRequired)
+
+		stored, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		assert.NotContains(t, stored.PassphraseHash, "open sesame")
+
+		for _, passphrase := range []string{"", "wrong"} {
+			w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token", ViewPassphrase: passphrase})
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, api.CodeUnauthorized, decodeError(t, w).Code)
+		}
+
+		stored, err = handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		if assert.NotNil(t, stored, "secret was burned by a rejected read") {
+			assert.Equal(t, 0, stored.ViewCount)
+		}
+
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token", ViewPassphrase: "open sesame"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response APISecretContentResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, testEncryptedContent(), response.EncryptedContent)
+	})
+
+	t.Run("Passphrase applies to named secrets", func(t *testing.T) {
+		createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CustomName:       "gated",
+			ViewPassphrase:   "open sesame",
+			CaptchaToken:     "valid-token",
+		})
+
+		w := postJSON(t, router, "/api/secrets/name/gated", APIViewSecretRequest{CaptchaToken: "valid-token", ViewPassphrase: "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, "/api/secrets/name/gated", APIViewSecretRequest{CaptchaToken: "valid-token", ViewPassphrase: "open sesame"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Replayed view token needs the passphrase", func(t *testing.T) {
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ViewPassphrase:   "open sesame",
+			CaptchaToken:     "valid-token",
+		})
+
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token", ViewToken: "nonce", ViewPassphrase: "open sesame"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token", ViewToken: "nonce", ViewPassphrase: "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Secrets without a passphrase are unchanged", func(t *testing.T) {
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     "valid-token",
+		})
+		assert.False(t, status(id).PassphraseRequired)
+
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token", ViewPassphrase: "anything"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestCiphertextEncoding(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Mock successful captcha verification
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	encryptedContent := models.EncryptedContent{
+		Encrypted: base64.StdEncoding.EncodeToString([]byte("test-secret-data")),
+		Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt-16byte")),
+		IV:        base64.StdEncoding.EncodeToString([]byte("test-iv-12by")),
+	}
+
+	for _, encoding := range []string{"base64", "base64url"} {
+		t.Run(encoding, func(t *testing.T) {
+			handler.config.Security.CiphertextEncoding = encoding
+
+			jsonData, err := json.Marshal(APICreateSecretRequest{
+				EncryptedContent: encryptedContent,
+				CaptchaToken:     "valid-token",
+			})
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest("POST", "/api/secrets", bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response APISecretResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+			secret, err := handler.fileStore.Get(response.ID)
+			assert.NoError(t, err)
+			if encoding == "base64url" {
+				assert.NotContains(t, string(secret.EncryptedData), "=")
+			}
+
+			jsonData, err = json.Marshal(APIViewSecretRequest{CaptchaToken: "valid-token"})
+			assert.NoError(t, err)
+
+			req = httptest.NewRequest("POST", fmt.Sprintf("/api/secrets/%s", response.ID), bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var viewResponse APISecretContentResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &viewResponse))
+			assert.Equal(t, encryptedContent, viewResponse.EncryptedContent)
+		})
+	}
+}
+
+// testEncryptedContent returns valid client-side encrypted content for tests
+func testEncryptedContent() models.EncryptedContent {
+	return models.EncryptedContent{
+		Encrypted: base64.StdEncoding.EncodeToString([]byte("test-encrypted-data")),
+		Salt:      base64.StdEncoding.EncodeToString([]byte("test-salt-16byte")),
+		IV:        base64.StdEncoding.EncodeToString([]byte("test-iv-12by")),
+	}
+}
+
+// postJSON sends a JSON request through the router and returns the recorder
+func postJSON(t *testing.T, router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonData, err := json.Marshal(body)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) api.APIError {
+	var response api.APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+// createTestSecret creates a secret through the API and returns its ID
+func createTestSecret(t *testing.T, router *gin.Engine, reqBody APICreateSecretRequest) string {
+	w := postJSON(t, router, "/api/secrets", reqBody)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+
+	var response APISecretResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.ID
+}
+
+func TestMultiViewSecret(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Mock successful captcha verification
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	t.Run("View count is reported for each read", func(t *testing.T) {
+		maxViews := 5
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		var response APISecretContentResponse
+		for i := 0; i < 3; i++ {
+			w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+
+		assert.Equal(t, 3, response.ViewCount)
+		assert.Equal(t, &maxViews, response.MaxViews)
+		assert.False(t, response.IsBurnAfterReading)
+
+		// The aggregate counter is exposed through the stats endpoint
+		req := httptest.NewRequest("GET", "/api/admin/stats", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var stats APIStatsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		assert.Equal(t, int64(3), stats.TotalViews)
+	})
+
+	t.Run("Secret is deleted after its last view", func(t *testing.T) {
+		maxViews := 2
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		for i := 0; i < maxViews; i++ {
+			w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		stored, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("Burn-after-reading secret is deleted after one view", func(t *testing.T) {
+		maxViews := 1
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response APISecretContentResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.IsBurnAfterReading)
+		assert.Equal(t, 1, response.ViewCount)
+
+		w = postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestMaxViewsLimit(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+	handler.config.Secrets.MaxViewsLimit = 10
+
+	createWithViews := func(maxViews int) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+	}
+
+	t.Run("Limit is accepted", func(t *testing.T) {
+		w := createWithViews(10)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Above the limit is rejected", func(t *testing.T) {
+		w := createWithViews(11)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		response := decodeError(t, w)
+		assert.Equal(t, api.CodeInvalidRequest, response.Code)
+		assert.Contains(t, response.Error, "maximum of 10 views")
+	})
+
+	for _, maxViews := range []int{0, -1} {
+		t.Run(fmt.Sprintf("%d views is rejected", maxViews), func(t *testing.T) {
+			w := createWithViews(maxViews)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, api.CodeInvalidRequest, decodeError(t, w).Code)
+		})
+	}
+
+	t.Run("Zero limit disables the cap", func(t *testing.T) {
+		handler.config.Secrets.MaxViewsLimit = 0
+		defer func() { handler.config.Secrets.MaxViewsLimit = 10 }()
+
+		w := createWithViews(1000)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Every invalid value is reported against the maxViews field
+	for _, raw := range []string{"-1", "0", "11", "-9223372036854775809", "9223372036854775808", "1e30", "1.5", `"2"`} {
+		t.Run("Field error for "+raw, func(t *testing.T) {
+			var body map[string]json.RawMessage
+			encoded, err := json.Marshal(APICreateSecretRequest{EncryptedContent: testEncryptedContent(), CaptchaToken: "valid-token"})
+			assert.NoError(t, err)
+			assert.NoError(t, json.Unmarshal(encoded, &body))
+			body["maxViews"] = json.RawMessage(raw)
+
+			w := postJSON(t, router, "/api/secrets", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			response := decodeError(t, w)
+			assert.Equal(t, api.CodeInvalidRequest, response.Code)
+			if assert.Len(t, response.Fields, 1) {
+				assert.Equal(t, "maxViews", response.Fields[0].Field)
+			}
+		})
+	}
+}
+
+func TestMaxTotalSecrets(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Mock successful captcha verification
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	handler.config.Secrets.MaxTotal = 2
+	reqBody := APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		CaptchaToken:     "valid-token",
+	}
+
+	firstID := createTestSecret(t, router, reqBody)
+	createTestSecret(t, router, reqBody)
+
+	t.Run("Creation is refused at capacity", func(t *testing.T) {
+		w := postJSON(t, router, "/api/secrets", reqBody)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "Secret storage is full")
+	})
+
+	t.Run("Creation resumes after a delete", func(t *testing.T) {
+		assert.NoError(t, handler.fileStore.Delete(firstID))
+
+		w := postJSON(t, router, "/api/secrets", reqBody)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestBindingValidationErrors(t *testing.T) {
+	router, _, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Mock successful captcha verification
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	fieldNames := func(response api.APIError) []string {
+		var names []string
+		for _, field := range response.Fields {
+			names = append(names, field.Field)
+		}
+		return names
+	}
+
+	t.Run("Missing fields are reported individually", func(t *testing.T) {
+		body := map[string]interface{}{
+			"encryptedContent": map[string]string{
+				"encrypted": "ZGF0YQ==",
+			},
+		}
+
+		w := postJSON(t, router, "/api/secrets", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		response := decodeError(t, w)
+		assert.Equal(t, api.CodeInvalidRequest, response.Code)
+		assert.ElementsMatch(t, []string{"encryptedContent.salt", "encryptedContent.iv"}, fieldNames(response))
+		for _, field := range response.Fields {
+			assert.Equal(t, "is required", field.Message)
+		}
+	})
+
+	t.Run("Missing captcha token is reported", func(t *testing.T) {
+		body := map[string]interface{}{
+			"encryptedContent": testEncryptedContent(),
+		}
+
+		w := postJSON(t, router, "/api/secrets", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"captchaToken"}, fieldNames(decodeError(t, w)))
+	})
+
+	t.Run("Wrong field types are reported", func(t *testing.T) {
+		body := map[string]interface{}{
+			"encryptedContent": testEncryptedContent(),
+			"maxViews":         "five",
+			"captchaToken":     "valid-token",
+		}
+
+		w := postJSON(t, router, "/api/secrets", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"maxViews"}, fieldNames(decodeError(t, w)))
+	})
+
+	t.Run("View requests report a missing captcha token", func(t *testing.T) {
+		w := postJSON(t, router, "/api/secrets/"+uuid.NewString(), map[string]string{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"captchaToken"}, fieldNames(decodeError(t, w)))
+	})
+}
+
+func TestPerRouteCaptcha(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Every captcha presented in this test is rejected upstream
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: false}, nil)
+
+	storeSecret := func() string {
+		secret := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now()}
+		combinedData := fmt.Sprintf("%s.%s.%s", "ZGF0YQ==", "c2FsdA==", "aXY=")
+		encryptedData, err := handler.encryptor.Encrypt([]byte(combinedData), "")
+		assert.NoError(t, err)
+		secret.EncryptedData = []byte(encryption.EncodeToString(encryptedData))
+		assert.NoError(t, handler.fileStore.Store(secret))
+		return secret.ID
+	}
+
+	t.Run("Captcha required on create but not on view", func(t *testing.T) {
+		handler.config.Security.Captcha = config.CaptchaConfig{Create: true, View: false}
+
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     "rejected-token",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(t, router, "/api/secrets/"+storeSecret(), APIViewSecretRequest{})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("API token bypasses captcha", func(t *testing.T) {
+		handler.config.Security.Captcha = config.CaptchaConfig{Create: true, View: true}
+		handler.config.Security.APIToken = "automation-token"
+		defer func() { handler.config.Security.APIToken = "" }()
+
+		send := func(path string, body interface{}, token string) *httptest.ResponseRecorder {
+			jsonData, err := json.Marshal(body)
+			assert.NoError(t, err)
+			req := httptest.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		createBody := APICreateSecretRequest{EncryptedContent: testEncryptedContent()}
+		assert.Equal(t, http.StatusOK, send("/api/secrets", createBody, "automation-token").Code)
+		assert.Equal(t, http.StatusOK, send("/api/secrets/"+storeSecret(), APIViewSecretRequest{}, "automation-token").Code)
+
+		// A wrong token falls back to requiring a captcha
+		assert.Equal(t, http.StatusBadRequest, send("/api/secrets", createBody, "wrong-token").Code)
+	})
+}
+
+func TestGeneratedName(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+	handler.config.Secrets.Autoname = config.AutonameConfig{Enabled: true, Length: 8, MaxAttempts: 3}
+
+	// Occupy a name that the stubbed generator will hand out first
+	createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		CustomName:       "taken",
+		CaptchaToken:     "valid-token",
+	})
+
+	// stubNames makes the generator return the given names in order
+	stubNames := func(names ...string) {
+		i := 0
+		handler.generateName = func(int) (string, error) {
+			name := names[i%len(names)]
+			i++
+			return name, nil
+		}
+	}
+
+	t.Run("Collisions are retried within budget", func(t *testing.T) {
+		stubNames("taken", "taken", "fresh")
+
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			GenerateName:     true,
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response APISecretResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "fresh", response.Name)
+
+		secret, err := handler.fileStore.GetByCustomName("fresh")
+		assert.NoError(t, err)
+		assert.NotNil(t, secret)
+		assert.Equal(t, response.ID, secret.ID)
+	})
+
+	t.Run("Retry budget exhausted", func(t *testing.T) {
+		stubNames("taken")
+
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			GenerateName:     true,
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, api.CodeNameTaken, decodeError(t, w).Code)
+	})
+
+	t.Run("Generated names are unique", func(t *testing.T) {
+		handler.generateName = models.GenerateCustomName
+
+		names := map[string]bool{}
+		for i := 0; i < 5; i++ {
+			w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+				EncryptedContent: testEncryptedContent(),
+				GenerateName:     true,
+				CaptchaToken:     "valid-token",
+			})
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response APISecretResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Len(t, response.Name, 8)
+			assert.NoError(t, models.ValidateCustomName(response.Name))
+			assert.False(t, names[response.Name])
+			names[response.Name] = true
+		}
+	})
+
+	t.Run("Custom name and generateName are exclusive", func(t *testing.T) {
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CustomName:       "mine",
+			GenerateName:     true,
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Disabled autoname", func(t *testing.T) {
+		handler.config.Secrets.Autoname.Enabled = false
+
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			GenerateName:     true,
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, api.CodeInvalidName, decodeError(t, w).Code)
+	})
+}
+
+func TestExpiryValidation(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	createWithExpiry := func(expiresAt time.Time) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ExpiresAt:        &expiresAt,
+			CaptchaToken:     "valid-token",
+		})
+	}
+
+	t.Run("Past expiry is rejected", func(t *testing.T) {
+		w := createWithExpiry(time.Now().Add(-10 * time.Minute))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		response := decodeError(t, w)
+		assert.Equal(t, api.CodeInvalidExpiry, response.Code)
+		assert.Contains(t, response.Error, "in the past")
+	})
+
+	t.Run("100-year expiry is rejected", func(t *testing.T) {
+		w := createWithExpiry(time.Now().AddDate(100, 0, 0))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		response := decodeError(t, w)
+		assert.Equal(t, api.CodeInvalidExpiry, response.Code)
+		assert.Contains(t, response.Error, "maximum of 7 days")
+	})
+
+	t.Run("Skew within tolerance is normalized", func(t *testing.T) {
+		handler.config.Secrets.ExpirySkewSec = 5
+		defer func() { handler.config.Secrets.ExpirySkewSec = 0 }()
+
+		w := createWithExpiry(time.Now().Add(time.Hour + 3*time.Second))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	createWithExpiresIn := func(expiresIn string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ExpiresIn:        expiresIn,
+			CaptchaToken:     "valid-token",
+		})
+	}
+
+	t.Run("Duration expiry is applied", func(t *testing.T) {
+		before := time.Now()
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ExpiresIn:        "7d",
+			CaptchaToken:     "valid-token",
+		})
+
+		stored, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, before.Add(7*24*time.Hour), *stored.ExpiresAt, 5*time.Second)
+	})
+
+	for _, expiresIn := range []string{"8d", "0m", "-1h", "soon", "2h"} {
+		t.Run("Duration "+expiresIn+" is rejected", func(t *testing.T) {
+			w := createWithExpiresIn(expiresIn)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, api.CodeInvalidExpiry, decodeError(t, w).Code)
+		})
+	}
+
+	t.Run("Duration takes precedence over a timestamp", func(t *testing.T) {
+		// The timestamp alone would be rejected as being in the past
+		past := time.Now().Add(-time.Hour)
+		before := time.Now()
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ExpiresIn:        "30m",
+			ExpiresAt:        &past,
+			CaptchaToken:     "valid-token",
+		})
+
+		stored, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, before.Add(30*time.Minute), *stored.ExpiresAt, 5*time.Second)
+	})
+}
+
+func TestExpiryJitter(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		expiresAt := time.Now()
+		assert.Equal(t, expiresAt, handler.jitterExpiry(expiresAt))
+	})
+
+	t.Run("Jitter stays within bounds", func(t *testing.T) {
+		base := time.Now()
+		for _, configured := range []int{10, 600} {
+			handler.config.Secrets.ExpiryJitterSeconds = configured
+			bound := time.Duration(configured) * time.Second
+			if bound > maxExpiryJitter {
+				bound = maxExpiryJitter
+			}
+			for i := 0; i < 1000; i++ {
+				offset := handler.jitterExpiry(base).Sub(base)
+				assert.LessOrEqual(t, offset, bound)
+				assert.GreaterOrEqual(t, offset, -bound)
+			}
+		}
+		handler.config.Secrets.ExpiryJitterSeconds = 0
+	})
+
+	t.Run("Created secrets get distributed expiries", func(t *testing.T) {
+		handler.config.Secrets.ExpiryJitterSeconds = 20
+		defer func() { handler.config.Secrets.ExpiryJitterSeconds = 0 }()
+
+		expiries := make(map[time.Time]bool)
+		for i := 0; i < 20; i++ {
+			before := time.Now()
+			id := createTestSecret(t, router, APICreateSecretRequest{
+				EncryptedContent: testEncryptedContent(),
+				ExpiresIn:        "10m",
+				CaptchaToken:     "valid-token",
+			})
+
+			stored, err := handler.fileStore.Get(id)
+			assert.NoError(t, err)
+			offset := stored.ExpiresAt.Sub(before.Add(10 * time.Minute))
+			assert.InDelta(t, 0, offset.Seconds(), 21, "expiry should stay within the jitter")
+			expiries[stored.ExpiresAt.Truncate(time.Second)] = true
+		}
+		assert.Greater(t, len(expiries), 5, "expiries should be spread out")
+	})
+}
+
+func TestParseExpiresIn(t *testing.T) {
+	valid := map[string]time.Duration{
+		"10m":   10 * time.Minute,
+		"1h":    time.Hour,
+		"1h30m": 90 * time.Minute,
+		"1d":    24 * time.Hour,
+		"7d":    7 * 24 * time.Hour,
+	}
+	for value, expected := range valid {
+		duration, err := parseExpiresIn(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, duration, value)
+	}
+
+	for _, value := range []string{"", "0s", "-5m", "d", "1.5d", "abc", "999999999d"} {
+		_, err := parseExpiresIn(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	// Secrets created before maintenance starts remain readable
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		CaptchaToken:     "valid-token",
+	})
+	createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		CustomName:       "maintenance",
+		CaptchaToken:     "valid-token",
+	})
+
+	// Maintenance is switched on at runtime through the admin API
+	admin := NewAdminAPIHandler(handler.fileStore, nil, handler.encryptor, handler.Maintenance(), handler.config)
+	router.PUT("/api/admin/maintenance", admin.SetMaintenance)
+	setMaintenance := func(enabled bool) {
+		t.Helper()
+		req := httptest.NewRequest("PUT", "/api/admin/maintenance", strings.NewReader(fmt.Sprintf(`{"enabled":%t}`, enabled)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response APIMaintenanceResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, enabled, response.MaintenanceMode)
+	}
+	setMaintenance(true)
+
+	t.Run("Create returns 503", func(t *testing.T) {
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, api.CodeMaintenance, decodeError(t, w).Code)
+	})
+
+	t.Run("Reads succeed", func(t *testing.T) {
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(t, router, "/api/secrets/name/maintenance", APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Stats report the mode", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/admin/stats", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var stats APIStatsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		assert.True(t, stats.MaintenanceMode)
+	})
+
+	t.Run("Switching off accepts new secrets again", func(t *testing.T) {
+		setMaintenance(false)
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("The state is required", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/api/admin/maintenance", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.False(t, handler.Maintenance().Enabled())
+	})
+}
+
+func TestUniformNotFound(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	storeExpired := func() string {
+		expiresAt := time.Now().Add(-time.Minute)
+		secret := &models.Secret{
+			ID:        uuid.NewString(),
+			CreatedAt: time.Now().Add(-time.Hour),
+			ExpiresAt: &expiresAt,
+		}
+		assert.NoError(t, handler.fileStore.Store(secret))
+		return secret.ID
+	}
+	view := func(id string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	}
+
+	t.Run("Disabled reports expiry", func(t *testing.T) {
+		w := view(storeExpired())
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Equal(t, api.CodeExpired, decodeError(t, w).Code)
+	})
+
+	handler.config.Security.UniformNotFound = true
+
+	t.Run("Expired and missing are indistinguishable", func(t *testing.T) {
+		id := storeExpired()
+		expired := view(id)
+		missing := view(uuid.NewString())
+
+		assert.Equal(t, http.StatusNotFound, expired.Code)
+		assert.Equal(t, missing.Code, expired.Code)
+		assert.Equal(t, missing.Body.String(), expired.Body.String())
+
+		// The expired secret is still cleaned up
+		stored, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("Status does not reveal expiry", func(t *testing.T) {
+		get := func(id string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/api/secrets/"+id+"/status", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		expired := get(storeExpired())
+		missing := get(uuid.NewString())
+
+		assert.Equal(t, http.StatusOK, expired.Code)
+		assert.Equal(t, missing.Code, expired.Code)
+		assert.Equal(t, missing.Body.String(), expired.Body.String())
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	router, _, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	var output bytes.Buffer
+	auditLogger, err := logger.NewLogger(&logger.Config{
+		Enabled: true,
+		Stdout:  true,
+		Output:  &output,
+		Files: map[string]logger.FileConfig{
+			"audit": {Filename: "audit.log"},
+		},
+	}, true)
+	assert.NoError(t, err)
+	defer logger.SetDefault(logger.SetDefault(auditLogger))
+
+	content := testEncryptedContent()
+	maxViews := 1
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: content,
+		MaxViews:         &maxViews,
+		CaptchaToken:     "valid-token",
+	})
+
+	w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var events []string
+	for _, line := range bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n")) {
+		var entry logger.LogEntry
+		assert.NoError(t, json.Unmarshal(line, &entry))
+		assert.Equal(t, "audit", entry.Type)
+		events = append(events, entry.Data.(map[string]interface{})["event"].(string))
+	}
+	assert.Equal(t, []string{"create", "view", "burn"}, events)
+
+	// Neither identifiers nor any part of the secret appear in the audit log
+	for _, plaintext := range []string{id, content.Encrypted, content.Salt, content.IV, testServerKey} {
+		assert.NotContains(t, output.String(), plaintext)
+	}
+}
+
+func TestIDSchemes(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	createAndView := func(t *testing.T) string {
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     "valid-token",
+		})
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		return id
+	}
+
+	t.Run("UUID scheme", func(t *testing.T) {
+		id := createAndView(t)
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err)
+
+		w := postJSON(t, router, "/api/secrets/abcdefghijklmnopq", APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, api.CodeInvalidID, decodeError(t, w).Code)
+	})
+
+	t.Run("Base62 scheme", func(t *testing.T) {
+		handler.config.Secrets.IDScheme = "base62"
+		handler.config.Secrets.IDBytes = 9
+		defer func() { handler.config.Secrets.IDScheme = "" }()
+
+		id := createAndView(t)
+		assert.Len(t, id, 13)
+		assert.Regexp(t, `^[0-9a-zA-Z]+$`, id)
+
+		// Wrong length or characters are rejected before any lookup
+		for _, invalid := range []string{"abc", "abcdefghijklmn", "abcdefghijkl!"} {
+			w := postJSON(t, router, "/api/secrets/"+invalid, APIViewSecretRequest{CaptchaToken: "valid-token"})
+			assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+		}
+
+		// Well-formed IDs that don't exist are looked up
+		w := postJSON(t, router, "/api/secrets/0000000000000", APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		// UUIDs from before the switch remain valid
+		w = postJSON(t, router, "/api/secrets/"+uuid.NewString(), APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestGetSecretWithToken(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	router.GET("/api/secrets/:id", middleware.RequireToken("automation-token"), handler.GetSecretWithToken)
+
+	get := func(id string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/secrets/"+id, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	maxViews := 1
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		MaxViews:         &maxViews,
+		CaptchaToken:     "valid-token",
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		w := get(id, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, api.CodeUnauthorized, decodeError(t, w).Code)
+	})
+
+	t.Run("Token GET returns content and burns", func(t *testing.T) {
+		w := get(id, "automation-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response APISecretContentResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, testEncryptedContent(), response.EncryptedContent)
+		assert.True(t, response.IsBurnAfterReading)
+
+		w = get(id, "automation-token")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestReadinessWaitsForCleanup(t *testing.T) {
+	router, handler, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	router.GET("/readyz", NewHealthAPIHandler(handler.fileStore, nil).Ready)
+	readiness := func() APIReadinessResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		var response APIReadinessResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		if response.Status == "ok" {
+			assert.Equal(t, http.StatusOK, w.Code)
+		} else {
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		}
+		return response
+	}
+
+	response := readiness()
+	assert.Equal(t, "unavailable", response.Status)
+	assert.Equal(t, "pending", response.Checks["cleanup"])
+
+	// A failed scan doesn't count
+	storagePath := handler.config.Secrets.StoragePath
+	assert.NoError(t, os.Rename(storagePath, storagePath+".moved"))
+	assert.Error(t, handler.fileStore.CleanExpired())
+	assert.NoError(t, os.Rename(storagePath+".moved", storagePath))
+	assert.Equal(t, "pending", readiness().Checks["cleanup"])
+
+	assert.NoError(t, handler.fileStore.CleanExpired())
+	response = readiness()
+	assert.Equal(t, "ok", response.Status)
+	assert.Equal(t, "ok", response.Checks["cleanup"])
+}
+
+func TestRedisHealth(t *testing.T) {
+	router, handler, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	port, _ := strconv.Atoi(mr.Port())
+	redisStore, err := redis.NewRedisStore(mr.Host(), port, "", "", 0)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer redisStore.Close()
+
+	router.GET("/readyz", NewHealthAPIHandler(handler.fileStore, redisStore).Ready)
+	router.GET("/admin/stats", NewAdminAPIHandler(handler.fileStore, redisStore, handler.encryptor, handler.Maintenance(), handler.config).GetStats)
+	assert.NoError(t, handler.fileStore.CleanExpired())
+
+	check := func() (int, APIReadinessResponse, APIStatsResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		var readiness APIReadinessResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &readiness))
+
+		statsRecorder := httptest.NewRecorder()
+		router.ServeHTTP(statsRecorder, httptest.NewRequest("GET", "/admin/stats", nil))
+		var stats APIStatsResponse
+		assert.NoError(t, json.Unmarshal(statsRecorder.Body.Bytes(), &stats))
+		return w.Code, readiness, stats
+	}
+
+	code, readiness, stats := check()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", readiness.Status)
+	assert.Equal(t, "ok", readiness.Checks["redis"])
+	assert.Equal(t, "ok", stats.Redis)
+
+	// Losing Redis degrades the service without taking it out of rotation
+	mr.SetError("server unavailable")
+	assert.Error(t, redisStore.CheckHealth(context.Background()))
+	code, readiness, stats = check()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", readiness.Status)
+	assert.Equal(t, "down", readiness.Checks["redis"])
+	assert.Equal(t, "down", stats.Redis)
+
+	mr.SetError("")
+	assert.NoError(t, redisStore.CheckHealth(context.Background()))
+	code, readiness, stats = check()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", readiness.Status)
+	assert.Equal(t, "ok", stats.Redis)
+}
+
+func TestUnwritableStorage(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+	router.GET("/readyz", NewHealthAPIHandler(handler.fileStore, nil).Ready)
+
+	readiness := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w
+	}
+	assert.NoError(t, handler.fileStore.CleanExpired())
+	assert.Equal(t, http.StatusOK, readiness().Code)
+
+	// Simulate the storage directory disappearing from under the server
+	assert.NoError(t, os.RemoveAll(handler.config.Secrets.StoragePath))
+
+	t.Run("Write failure returns a specific error", func(t *testing.T) {
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, api.CodeStorageUnavailable, decodeError(t, w).Code)
+	})
+
+	t.Run("Readiness reports the storage", func(t *testing.T) {
+		w := readiness()
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+		var response APIReadinessResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "unwritable", response.Checks["storage"])
+	})
+
+	t.Run("Recovers after a successful probe", func(t *testing.T) {
+		assert.NoError(t, os.MkdirAll(handler.config.Secrets.StoragePath, 0750))
+		assert.NoError(t, handler.fileStore.ProbeWritable())
+		assert.Equal(t, http.StatusOK, readiness().Code)
+
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestImportSecretsErrors(t *testing.T) {
+	router, handler, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	router.POST("/api/admin/import", NewAdminAPIHandler(handler.fileStore, nil, handler.encryptor, handler.Maintenance(), handler.config).ImportSecrets)
+	importArchive := func(body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/import", bytes.NewReader(body)))
+		return w
+	}
+
+	exported := &models.Secret{ID: "exported-secret", CreatedAt: time.Now(), EncryptedData: []byte("ciphertext")}
+	assert.NoError(t, handler.fileStore.Store(exported))
+	var archive bytes.Buffer
+	_, err := handler.fileStore.Export(&archive)
+	assert.NoError(t, err)
+	assert.NoError(t, handler.fileStore.Delete(exported.ID))
+	assert.NoError(t, handler.fileStore.Store(&models.Secret{ID: "existing-secret", CreatedAt: time.Now()}))
+
+	t.Run("Invalid archive", func(t *testing.T) {
+		w := importArchive([]byte("not an archive"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, api.CodeInvalidData, decodeError(t, w).Code)
+	})
+
+	t.Run("Entry over max_import_entry_bytes", func(t *testing.T) {
+		handler.config.Secrets.MaxImportEntryBytes = 16
+		defer func() { handler.config.Secrets.MaxImportEntryBytes = 0 }()
+
+		w := importArchive(archive.Bytes())
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, api.CodeInvalidData, decodeError(t, w).Code)
+		assert.Equal(t, 1, handler.fileStore.Count())
+	})
+
+	t.Run("Storage at max_total", func(t *testing.T) {
+		handler.config.Secrets.MaxTotal = 1
+		defer func() { handler.config.Secrets.MaxTotal = 0 }()
+
+		w := importArchive(archive.Bytes())
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, api.CodeStorageFull, decodeError(t, w).Code)
+		assert.Equal(t, 1, handler.fileStore.Count())
+	})
+
+	t.Run("Storage unwritable", func(t *testing.T) {
+		assert.NoError(t, os.RemoveAll(handler.config.Secrets.StoragePath))
+		assert.Error(t, handler.fileStore.ProbeWritable())
+
+		w := importArchive(archive.Bytes())
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, api.CodeStorageUnavailable, decodeError(t, w).Code)
+	})
+}
+
+func TestEncryptedContentValidation(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	valid := testEncryptedContent()
+	testCases := []struct {
+		name    string
+		content models.EncryptedContent
+		field   string
+		message string
+	}{
+		{
+			name:    "Non-base64 ciphertext",
+			content: models.EncryptedContent{Encrypted: "not base64!", Salt: valid.Salt, IV: valid.IV},
+			field:   "encryptedContent.encrypted",
+			message: "must be valid base64",
+		},
+		{
+			name:    "Non-base64 salt",
+			content: models.EncryptedContent{Encrypted: valid.Encrypted, Salt: "%%%%", IV: valid.IV},
+			field:   "encryptedContent.salt",
+			message: "must be valid base64",
+		},
+		{
+			name:    "IV of the wrong length",
+			content: models.EncryptedContent{Encrypted: valid.Encrypted, Salt: valid.Salt, IV: base64.StdEncoding.EncodeToString([]byte("short-iv"))},
+			field:   "encryptedContent.iv",
+			message: "must decode to 12 bytes",
+		},
+		{
+			name:    "Ciphertext too short",
+			content: models.EncryptedContent{Encrypted: base64.StdEncoding.EncodeToString([]byte("tiny")), Salt: valid.Salt, IV: valid.IV},
+			field:   "encryptedContent.encrypted",
+			message: "must decode to at least 16 bytes",
+		},
+		{
+			name:    "Delimiter in ciphertext",
+			content: models.EncryptedContent{Encrypted: valid.Encrypted + "." + valid.Encrypted, Salt: valid.Salt, IV: valid.IV},
+			field:   "encryptedContent.encrypted",
+			message: `must not contain "."`,
+		},
+		{
+			name:    "Delimiter in salt",
+			content: models.EncryptedContent{Encrypted: valid.Encrypted, Salt: "." + valid.Salt, IV: valid.IV},
+			field:   "encryptedContent.salt",
+			message: `must not contain "."`,
+		},
+		{
+			name:    "Delimiter in IV",
+			content: models.EncryptedContent{Encrypted: valid.Encrypted, Salt: valid.Salt, IV: valid.IV + "."},
+			field:   "encryptedContent.iv",
+			message: `must not contain "."`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+				EncryptedContent: tc.content,
+				CaptchaToken:     "valid-token",
+			})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			response := decodeError(t, w)
+			assert.Equal(t, api.CodeInvalidRequest, response.Code)
+			assert.Equal(t, []api.FieldError{{Field: tc.field, Message: tc.message}}, response.Fields)
+		})
+	}
+
+	// Nothing was stored for any rejected request
+	assert.Equal(t, 0, handler.fileStore.Count())
+}
+
+// fakeClock is a Clock that only moves when the test advances it
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestClockExpiry(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	handler.clock = clock
+	created := clock.now
+
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		ExpiresIn:        "10m",
+		CaptchaToken:     "valid-token",
+	})
+	secret, err := handler.fileStore.Get(id)
+	assert.NoError(t, err)
+	assert.True(t, secret.CreatedAt.Equal(created))
+	assert.True(t, secret.ExpiresAt.Equal(created.Add(10*time.Minute)))
+
+	getStatus := func() APISecretStatusResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/secrets/"+id+"/status", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response APISecretStatusResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	// Still viewable at the exact expiry time
+	clock.now = created.Add(10 * time.Minute)
+	assert.True(t, getStatus().Viewable)
+
+	clock.now = clock.now.Add(time.Nanosecond)
+	response := getStatus()
+	assert.False(t, response.Viewable)
+	assert.True(t, response.Expired)
+}
+
+func TestHardMaxAge(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	handler.clock = clock
+	handler.config.Secrets.HardMaxAge = time.Hour
+
+	tests := []struct {
+		expiresIn  string
+		burn       bool
+		wantExpiry time.Duration
+	}{
+		{"10m", false, 10 * time.Minute},
+		{"1h", false, time.Hour},
+		{"1d", false, time.Hour},
+		{"7d", false, time.Hour},
+		{"burn", true, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expiresIn, func(t *testing.T) {
+			req := APICreateSecretRequest{
+				EncryptedContent: testEncryptedContent(),
+				CaptchaToken:     "valid-token",
+			}
+			if tt.burn {
+				oneView := 1
+				req.MaxViews = &oneView
+			} else {
+				req.ExpiresIn = tt.expiresIn
+			}
+			id := createTestSecret(t, router, req)
+			secret, err := handler.fileStore.Get(id)
+			assert.NoError(t, err)
+			if assert.NotNil(t, secret.ExpiresAt) {
+				assert.True(t, secret.ExpiresAt.Equal(clock.now.Add(tt.wantExpiry)), "expires at %s", secret.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestDecryptionFailure(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	storeSecret := func(data []byte) string {
+		expiresAt := time.Now().Add(time.Hour)
+		secret := &models.Secret{
+			ID:            uuid.NewString(),
+			CreatedAt:     time.Now(),
+			ExpiresAt:     &expiresAt,
+			EncryptedData: []byte(handler.ciphertextEncoding().EncodeToString(data)),
+		}
+		assert.NoError(t, handler.fileStore.Store(secret))
+		return secret.ID
+	}
+
+	t.Run("Data encrypted under a different key", func(t *testing.T) {
+		encrypted, err := encryption.NewEncryptor("a-different-server-key").Encrypt([]byte("a.b.c"), "")
+		assert.NoError(t, err)
+		id := storeSecret(encrypted)
+		before := handler.encryptor.DecryptFailures()
+
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, api.CodeDecryptionFailed, decodeError(t, w).Code)
+		assert.Equal(t, before+1, handler.encryptor.DecryptFailures())
+
+		// The failed view isn't counted
+		secret, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, secret.ViewCount)
+	})
+
+	t.Run("Decrypted data in an invalid format", func(t *testing.T) {
+		encrypted, err := handler.encryptor.Encrypt([]byte("not-three-parts"), "")
+		assert.NoError(t, err)
+		id := storeSecret(encrypted)
+		before := handler.encryptor.DecryptFailures()
+
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, api.CodeInvalidData, decodeError(t, w).Code)
+		assert.Equal(t, before, handler.encryptor.DecryptFailures())
+	})
+
+	t.Run("Failures are reported in admin stats", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/stats", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var stats APIStatsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		assert.Equal(t, int64(1), stats.DecryptionFailures)
+	})
+}
+
+func TestSecretAgeStats(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	// A secret read two hours after it was created
+	encrypted, err := handler.encryptor.Encrypt([]byte("a.b.c"), "")
+	assert.NoError(t, err)
+	expiresAt := time.Now().Add(time.Hour)
+	secret := &models.Secret{
+		ID:            uuid.NewString(),
+		CreatedAt:     time.Now().Add(-2 * time.Hour),
+		ExpiresAt:     &expiresAt,
+		EncryptedData: []byte(handler.ciphertextEncoding().EncodeToString(encrypted)),
+	}
+	assert.NoError(t, handler.fileStore.Store(secret))
+
+	w := postJSON(t, router, "/api/secrets/"+secret.ID, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/stats", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stats APIStatsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	ages := stats.SecretAgeAtViewSeconds
+	assert.Equal(t, int64(1), ages.Count)
+	assert.GreaterOrEqual(t, ages.Sum, float64(7200))
+	for _, bucket := range ages.Buckets {
+		if bucket.LE < 7200 {
+			assert.Equal(t, int64(0), bucket.Count, "bucket le=%v", bucket.LE)
+		} else {
+			assert.Equal(t, int64(1), bucket.Count, "bucket le=%v", bucket.LE)
+		}
+	}
+	assert.Equal(t, int64(0), stats.SecretAgeAtExpirySeconds.Count)
+
+	// The same histogram is exposed to Prometheus
+	router.GET("/api/admin/metrics", NewAdminAPIHandler(handler.fileStore, nil, handler.encryptor, handler.Maintenance(), handler.config).GetMetrics)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+
+	metrics := w.Body.String()
+	assert.Contains(t, metrics, "# TYPE secret_age_at_view_seconds histogram\n")
+	assert.Contains(t, metrics, "secret_age_at_view_seconds_bucket{le=\"3600\"} 0\n")
+	assert.Contains(t, metrics, "secret_age_at_view_seconds_bucket{le=\"21600\"} 1\n")
+	assert.Contains(t, metrics, "secret_age_at_view_seconds_bucket{le=\"+Inf\"} 1\n")
+	assert.Contains(t, metrics, "secret_age_at_view_seconds_count 1\n")
+	assert.Contains(t, metrics, "secret_age_at_expiry_seconds_count 0\n")
+}
+
+func TestCleanupMetrics(t *testing.T) {
+	router, handler, _, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	router.GET("/api/admin/metrics", NewAdminAPIHandler(handler.fileStore, nil, handler.encryptor, handler.Maintenance(), handler.config).GetMetrics)
+
+	expiredAt := time.Now().Add(-time.Hour)
+	assert.NoError(t, handler.fileStore.Store(&models.Secret{ID: uuid.NewString(), CreatedAt: time.Now().Add(-2 * time.Hour), ExpiresAt: &expiredAt}))
+	assert.NoError(t, handler.fileStore.CleanExpired())
+	assert.NoError(t, handler.fileStore.CleanExpired())
+
+	// Stats and metrics both report the totals over both runs
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/stats", nil))
+	var stats APIStatsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 0, stats.Cleanup.SecretsCleaned)
+	assert.Equal(t, 1, stats.Cleanup.TotalCleaned)
+	assert.Equal(t, 2, stats.Cleanup.TotalRuns)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	metrics := w.Body.String()
+	assert.Contains(t, metrics, "# TYPE secrets_cleaned_total counter\nsecrets_cleaned_total 1\n")
+	assert.Contains(t, metrics, "cleanup_runs_total 2\n")
+	assert.Contains(t, metrics, "cleanup_errors_total 0\n")
+	assert.Contains(t, metrics, "secret_age_at_expiry_seconds_count 1\n")
+}
+
+func TestFailedNameLookupLimit(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	port, _ := strconv.Atoi(mr.Port())
+	redisStore, err := redis.NewRedisStore(mr.Host(), port, "", "", 0)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer redisStore.Close()
+
+	handler.redisStore = redisStore
+	handler.config.RateLimit.Enabled = true
+	handler.config.RateLimit.NameLookupFailures = config.RouteRateLimit{RequestsPerHour: 10, RequestsPerMinute: 2}
+
+	view := func(path string) int {
+		return postJSON(t, router, path, APIViewSecretRequest{CaptchaToken: "valid-token"}).Code
+	}
+
+	// Unknown names count as failures until the limit is reached
+	assert.Equal(t, http.StatusNotFound, view("/api/secrets/name/invoice"))
+	assert.Equal(t, http.StatusNotFound, view("/api/secrets/name/password"))
+	assert.Equal(t, http.StatusTooManyRequests, view("/api/secrets/name/backup"))
+
+	// ID lookups have their own limits
+	assert.Equal(t, http.StatusNotFound, view("/api/secrets/"+uuid.NewString()))
+
+	// The minute window resets
+	mr.FastForward(time.Minute)
+	assert.Equal(t, http.StatusNotFound, view("/api/secrets/name/backup"))
+}
+
+func TestIDShapedCustomName(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	create := func(name string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CustomName:       name,
+			CaptchaToken:     "valid-token",
+		})
+	}
+
+	t.Run("UUID-shaped names are rejected", func(t *testing.T) {
+		for _, name := range []string{uuid.NewString(), strings.ToUpper(uuid.NewString())} {
+			w := create(name)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Equal(t, api.CodeInvalidName, decodeError(t, w).Code)
+		}
+	})
+
+	t.Run("Names shaped like base62 IDs are rejected", func(t *testing.T) {
+		handler.config.Secrets.IDScheme = "base62"
+		handler.config.Secrets.IDBytes = 9
+		defer func() { handler.config.Secrets.IDScheme = "" }()
+
+		w := create("abcdefghijklm")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		response := decodeError(t, w)
+		assert.Equal(t, api.CodeInvalidName, response.Code)
+		assert.Contains(t, response.Error, "secret ID")
+
+		// Other lengths can't be mistaken for an ID
+		assert.Equal(t, http.StatusOK, create("abcdefghijkl").Code)
+	})
+
+	t.Run("Normal names still work", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, create("invoice").Code)
+
+		// The same name is fine under the UUID scheme
+		assert.Equal(t, http.StatusOK, create("abcdefghijklm").Code)
+	})
+
+	t.Run("ID-shaped generated names are skipped", func(t *testing.T) {
+		handler.config.Secrets.IDScheme = "base62"
+		handler.config.Secrets.IDBytes = 9
+		handler.config.Secrets.Autoname = config.AutonameConfig{Enabled: true, Length: 13, MaxAttempts: 3}
+		defer func() { handler.config.Secrets.IDScheme = "" }()
+
+		names := []string{"nopqrstuvwxyz", "generated"}
+		handler.generateName = func(int) (string, error) {
+			name := names[0]
+			names = names[1:]
+			return name, nil
+		}
+
+		w := postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			GenerateName:     true,
+			CaptchaToken:     "valid-token",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response APISecretResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "generated", response.Name)
+	})
+}
+
+func TestConstantTimeLookups(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	const min = 50 * time.Millisecond
+	router.POST("/padded/:id", middleware.PadResponseTime(min), handler.GetSecret)
+
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		CaptchaToken:     "valid-token",
+	})
+	expiredAt := time.Now().Add(-time.Minute)
+	expired := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now(), ExpiresAt: &expiredAt, EncryptedData: []byte("data")}
+	assert.NoError(t, handler.fileStore.Store(expired))
+
+	for _, tc := range []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"Found", id, http.StatusOK},
+		{"Expired", expired.ID, http.StatusGone},
+		{"Missing", uuid.NewString(), http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			w := postJSON(t, router, "/padded/"+tc.id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+			assert.GreaterOrEqual(t, time.Since(start), min)
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
+
+func TestCreateResponseExpiry(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	handler.clock = clock
+
+	create := func(req APICreateSecretRequest) APISecretResponse {
+		req.EncryptedContent = testEncryptedContent()
+		req.CaptchaToken = "valid-token"
+		w := postJSON(t, router, "/api/secrets", req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response APISecretResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	for _, duration := range []time.Duration{10 * time.Minute, 30 * time.Minute, time.Hour, 24 * time.Hour, 7 * 24 * time.Hour} {
+		t.Run(duration.String(), func(t *testing.T) {
+			// A client clock slightly ahead is normalized to the exact duration
+			requested := clock.now.Add(duration + 800*time.Millisecond)
+			response := create(APICreateSecretRequest{ExpiresAt: &requested})
+
+			expected := clock.now.Add(duration)
+			if assert.NotNil(t, response.ExpiresAt) {
+				assert.True(t, response.ExpiresAt.Equal(expected), "expected %v, got %v", expected, response.ExpiresAt)
+			}
+			assert.False(t, response.IsBurnAfterReading)
+
+			stored, err := handler.fileStore.Get(response.ID)
+			if assert.NoError(t, err) && assert.NotNil(t, response.ExpiresAt) {
+				assert.True(t, stored.ExpiresAt.Equal(*response.ExpiresAt))
+			}
+		})
+	}
+
+	t.Run("Default expiry", func(t *testing.T) {
+		response := create(APICreateSecretRequest{})
+		if assert.NotNil(t, response.ExpiresAt) {
+			assert.True(t, response.ExpiresAt.Equal(clock.now.Add(10*time.Minute)))
+		}
+	})
+
+	t.Run("Burn after reading", func(t *testing.T) {
+		maxViews := 1
+		response := create(APICreateSecretRequest{MaxViews: &maxViews})
+		assert.Nil(t, response.ExpiresAt)
+		assert.True(t, response.IsBurnAfterReading)
+	})
+}
+
+func TestCustomNamePattern(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	create := func(name string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CustomName:       name,
+			CaptchaToken:     "valid-token",
+		})
+	}
+	view := func(name string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets/name/"+name, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	}
+
+	t.Run("Default rejects dashes and underscores", func(t *testing.T) {
+		for _, name := range []string{"q3-invoice", "q3_invoice"} {
+			w := create(name)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Equal(t, api.CodeInvalidName, decodeError(t, w).Code)
+
+			w = view(name)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Equal(t, api.CodeInvalidName, decodeError(t, w).Code)
+		}
+	})
+
+	t.Run("Slug preset accepts dashes and underscores", func(t *testing.T) {
+		pattern, err := models.ParseNamePattern(models.NamePatternSlug)
+		assert.NoError(t, err)
+		handler.namePattern = pattern
+		defer func() { handler.namePattern = models.DefaultNamePattern() }()
+
+		for _, name := range []string{"q3-invoice", "q3_invoice", "plain"} {
+			assert.Equal(t, http.StatusOK, create(name).Code, name)
+			assert.Equal(t, http.StatusOK, view(name).Code, name)
+		}
+
+		// Separators must sit between letters or numbers
+		for _, name := range []string{"-invoice", "q3--invoice", "invoice_", "q3.invoice"} {
+			w := create(name)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Equal(t, api.CodeInvalidName, decodeError(t, w).Code)
+		}
+	})
+
+	t.Run("Custom regular expressions match the whole name", func(t *testing.T) {
+		pattern, err := models.ParseNamePattern("[a-z]{3,8}")
+		assert.NoError(t, err)
+		assert.True(t, pattern.MatchString("invoice"))
+		assert.False(t, pattern.MatchString("invoice1"))
+		assert.False(t, pattern.MatchString("Invoice"))
+
+		_, err = models.ParseNamePattern("[a-z")
+		assert.Error(t, err)
+	})
+}
+
+func TestContentFormat(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	storeSecret := func(plaintext []byte) string {
+		encrypted, err := handler.encryptor.Encrypt(plaintext, "")
+		assert.NoError(t, err)
+		expiresAt := time.Now().Add(time.Hour)
+		secret := &models.Secret{
+			ID:            uuid.NewString(),
+			CreatedAt:     time.Now(),
+			ExpiresAt:     &expiresAt,
+			EncryptedData: []byte(handler.ciphertextEncoding().EncodeToString(encrypted)),
+		}
+		assert.NoError(t, handler.fileStore.Store(secret))
+		return secret.ID
+	}
+	view := func(id string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	}
+
+	t.Run("New secrets use the structured format", func(t *testing.T) {
+		content := testEncryptedContent()
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: content,
+			CaptchaToken:     "valid-token",
+		})
+
+		secret, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		encrypted, err := handler.ciphertextEncoding().DecodeString(string(secret.EncryptedData))
+		assert.NoError(t, err)
+		plaintext, err := handler.encryptor.Decrypt(encrypted, "")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x00, 0x01}, plaintext[:2])
+		assert.NotContains(t, string(plaintext), ".")
+
+		var decoded models.EncryptedContent
+		assert.NoError(t, decoded.UnmarshalBinary(plaintext))
+		assert.Equal(t, content, decoded)
+	})
+
+	t.Run("Legacy dot-joined secrets still read", func(t *testing.T) {
+		content := testEncryptedContent()
+		id := storeSecret([]byte(content.Encrypted + "." + content.Salt + "." + content.IV))
+
+		w := view(id)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response APISecretContentResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, content, response.EncryptedContent)
+	})
+
+	t.Run("Malformed structured data is rejected", func(t *testing.T) {
+		valid, err := testEncryptedContent().MarshalBinary()
+		assert.NoError(t, err)
+
+		for name, data := range map[string][]byte{
+			"Unknown version":  {0x00, 0x02, 0x00, 0x00, 0x00},
+			"Truncated":        valid[:len(valid)-1],
+			"Trailing data":    append(append([]byte{}, valid...), 'x'),
+			"Oversized length": {0x00, 0x01, 0xff, 0x01},
+		} {
+			w := view(storeSecret(data))
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Equal(t, api.CodeInvalidData, decodeError(t, w).Code, name)
+		}
+	})
+}
+
+func TestExpiredRetention(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	handler.clock = clock
+	store, err := file.NewFileStore(handler.config.Secrets.StoragePath, file.WithExpiredRetention(time.Hour), file.WithClock(clock))
+	assert.NoError(t, err)
+	handler.fileStore = store
+
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		ExpiresIn:        "10m",
+		CaptchaToken:     "valid-token",
+	})
+
+	// Inside the window the secret stays on disk but is never served
+	clock.now = clock.now.Add(30 * time.Minute)
+	for i := 0; i < 2; i++ {
+		w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Equal(t, api.CodeExpired, decodeError(t, w).Code)
+		assert.NotContains(t, w.Body.String(), testEncryptedContent().Encrypted)
+	}
+	assert.NoError(t, store.CleanExpired())
+	secret, err := store.Get(id)
+	assert.NoError(t, err)
+	assert.NotNil(t, secret)
+
+	// After the window a read removes it like any expired secret
+	clock.now = clock.now.Add(time.Hour)
+	w := postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	assert.Equal(t, http.StatusGone, w.Code)
+	secret, err = store.Get(id)
+	assert.NoError(t, err)
+	assert.Nil(t, secret)
+}
+
+func TestExpiryGrace(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	handler.clock = clock
+	handler.config.Secrets.ExpiryGraceSeconds = 5
+	defer func() { handler.config.Secrets.ExpiryGraceSeconds = 0 }()
+
+	createExpiring := func() string {
+		clock.now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		maxViews := 3
+		return createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ExpiresIn:        "10m",
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+	}
+	view := func(id string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	}
+
+	t.Run("Served once inside the grace window", func(t *testing.T) {
+		id := createExpiring()
+		clock.now = clock.now.Add(10*time.Minute + 3*time.Second)
+
+		w := view(id)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The grace read used up the secret despite its remaining views
+		secret, err := handler.fileStore.Get(id)
+		assert.NoError(t, err)
+		assert.Nil(t, secret)
+		assert.Equal(t, http.StatusNotFound, view(id).Code)
+	})
+
+	t.Run("Rejected outside the grace window", func(t *testing.T) {
+		id := createExpiring()
+		clock.now = clock.now.Add(10*time.Minute + 6*time.Second)
+
+		w := view(id)
+		assert.Equal(t, http.StatusGone, w.Code)
+		assert.Equal(t, api.CodeExpired, decodeError(t, w).Code)
+	})
+
+	t.Run("Reads before expiry are unaffected", func(t *testing.T) {
+		id := createExpiring()
+		clock.now = clock.now.Add(9 * time.Minute)
+
+		assert.Equal(t, http.StatusOK, view(id).Code)
+		assert.Equal(t, http.StatusOK, view(id).Code)
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		handler.config.Secrets.ExpiryGraceSeconds = 0
+		id := createExpiring()
+		clock.now = clock.now.Add(10*time.Minute + time.Second)
+
+		assert.Equal(t, http.StatusGone, view(id).Code)
+	})
+}
+
+func TestExposeCreatedAt(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	handler.clock = clock
+
+	check := func(t *testing.T, want *time.Time) {
+		maxViews := 2
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			ExpiresIn:        "10m",
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/secrets/"+id+"/status", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var status map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+
+		w = postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var content map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &content))
+
+		for _, response := range []map[string]interface{}{status, content} {
+			if want == nil {
+				assert.NotContains(t, response, "createdAt")
+			} else {
+				assert.Equal(t, want.Format(time.RFC3339), response["createdAt"])
+			}
+		}
+	}
+
+	t.Run("Omitted by default", func(t *testing.T) {
+		check(t, nil)
+	})
+
+	t.Run("Included when enabled", func(t *testing.T) {
+		handler.config.Secrets.ExposeCreatedAt = true
+		defer func() { handler.config.Secrets.ExposeCreatedAt = false }()
+
+		created := clock.now
+		check(t, &created)
+	})
+}
+
+func TestCaptchaActions(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	// Tokens are named after the action they were minted for
+	for _, action := range []string{"create_secret", "view_secret"} {
+		mockTurnstileClient.On("Verify", action, mock.Anything).Return(&captcha.TurnstileResponse{Success: true, Action: action}, nil)
+	}
+	mockTurnstileClient.On("Verify", "valid-token", mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	maxViews := 5
+	id := createTestSecret(t, router, APICreateSecretRequest{
+		EncryptedContent: testEncryptedContent(),
+		MaxViews:         &maxViews,
+		CaptchaToken:     "valid-token",
+	})
+
+	handler.config.Security.Captcha.CreateAction = "create_secret"
+	handler.config.Security.Captcha.ViewAction = "view_secret"
+	defer func() {
+		handler.config.Security.Captcha.CreateAction = ""
+		handler.config.Security.Captcha.ViewAction = ""
+	}()
+
+	create := func(token string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets", APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			CaptchaToken:     token,
+		})
+	}
+	view := func(token string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: token})
+	}
+
+	t.Run("Matching actions are accepted", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, create("create_secret").Code)
+		assert.Equal(t, http.StatusOK, view("view_secret").Code)
+	})
+
+	t.Run("Tokens for another action are rejected", func(t *testing.T) {
+		for _, w := range []*httptest.ResponseRecorder{create("view_secret"), view("create_secret"), create("valid-token")} {
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, api.CodeCaptchaInvalid, decodeError(t, w).Code)
+		}
+	})
+}
+
+func TestDecryptCache(t *testing.T) {
+	router, handler, mockTurnstileClient, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	mockTurnstileClient.On("Verify", mock.Anything, mock.Anything).Return(&captcha.TurnstileResponse{Success: true}, nil)
+
+	handler.decryptCache = newDecryptCache(10)
+	view := func(id string) *httptest.ResponseRecorder {
+		return postJSON(t, router, "/api/secrets/"+id, APIViewSecretRequest{CaptchaToken: "valid-token"})
+	}
+
+	t.Run("Repeated reads of a multi-view secret hit the cache", func(t *testing.T) {
+		maxViews := 3
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		hitsBefore, missesBefore := handler.decryptCache.stats()
+		for i := 0; i < maxViews; i++ {
+			w := view(id)
+			if assert.Equal(t, http.StatusOK, w.Code) {
+				var response APISecretContentResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, testEncryptedContent(), response.EncryptedContent)
+				assert.Equal(t, i+1, response.ViewCount)
+			}
+		}
+		hits, misses := handler.decryptCache.stats()
+		assert.Equal(t, int64(maxViews-1), hits-hitsBefore)
+		assert.Equal(t, int64(1), misses-missesBefore)
+
+		// The burned secret's content is dropped from memory
+		_, ok := handler.decryptCache.get(id)
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusNotFound, view(id).Code)
+	})
+
+	t.Run("Burn-after-reading secrets bypass the cache", func(t *testing.T) {
+		maxViews := 1
+		id := createTestSecret(t, router, APICreateSecretRequest{
+			EncryptedContent: testEncryptedContent(),
+			MaxViews:         &maxViews,
+			CaptchaToken:     "valid-token",
+		})
+
+		assert.Equal(t, http.StatusOK, view(id).Code)
+		handler.decryptCache.mu.Lock()
+		_, cached := handler.decryptCache.entries[id]
+		handler.decryptCache.mu.Unlock()
+		assert.False(t, cached)
+	})
+
+	t.Run("Least recently used entries are evicted", func(t *testing.T) {
+		cache := newDecryptCache(2)
+		now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		cache.now = func() time.Time { return now }
+
+		cache.put("a", models.EncryptedContent{Encrypted: "a"})
+		cache.put("b", models.EncryptedContent{Encrypted: "b"})
+		cache.get("a")
+		cache.put("c", models.EncryptedContent{Encrypted: "c"})
+
+		_, okA := cache.get("a")
+		_, okB := cache.get("b")
+		_, okC := cache.get("c")
+		assert.True(t, okA)
+		assert.False(t, okB, "least recently used entry should be evicted")
+		assert.True(t, okC)
+
+		// Entries expire after the TTL
+		now = now.Add(decryptCacheTTL)
+		_, okA = cache.get("a")
+		assert.False(t, okA)
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		assert.Nil(t, newDecryptCache(0))
+	})
+}
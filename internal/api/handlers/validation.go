@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/config"
+	"secrets-share/internal/models"
+)
+
+func init() {
+	// Report validation errors using the JSON field names clients send
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// respondBindingError reports which request fields are missing or malformed
+func respondBindingError(c *gin.Context, err error) {
+	api.JSON(c, http.StatusBadRequest, api.APIError{
+		Error:  "Invalid request format",
+		Code:   api.CodeInvalidRequest,
+		Fields: bindingFieldErrors(err),
+	})
+}
+
+// bindingFieldErrors converts a binding error into field-level errors
+func bindingFieldErrors(err error) []api.FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]api.FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, api.FieldError{
+				Field:   fieldPath(fieldErr.Namespace()),
+				Message: validationMessage(fieldErr),
+			})
+		}
+		return fields
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []api.FieldError{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}}
+	}
+
+	return nil
+}
+
+// fieldPath strips the request struct name from a validator namespace
+// ("APICreateSecretRequest.encryptedContent.iv" becomes "encryptedContent.iv")
+func fieldPath(namespace string) string {
+	if _, path, found := strings.Cut(namespace, "."); found {
+		return path
+	}
+	return namespace
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	if fieldErr.Tag() == "required" {
+		return "is required"
+	}
+	return fmt.Sprintf("failed %q validation", fieldErr.Tag())
+}
+
+// maxViewsError checks that a requested view limit is at least 1 and within
+// the configured cap, so it can't be silently reinterpreted. Values that don't
+// fit an int are already rejected when the request is bound. A zero cap is not
+// enforced.
+func maxViewsError(maxViews *int, limit int) *api.FieldError {
+	switch {
+	case maxViews == nil:
+		return nil
+	case *maxViews <= 0:
+		return &api.FieldError{Field: "maxViews", Message: "must be at least 1"}
+	case limit > 0 && *maxViews > limit:
+		return &api.FieldError{Field: "maxViews", Message: fmt.Sprintf("exceeds the maximum of %d views", limit)}
+	}
+	return nil
+}
+
+// encryptedContentErrors checks that the client-side encrypted parts are valid
+// base64 of plausible sizes, so garbage is rejected before it is stored. Dots
+// get their own message since they delimited parts in the legacy storage
+// format. Size limits that are zero in the config are not enforced.
+func encryptedContentErrors(content models.EncryptedContent, cfg *config.SecretsConfig) []api.FieldError {
+	var fields []api.FieldError
+	check := func(field, value string, exact, min int) {
+		if strings.Contains(value, models.LegacyContentDelimiter) {
+			fields = append(fields, api.FieldError{Field: field, Message: fmt.Sprintf("must not contain %q", models.LegacyContentDelimiter)})
+			return
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		switch {
+		case err != nil:
+			fields = append(fields, api.FieldError{Field: field, Message: "must be valid base64"})
+		case exact > 0 && len(decoded) != exact:
+			fields = append(fields, api.FieldError{Field: field, Message: fmt.Sprintf("must decode to %d bytes", exact)})
+		case min > 0 && len(decoded) < min:
+			fields = append(fields, api.FieldError{Field: field, Message: fmt.Sprintf("must decode to at least %d bytes", min)})
+		}
+	}
+
+	check("encryptedContent.encrypted", content.Encrypted, 0, cfg.MinCiphertextBytes)
+	check("encryptedContent.salt", content.Salt, cfg.SaltBytes, 0)
+	check("encryptedContent.iv", content.IV, cfg.IVBytes, 0)
+	return fields
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// defaultViewTokenTTL is how long a served view token can be replayed when
+// secrets.view_token_ttl_sec is not set
+const defaultViewTokenTTL = time.Minute
+
+// viewTokenCache remembers the responses served for client-supplied view
+// tokens, so a read retried after a timeout gets the same content back without
+// spending another view
+type viewTokenCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]viewTokenEntry
+}
+
+type viewTokenEntry struct {
+	response  *APISecretContentResponse
+	expiresAt time.Time
+}
+
+func newViewTokenCache(ttl time.Duration) *viewTokenCache {
+	if ttl <= 0 {
+		ttl = defaultViewTokenTTL
+	}
+	return &viewTokenCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]viewTokenEntry),
+	}
+}
+
+// viewTokenKey identifies a read of the secret found by lookup ("id" or
+// "name") and value. The view passphrase is part of the key, so a replay needs
+// the same passphrase as the original read. It is empty when the client sent
+// no token.
+func viewTokenKey(lookup, value, token, passphrase string) string {
+	if token == "" {
+		return ""
+	}
+	key := lookup + ":" + value + ":" + token
+	if passphrase != "" {
+		sum := sha256.Sum256([]byte(passphrase))
+		key += ":" + hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+// get returns the response served earlier for key, if it is still fresh
+func (v *viewTokenCache) get(key string) *APISecretContentResponse {
+	if key == "" {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	entry, ok := v.entries[key]
+	if !ok || !v.now().Before(entry.expiresAt) {
+		return nil
+	}
+	return entry.response
+}
+
+// put records the response served for key, pruning expired entries
+func (v *viewTokenCache) put(key string, response *APISecretContentResponse) {
+	if key == "" {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	for cachedKey, cached := range v.entries {
+		if !now.Before(cached.expiresAt) {
+			delete(v.entries, cachedKey)
+		}
+	}
+	v.entries[key] = viewTokenEntry{response: response, expiresAt: now.Add(v.ttl)}
+}
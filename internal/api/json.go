@@ -0,0 +1,18 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// PrettyJSONKey is set on the request context when responses should be indented
+const PrettyJSONKey = "prettyJSON"
+
+// JSON writes obj as the response body, indented when the request was marked
+// for pretty output
+func JSON(c *gin.Context, status int, obj interface{}) {
+	if c.GetBool(PrettyJSONKey) {
+		c.IndentedJSON(status, obj)
+		return
+	}
+	c.JSON(status, obj)
+}
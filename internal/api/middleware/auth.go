@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+)
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header
+func BearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// TokenMatches compares a presented token against the expected one in constant time
+func TokenMatches(presented, expected string) bool {
+	if presented == "" || expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(expected)) == 1
+}
+
+// RequireToken rejects requests that don't carry the given bearer token. An
+// empty token disables the protected routes entirely.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			api.AbortWithError(c, http.StatusNotFound, api.CodeNotFound, "Not found")
+			return
+		}
+		if !TokenMatches(BearerToken(c), token) {
+			api.AbortWithError(c, http.StatusUnauthorized, api.CodeUnauthorized, "Unauthorized")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+)
+
+// MaxBodySize rejects request bodies larger than limit bytes with 413 before
+// the handler runs, so oversized payloads are never JSON-decoded. A
+// non-positive limit disables the check.
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			abortTooLarge(c)
+			return
+		}
+
+		// The declared length can be absent (chunked) or wrong, so read at most
+		// limit bytes and hand the buffered body to the handler
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abortTooLarge(c)
+				return
+			}
+			api.AbortWithError(c, http.StatusBadRequest, api.CodeInvalidRequest, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		c.Next()
+	}
+}
+
+func abortTooLarge(c *gin.Context) {
+	api.AbortWithError(c, http.StatusRequestEntityTooLarge, api.CodeRequestTooLarge, "Request body too large")
+}
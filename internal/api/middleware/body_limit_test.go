@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handlerCalled := false
+	router := gin.New()
+	router.POST("/secrets", MaxBodySize(64), func(c *gin.Context) {
+		handlerCalled = true
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	t.Run("Body within the limit reaches the handler", func(t *testing.T) {
+		handlerCalled = false
+		req := httptest.NewRequest("POST", "/secrets", strings.NewReader(`{"captchaToken":"token"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, handlerCalled)
+		assert.Equal(t, `{"captchaToken":"token"}`, w.Body.String())
+	})
+
+	t.Run("Oversized body is rejected", func(t *testing.T) {
+		handlerCalled = false
+		req := httptest.NewRequest("POST", "/secrets", strings.NewReader(strings.Repeat("a", 65)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.False(t, handlerCalled)
+	})
+
+	t.Run("Oversized body without a content length is rejected", func(t *testing.T) {
+		handlerCalled = false
+		req := httptest.NewRequest("POST", "/secrets", io.NopCloser(strings.NewReader(strings.Repeat("a", 1024))))
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.False(t, handlerCalled)
+	})
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/captcha"
+	"secrets-share/internal/logger"
+)
+
+// CaptchaVerifiedKey is set on the request context once RequireCaptcha has
+// verified the request's captcha token
+const CaptchaVerifiedKey = "captchaVerified"
+
+// CaptchaErrorStatus maps a verification error to a response status. Failing
+// fast on an open circuit or at the concurrency limit is reported as 503 so
+// clients know to retry later.
+func CaptchaErrorStatus(err error) int {
+	if errors.Is(err, captcha.ErrCircuitOpen) || errors.Is(err, captcha.ErrTooManyVerifications) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// LogCaptchaFailure records why a captcha was rejected so spam waves can be
+// told apart from other bad requests. The token itself is never logged.
+func LogCaptchaFailure(c *gin.Context, result *captcha.TurnstileResponse) {
+	logger.Info("Captcha verification failed", map[string]interface{}{
+		"event":       "captcha_failed",
+		"route":       c.FullPath(),
+		"ip":          c.ClientIP(),
+		"error_codes": result.ErrorCodes,
+		"hostname":    result.Hostname,
+		"action":      result.Action,
+	})
+}
+
+// RequireCaptcha verifies the captchaToken in a JSON request body before the
+// handler runs, so failed captchas are rejected without further work. Tokens
+// must have been minted for action unless it is empty. Requests without a
+// token, with an unreadable body, or carrying apiToken are passed on for the
+// handler to deal with.
+func RequireCaptcha(verifier captcha.TurnstileVerifier, apiToken, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || TokenMatches(BearerToken(c), apiToken) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			api.AbortWithError(c, http.StatusBadRequest, api.CodeInvalidRequest, "Failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var req struct {
+			CaptchaToken string `json:"captchaToken"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil || req.CaptchaToken == "" {
+			c.Next()
+			return
+		}
+
+		result, err := verifier.Verify(req.CaptchaToken, c.ClientIP())
+		if err != nil {
+			api.AbortWithError(c, CaptchaErrorStatus(err), api.CodeCaptchaUnavailable, "Failed to verify captcha")
+			return
+		}
+		if !result.Success || !result.MatchesAction(action) {
+			LogCaptchaFailure(c, result)
+			api.AbortWithError(c, http.StatusBadRequest, api.CodeCaptchaInvalid, "Invalid captcha")
+			return
+		}
+
+		c.Set(CaptchaVerifiedKey, true)
+		c.Next()
+	}
+}
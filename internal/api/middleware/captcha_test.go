@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/captcha"
+	"secrets-share/internal/logger"
+)
+
+// stubVerifier accepts only tokens starting with "valid" and counts upstream calls
+type stubVerifier struct {
+	calls int
+}
+
+func (v *stubVerifier) Verify(token string, remoteIP string) (*captcha.TurnstileResponse, error) {
+	v.calls++
+	return &captcha.TurnstileResponse{Success: strings.HasPrefix(token, "valid")}, nil
+}
+
+func TestRequireCaptcha(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := &stubVerifier{}
+	verifier := captcha.NewCachingVerifier(upstream, time.Minute)
+
+	var handlerCalls int
+	var handlerBody string
+	var verified bool
+	router := gin.New()
+	router.POST("/secrets/:id", RequireCaptcha(verifier, "api-token", ""), func(c *gin.Context) {
+		handlerCalls++
+		body, _ := io.ReadAll(c.Request.Body)
+		handlerBody = string(body)
+		verified = c.GetBool(CaptchaVerifiedKey)
+		c.Status(http.StatusOK)
+	})
+
+	send := func(body string, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/secrets/abc", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Failed captcha short-circuits", func(t *testing.T) {
+		handlerCalls = 0
+		w := send(`{"captchaToken":"bad-token"}`, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "CAPTCHA_INVALID")
+		assert.Equal(t, 0, handlerCalls)
+	})
+
+	t.Run("Valid captcha reaches the handler with the body intact", func(t *testing.T) {
+		body := `{"captchaToken":"valid-token"}`
+		w := send(body, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, body, handlerBody)
+		assert.True(t, verified)
+	})
+
+	t.Run("Repeated token within the TTL verifies upstream once", func(t *testing.T) {
+		upstream.calls = 0
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, send(`{"captchaToken":"valid-retry-token"}`, "").Code)
+		}
+		assert.Equal(t, 1, upstream.calls)
+	})
+
+	t.Run("Missing token and API token are left to the handler", func(t *testing.T) {
+		upstream.calls = 0
+
+		w := send(`{}`, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, verified)
+
+		w = send(`{"captchaToken":"bad-token"}`, "api-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, verified)
+
+		assert.Equal(t, 0, upstream.calls)
+	})
+}
+
+func TestCaptchaErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusServiceUnavailable, CaptchaErrorStatus(captcha.ErrCircuitOpen))
+	assert.Equal(t, http.StatusServiceUnavailable, CaptchaErrorStatus(fmt.Errorf("verify: %w", captcha.ErrCircuitOpen)))
+	assert.Equal(t, http.StatusServiceUnavailable, CaptchaErrorStatus(captcha.ErrTooManyVerifications))
+	assert.Equal(t, http.StatusInternalServerError, CaptchaErrorStatus(&captcha.UpstreamError{StatusCode: 502}))
+}
+
+// rejectingVerifier fails every token with the given Turnstile error codes
+type rejectingVerifier struct {
+	errorCodes []string
+}
+
+func (v rejectingVerifier) Verify(token string, remoteIP string) (*captcha.TurnstileResponse, error) {
+	return &captcha.TurnstileResponse{Success: false, ErrorCodes: v.errorCodes, Hostname: "example.com"}, nil
+}
+
+func TestCaptchaFailureLogging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var output bytes.Buffer
+	testLogger, err := logger.NewLogger(&logger.Config{
+		Enabled: true,
+		Stdout:  true,
+		Output:  &output,
+		Files: map[string]logger.FileConfig{
+			"application": {Filename: "app.log"},
+		},
+	}, true)
+	assert.NoError(t, err)
+	defer logger.SetDefault(logger.SetDefault(testLogger))
+
+	verifier := rejectingVerifier{errorCodes: []string{"timeout-or-duplicate"}}
+	router := gin.New()
+	router.POST("/secrets/:id", RequireCaptcha(verifier, "", ""), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("POST", "/secrets/abc", strings.NewReader(`{"captchaToken":"secret-token-value"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var entry struct {
+		Message string `json:"message"`
+		Data    struct {
+			Event      string   `json:"event"`
+			Route      string   `json:"route"`
+			ErrorCodes []string `json:"error_codes"`
+			Hostname   string   `json:"hostname"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "captcha_failed", entry.Data.Event)
+	assert.Equal(t, "/secrets/:id", entry.Data.Route)
+	assert.Equal(t, []string{"timeout-or-duplicate"}, entry.Data.ErrorCodes)
+	assert.Equal(t, "example.com", entry.Data.Hostname)
+
+	assert.NotContains(t, output.String(), "secret-token-value")
+}
+
+// actionVerifier accepts every token, reporting the token itself as its action
+type actionVerifier struct{}
+
+func (actionVerifier) Verify(token string, remoteIP string) (*captcha.TurnstileResponse, error) {
+	return &captcha.TurnstileResponse{Success: true, Action: token}, nil
+}
+
+func TestRequireCaptchaAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.POST("/secrets", RequireCaptcha(actionVerifier{}, "", "create_secret"), ok)
+	router.POST("/secrets/:id", RequireCaptcha(actionVerifier{}, "", "view_secret"), ok)
+	router.POST("/any", RequireCaptcha(actionVerifier{}, "", ""), ok)
+
+	send := func(path, action string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"captchaToken":"`+action+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("/secrets", "create_secret"))
+	assert.Equal(t, http.StatusBadRequest, send("/secrets", "view_secret"))
+	assert.Equal(t, http.StatusOK, send("/secrets/abc", "view_secret"))
+	assert.Equal(t, http.StatusBadRequest, send("/secrets/abc", "create_secret"))
+	assert.Equal(t, http.StatusOK, send("/any", "view_secret"))
+}
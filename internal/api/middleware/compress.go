@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bufferedWriter holds the response so it can be compressed once its final
+// size is known. Responses that are already compressed, such as the admin
+// export, switch it to passthrough so they stream without being buffered.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	passthrough bool
+}
+
+// alreadyCompressed reports whether response headers mark a body that
+// compressing again would only make larger
+func alreadyCompressed(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return true
+	}
+	switch strings.TrimSpace(strings.Split(header.Get("Content-Type"), ";")[0]) {
+	case "application/gzip", "application/x-gzip", "application/zip":
+		return true
+	}
+	return false
+}
+
+// checkPassthrough switches to writing straight through once the headers show
+// the response is already compressed
+func (w *bufferedWriter) checkPassthrough() {
+	if w.passthrough || !alreadyCompressed(w.Header()) {
+		return
+	}
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.Status())
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+	w.checkPassthrough()
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.checkPassthrough()
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.checkPassthrough()
+	if w.passthrough {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	if w.passthrough {
+		return true
+	}
+	return w.status != 0 || w.body.Len() > 0
+}
+
+// Compress gzip- or deflate-encodes response bodies of at least minSize bytes
+// for clients that advertise support via Accept-Encoding
+func Compress(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The body depends on Accept-Encoding even when it ends up sent
+		// uncompressed, so caches must never reuse it across encodings
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original}
+		c.Writer = buffered
+		defer func() {
+			c.Writer = original
+		}()
+
+		c.Next()
+		if buffered.passthrough {
+			return
+		}
+
+		body := buffered.body.Bytes()
+		header := original.Header()
+		if len(body) < minSize || header.Get("Content-Encoding") != "" {
+			original.WriteHeader(buffered.Status())
+			original.Write(body)
+			return
+		}
+
+		var compressed bytes.Buffer
+		if err := compress(&compressed, encoding, body); err != nil {
+			original.WriteHeader(buffered.Status())
+			original.Write(body)
+			return
+		}
+
+		header.Set("Content-Encoding", encoding)
+		header.Del("Content-Length")
+		original.WriteHeader(buffered.Status())
+		original.Write(compressed.Bytes())
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip and honoring explicit q=0 refusals
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		refused := false
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if q, ok := strings.CutPrefix(param, "q="); ok {
+				if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+					refused = true
+				}
+			}
+		}
+		accepted[name] = !refused
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func compress(dst io.Writer, encoding string, body []byte) error {
+	var writer io.WriteCloser
+	var err error
+	if encoding == "gzip" {
+		writer = gzip.NewWriter(dst)
+	} else {
+		writer, err = flate.NewWriter(dst, flate.DefaultCompression)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	largeBody := strings.Repeat("encrypted-secret-content ", 200)
+	router := gin.New()
+	router.Use(Compress(1024))
+	router.GET("/large", func(c *gin.Context) {
+		c.String(http.StatusCreated, largeBody)
+	})
+	router.GET("/small", func(c *gin.Context) {
+		c.String(http.StatusOK, "tiny")
+	})
+
+	request := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Large response is gzip encoded", func(t *testing.T) {
+		w := request("/large", "gzip, deflate, br")
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Less(t, w.Body.Len(), len(largeBody))
+
+		reader, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		decoded, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, largeBody, string(decoded))
+	})
+
+	t.Run("Deflate is used when gzip is not accepted", func(t *testing.T) {
+		w := request("/large", "gzip;q=0, deflate")
+
+		assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+		decoded, err := io.ReadAll(flate.NewReader(w.Body))
+		assert.NoError(t, err)
+		assert.Equal(t, largeBody, string(decoded))
+	})
+
+	t.Run("Response is left alone without Accept-Encoding", func(t *testing.T) {
+		w := request("/large", "")
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, largeBody, w.Body.String())
+	})
+
+	t.Run("Already compressed response streams unchanged", func(t *testing.T) {
+		archive := []byte(largeBody)
+		recorder := httptest.NewRecorder()
+		var streamed bool
+		router.GET("/export", func(c *gin.Context) {
+			c.Header("Content-Type", "application/gzip")
+			c.Status(http.StatusOK)
+			c.Writer.Write(archive[:512])
+			// The first chunk reaches the client before the handler finishes
+			streamed = recorder.Body.Len() == 512
+			c.Writer.Write(archive[512:])
+		})
+
+		req := httptest.NewRequest("GET", "/export", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		router.ServeHTTP(recorder, req)
+
+		assert.True(t, streamed, "response should not be buffered")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+		assert.Equal(t, archive, recorder.Body.Bytes())
+	})
+
+	t.Run("Small response is not compressed", func(t *testing.T) {
+		w := request("/small", "gzip")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, "tiny", w.Body.String())
+	})
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+// LimitConcurrency caps the number of in-flight requests per client IP,
+// rejecting requests beyond the cap with 429. Each IP gets a counting
+// semaphore that is released when its request completes. A non-positive
+// limit disables the check.
+func LimitConcurrency(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	var mu sync.Mutex
+	inFlight := make(map[string]int)
+
+	release := func(ip string) {
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight[ip]--
+		if inFlight[ip] <= 0 {
+			delete(inFlight, ip)
+		}
+	}
+
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+
+		mu.Lock()
+		if inFlight[ip] >= limit {
+			mu.Unlock()
+			logger.RateLimit("Concurrent request limit exceeded", map[string]interface{}{
+				"route": c.FullPath(),
+				"ip":    ip,
+				"limit": limit,
+			})
+			api.AbortWithError(c, http.StatusTooManyRequests, api.CodeTooManyRequests, "Too many concurrent requests")
+			return
+		}
+		inFlight[ip]++
+		mu.Unlock()
+
+		defer release(ip)
+		c.Next()
+	}
+}
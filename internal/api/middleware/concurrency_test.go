@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const limit = 3
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	router := gin.New()
+	router.Use(LimitConcurrency(limit))
+	router.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	results := make(chan *httptest.ResponseRecorder, limit+1)
+	for i := 0; i < limit+1; i++ {
+		go func() {
+			results <- request("/slow", "192.0.2.1:1234")
+		}()
+	}
+
+	// Exactly limit requests reach the handler and the extra one is rejected
+	timeout := time.After(5 * time.Second)
+	startedCount := 0
+	var rejected *httptest.ResponseRecorder
+	for startedCount < limit || rejected == nil {
+		select {
+		case <-started:
+			startedCount++
+		case w := <-results:
+			if rejected != nil {
+				t.Fatal("More than one request finished early")
+			}
+			rejected = w
+		case <-timeout:
+			t.Fatalf("Timed out with %d requests started", startedCount)
+		}
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	var body api.APIError
+	assert.NoError(t, json.Unmarshal(rejected.Body.Bytes(), &body))
+	assert.Equal(t, api.CodeTooManyRequests, body.Code)
+
+	// Other clients are unaffected while the first one is at its cap
+	assert.Equal(t, http.StatusOK, request("/fast", "192.0.2.2:1234").Code)
+
+	close(release)
+	for i := 0; i < limit; i++ {
+		assert.Equal(t, http.StatusOK, (<-results).Code)
+	}
+
+	// Slots are released once requests complete
+	assert.Equal(t, http.StatusOK, request("/fast", "192.0.2.1:1234").Code)
+}
+
+func TestLimitConcurrencyDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/", LimitConcurrency(0), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+)
+
+// RequireJSON rejects request bodies that aren't declared as application/json
+// with 415, so clients sending form data get a clear error instead of a
+// confusing bind failure. Parameters such as charset are ignored and requests
+// without a body are passed through.
+func RequireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			api.AbortWithError(c, http.StatusUnsupportedMediaType, api.CodeUnsupportedMediaType, "Content-Type must be application/json")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+)
+
+func TestRequireJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handlerCalled := false
+	router := gin.New()
+	router.POST("/secrets", RequireJSON(), func(c *gin.Context) {
+		handlerCalled = true
+		c.Status(http.StatusOK)
+	})
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		handlerCalled = false
+		req := httptest.NewRequest("POST", "/secrets", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("JSON is accepted", func(t *testing.T) {
+		for _, contentType := range []string{"application/json", "application/json; charset=utf-8", "Application/JSON"} {
+			w := post(contentType, `{"captchaToken":"token"}`)
+			assert.Equal(t, http.StatusOK, w.Code, contentType)
+			assert.True(t, handlerCalled, contentType)
+		}
+	})
+
+	rejected := map[string]string{
+		"Plain text":   "text/plain",
+		"Form encoded": "application/x-www-form-urlencoded",
+		"Missing":      "",
+		"Malformed":    "application/json; charset",
+	}
+	for name, contentType := range rejected {
+		t.Run(name+" content type is rejected", func(t *testing.T) {
+			w := post(contentType, `captchaToken=token`)
+			assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+			assert.False(t, handlerCalled)
+
+			var response api.APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, api.CodeUnsupportedMediaType, response.Code)
+		})
+	}
+
+	t.Run("Empty body is passed through", func(t *testing.T) {
+		w := post("", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, handlerCalled)
+	})
+}
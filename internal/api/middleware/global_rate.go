@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+// LimitGlobalRate caps the request rate of the whole process with a token
+// bucket holding up to one second's worth of requests, rejecting requests
+// beyond it with 503. Unlike the per-IP limits it also holds against floods
+// spread over many addresses. A non-positive rate disables the check.
+func LimitGlobalRate(perSecond int) gin.HandlerFunc {
+	return limitGlobalRate(perSecond, time.Now)
+}
+
+func limitGlobalRate(perSecond int, now func() time.Time) gin.HandlerFunc {
+	if perSecond <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	var mu sync.Mutex
+	capacity := float64(perSecond)
+	tokens := capacity
+	last := now()
+
+	take := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		current := now()
+		if elapsed := current.Sub(last).Seconds(); elapsed > 0 {
+			tokens = min(capacity, tokens+elapsed*capacity)
+		}
+		last = current
+
+		if tokens < 1 {
+			return false
+		}
+		tokens--
+		return true
+	}
+
+	return func(c *gin.Context) {
+		if !take() {
+			logger.RateLimit("Global request rate exceeded", map[string]interface{}{
+				"route": c.FullPath(),
+				"ip":    c.ClientIP(),
+				"limit": perSecond,
+			})
+			c.Header("Retry-After", "1")
+			api.AbortWithError(c, http.StatusServiceUnavailable, api.CodeServerBusy, "Server is busy. Please try again later.")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+)
+
+func TestLimitGlobalRate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const perSecond = 5
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	router := gin.New()
+	router.Use(limitGlobalRate(perSecond, func() time.Time { return now }))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// Every request comes from a different IP, so only the global cap applies
+	next := 0
+	burst := func(n int) (ok, busy int) {
+		for i := 0; i < n; i++ {
+			next++
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", next%250+1)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			switch w.Code {
+			case http.StatusOK:
+				ok++
+			case http.StatusServiceUnavailable:
+				busy++
+				assert.Equal(t, "1", w.Header().Get("Retry-After"))
+				var response api.APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, api.CodeServerBusy, response.Code)
+			default:
+				t.Fatalf("Unexpected status %d", w.Code)
+			}
+		}
+		return ok, busy
+	}
+
+	ok, busy := burst(20)
+	assert.Equal(t, perSecond, ok)
+	assert.Equal(t, 20-perSecond, busy)
+
+	// Tokens refill with time, but never beyond one second's worth
+	now = now.Add(200 * time.Millisecond)
+	ok, _ = burst(5)
+	assert.Equal(t, 1, ok)
+
+	now = now.Add(time.Minute)
+	ok, _ = burst(20)
+	assert.Equal(t, perSecond, ok)
+}
+
+func TestLimitGlobalRateDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(LimitGlobalRate(0))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+// ParsePrefixes parses a list of CIDR ranges. Bare addresses are accepted as
+// single-host ranges.
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP range %q: %w", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q: %w", value, err)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// FilterIPs rejects clients outside the allowlist or inside the denylist with
+// 403. The denylist wins when a client matches both, and an empty allowlist
+// allows everyone not denied.
+func FilterIPs(allowlist, denylist []netip.Prefix) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(allowlist) == 0 && len(denylist) == 0 {
+			c.Next()
+			return
+		}
+
+		ip := c.ClientIP()
+		addr, err := netip.ParseAddr(ip)
+		allowed := err == nil &&
+			!containsAddr(denylist, addr.Unmap()) &&
+			(len(allowlist) == 0 || containsAddr(allowlist, addr.Unmap()))
+		if !allowed {
+			logger.Access("Client IP not allowed", map[string]interface{}{
+				"ip":    ip,
+				"route": c.FullPath(),
+			})
+			api.AbortWithError(c, http.StatusForbidden, api.CodeForbidden, "Access denied")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
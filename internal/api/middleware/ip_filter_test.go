@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterIPs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	list := func(values ...string) []string { return values }
+	newRouter := func(allow, deny []string) *gin.Engine {
+		allowlist, err := ParsePrefixes(allow)
+		assert.NoError(t, err)
+		denylist, err := ParsePrefixes(deny)
+		assert.NoError(t, err)
+
+		router := gin.New()
+		router.Use(FilterIPs(allowlist, denylist))
+		router.GET("/", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+
+	tests := []struct {
+		name       string
+		allow      []string
+		deny       []string
+		remoteAddr string
+		wantStatus int
+	}{
+		{"No lists", nil, nil, "203.0.113.7:1234", http.StatusOK},
+		{"Allowlist match", list("10.0.0.0/8"), nil, "10.1.2.3:1234", http.StatusOK},
+		{"Allowlist miss", list("10.0.0.0/8"), nil, "203.0.113.7:1234", http.StatusForbidden},
+		{"Allowlist single address", list("203.0.113.7"), nil, "203.0.113.7:1234", http.StatusOK},
+		{"Allowlist IPv6", list("2001:db8::/32"), nil, "[2001:db8::1]:1234", http.StatusOK},
+		{"Denylist match", nil, list("203.0.113.0/24"), "203.0.113.7:1234", http.StatusForbidden},
+		{"Denylist miss", nil, list("203.0.113.0/24"), "198.51.100.1:1234", http.StatusOK},
+		{"Deny wins over allow", list("10.0.0.0/8"), list("10.0.0.0/16"), "10.0.5.5:1234", http.StatusForbidden},
+		{"Allowed outside denied subrange", list("10.0.0.0/8"), list("10.0.0.0/16"), "10.1.5.5:1234", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+
+			newRouter(tt.allow, tt.deny).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "FORBIDDEN")
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"192.168.1.7/24", " 10.0.0.1 ", "::1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.0/24", prefixes[0].String())
+	assert.Equal(t, "10.0.0.1/32", prefixes[1].String())
+	assert.Equal(t, "::1/128", prefixes[2].String())
+
+	_, err = ParsePrefixes([]string{"not-an-ip"})
+	assert.Error(t, err)
+	_, err = ParsePrefixes([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
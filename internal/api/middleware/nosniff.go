@@ -0,0 +1,13 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// NoSniff sets X-Content-Type-Options: nosniff on every response, so browsers
+// use the declared Content-Type instead of guessing one from content that may
+// be attacker-influenced, e.g. rendering it as HTML
+func NoSniff() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+)
+
+func TestNoSniff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NoSniff())
+	router.GET("/ok", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/plain", []byte("<html><script>alert(1)</script></html>"))
+	})
+	router.GET("/error", func(c *gin.Context) {
+		api.AbortWithError(c, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid request")
+	})
+
+	// Successful, aborted and unmatched requests all carry the header
+	for path, status := range map[string]int{
+		"/ok":      http.StatusOK,
+		"/error":   http.StatusBadRequest,
+		"/missing": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, status, w.Code, path)
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"), path)
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+)
+
+// PrettyJSON marks requests so API responses are written as indented JSON,
+// which is easier to read from a terminal during development
+func PrettyJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(api.PrettyJSONKey, true)
+		c.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(pretty bool) *gin.Engine {
+		router := gin.New()
+		if pretty {
+			router.Use(PrettyJSON())
+		}
+		router.GET("/ok", func(c *gin.Context) {
+			api.JSON(c, http.StatusOK, gin.H{"status": "ok"})
+		})
+		router.GET("/error", func(c *gin.Context) {
+			api.AbortWithError(c, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid request format")
+		})
+		return router
+	}
+
+	get := func(router *gin.Engine, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	for _, path := range []string{"/ok", "/error"} {
+		t.Run("Pretty "+path, func(t *testing.T) {
+			w := get(newRouter(true), path)
+			assert.Contains(t, w.Body.String(), "\n    \"")
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		})
+
+		t.Run("Compact "+path, func(t *testing.T) {
+			w := get(newRouter(false), path)
+			assert.False(t, strings.Contains(w.Body.String(), "\n"), "unexpected indentation in %q", w.Body.String())
+		})
+	}
+}
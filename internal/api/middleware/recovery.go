@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+// requestIDHeader carries a request ID set by a proxy in front of the server
+const requestIDHeader = "X-Request-ID"
+
+// Recover turns a panic in a later handler into a 500 error response. Unlike
+// gin.Recovery, the panic and its stack trace go to the error log, so they
+// are kept with the other structured entries.
+func Recover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				// Deliberate aborts are left to net/http, which expects them
+				panic(recovered)
+			}
+
+			data := map[string]interface{}{
+				"panic":  fmt.Sprint(recovered),
+				"stack":  string(debug.Stack()),
+				"method": c.Request.Method,
+				"route":  c.FullPath(),
+			}
+			if requestID := c.GetHeader(requestIDHeader); requestID != "" {
+				data["request_id"] = requestID
+			}
+			logger.Error("Recovered from panic", data)
+
+			if c.Writer.Written() {
+				// Part of a response was already sent and can't be replaced
+				c.Abort()
+				return
+			}
+			api.AbortWithError(c, http.StatusInternalServerError, api.CodeInternal, "Internal server error")
+		}()
+
+		c.Next()
+	}
+}
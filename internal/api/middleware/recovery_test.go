@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/logger"
+)
+
+func TestRecover(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var output bytes.Buffer
+	testLogger, err := logger.NewLogger(&logger.Config{
+		Enabled: true,
+		Stdout:  true,
+		Output:  &output,
+		Files: map[string]logger.FileConfig{
+			"error": {Filename: "error.log"},
+		},
+	}, true)
+	assert.NoError(t, err)
+	defer logger.SetDefault(logger.SetDefault(testLogger))
+
+	router := gin.New()
+	router.Use(Recover())
+	router.GET("/secrets/:id", func(c *gin.Context) {
+		panic("something broke")
+	})
+	router.GET("/ok", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	t.Run("Panics become a 500 and an error log entry", func(t *testing.T) {
+		output.Reset()
+		req := httptest.NewRequest("GET", "/secrets/abc", nil)
+		req.Header.Set("X-Request-ID", "req-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		var response api.APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, api.CodeInternal, response.Code)
+		assert.NotContains(t, w.Body.String(), "something broke")
+
+		var entry struct {
+			Level   string `json:"level"`
+			Type    string `json:"type"`
+			Message string `json:"message"`
+			Data    struct {
+				Panic     string `json:"panic"`
+				Stack     string `json:"stack"`
+				Route     string `json:"route"`
+				RequestID string `json:"request_id"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(output.String())), &entry))
+		assert.Equal(t, "error", entry.Type)
+		assert.Equal(t, "ERROR", entry.Level)
+		assert.Equal(t, "something broke", entry.Data.Panic)
+		assert.Contains(t, entry.Data.Stack, "recovery_test.go")
+		assert.Equal(t, "/secrets/:id", entry.Data.Route)
+		assert.Equal(t, "req-123", entry.Data.RequestID)
+	})
+
+	t.Run("Requests without a panic are untouched", func(t *testing.T) {
+		output.Reset()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/ok", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, output.String())
+	})
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// paddedWriter holds back the response until a deadline, so how long a
+// request took doesn't reveal which path the handler went down
+type paddedWriter struct {
+	gin.ResponseWriter
+	deadline time.Time
+	waited   bool
+}
+
+func (w *paddedWriter) wait() {
+	if !w.waited {
+		w.waited = true
+		time.Sleep(time.Until(w.deadline))
+	}
+}
+
+func (w *paddedWriter) WriteHeader(code int) {
+	w.wait()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *paddedWriter) WriteHeaderNow() {
+	w.wait()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *paddedWriter) Write(data []byte) (int, error) {
+	w.wait()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *paddedWriter) WriteString(s string) (int, error) {
+	w.wait()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// PadResponseTime delays responses until at least min has passed since the
+// request arrived, so found, expired and missing secrets answer in the same
+// time. Responses that take longer are not delayed. A non-positive min
+// disables padding.
+func PadResponseTime(min time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if min <= 0 {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		padded := &paddedWriter{ResponseWriter: original, deadline: time.Now().Add(min)}
+		c.Writer = padded
+		defer func() {
+			c.Writer = original
+		}()
+
+		c.Next()
+
+		// Cover handlers that finished without writing anything
+		padded.wait()
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPadResponseTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const min = 50 * time.Millisecond
+	router := gin.New()
+	padding := PadResponseTime(min)
+	router.GET("/found", padding, func(c *gin.Context) {
+		time.Sleep(10 * time.Millisecond) // Stands in for reading and decrypting
+		c.JSON(http.StatusOK, gin.H{"secret": "content"})
+	})
+	router.GET("/missing", padding, func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Secret not found"})
+	})
+	router.GET("/empty", padding, func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/slow", padding, func(c *gin.Context) {
+		time.Sleep(2 * min)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/unpadded", PadResponseTime(0), func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	get := func(path string) (*httptest.ResponseRecorder, time.Duration) {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w, time.Since(start)
+	}
+
+	t.Run("Found and not-found take at least the minimum", func(t *testing.T) {
+		for _, path := range []string{"/found", "/missing", "/empty"} {
+			w, elapsed := get(path)
+			assert.GreaterOrEqual(t, elapsed, min, path)
+			assert.NotEqual(t, 0, w.Code, path)
+		}
+
+		w, _ := get("/missing")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"error":"Secret not found"}`, w.Body.String())
+	})
+
+	t.Run("Slow responses are not delayed further", func(t *testing.T) {
+		_, elapsed := get("/slow")
+		assert.Less(t, elapsed, 3*min)
+	})
+
+	t.Run("Disabled without a minimum", func(t *testing.T) {
+		_, elapsed := get("/unpadded")
+		assert.Less(t, elapsed, min)
+	})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeaders sets the given response headers on every response, skipping
+// those with an empty value. Strict-Transport-Security is added with a
+// positive hstsMaxAge, but only on requests that arrived over TLS, either
+// directly or through a TLS-terminating proxy that sets X-Forwarded-Proto,
+// since browsers ignore it over plain HTTP. X-Forwarded-Proto is only honored
+// from peers in trustedProxies.
+func SecurityHeaders(headers map[string]string, hstsMaxAge time.Duration, trustedProxies []netip.Prefix) gin.HandlerFunc {
+	set := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if value != "" {
+			set[name] = value
+		}
+	}
+
+	var hsts string
+	if hstsMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(hstsMaxAge/time.Second))
+	}
+
+	return func(c *gin.Context) {
+		for name, value := range set {
+			c.Header(name, value)
+		}
+		if hsts != "" && isTLS(c, trustedProxies) {
+			c.Header("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// isTLS reports whether the client connected over TLS, believing
+// X-Forwarded-Proto only when the direct peer is a trusted proxy
+func isTLS(c *gin.Context, trustedProxies []netip.Prefix) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	if !strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		return false
+	}
+	peer, err := netip.ParseAddr(c.RemoteIP())
+	return err == nil && containsAddr(trustedProxies, peer.Unmap())
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(SecurityHeaders(map[string]string{
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "no-referrer",
+		"Content-Security-Policy": "default-src 'none'",
+		"Permissions-Policy":      "",
+	}, 365*24*time.Hour, []netip.Prefix{netip.MustParsePrefix("10.0.0.1/32")}))
+	router.GET("/ok", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	get := func(path string, modify func(*http.Request)) http.Header {
+		req := httptest.NewRequest("GET", path, nil)
+		if modify != nil {
+			modify(req)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header()
+	}
+
+	t.Run("Headers are set on every response", func(t *testing.T) {
+		for _, path := range []string{"/ok", "/missing"} {
+			header := get(path, nil)
+			assert.Equal(t, "DENY", header.Get("X-Frame-Options"), path)
+			assert.Equal(t, "no-referrer", header.Get("Referrer-Policy"), path)
+			assert.Equal(t, "default-src 'none'", header.Get("Content-Security-Policy"), path)
+		}
+	})
+
+	t.Run("Empty values are skipped", func(t *testing.T) {
+		_, ok := get("/ok", nil)["Permissions-Policy"]
+		assert.False(t, ok)
+	})
+
+	t.Run("HSTS only over TLS", func(t *testing.T) {
+		assert.Empty(t, get("/ok", nil).Get("Strict-Transport-Security"))
+
+		direct := get("/ok", func(req *http.Request) { req.TLS = &tls.ConnectionState{} })
+		assert.Equal(t, "max-age=31536000", direct.Get("Strict-Transport-Security"))
+
+		proxied := get("/ok", func(req *http.Request) {
+			req.RemoteAddr = "10.0.0.1:4321"
+			req.Header.Set("X-Forwarded-Proto", "https")
+		})
+		assert.Equal(t, "max-age=31536000", proxied.Get("Strict-Transport-Security"))
+
+		plain := get("/ok", func(req *http.Request) {
+			req.RemoteAddr = "10.0.0.1:4321"
+			req.Header.Set("X-Forwarded-Proto", "http")
+		})
+		assert.Empty(t, plain.Get("Strict-Transport-Security"))
+
+		// Any client could send the header, so only trusted proxies are believed
+		spoofed := get("/ok", func(req *http.Request) { req.Header.Set("X-Forwarded-Proto", "https") })
+		assert.Empty(t, spoofed.Get("Strict-Transport-Security"))
+	})
+
+	t.Run("HSTS can be disabled", func(t *testing.T) {
+		router := gin.New()
+		router.Use(SecurityHeaders(nil, 0, nil))
+		router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req := httptest.NewRequest("GET", "/ok", nil)
+		req.TLS = &tls.ConnectionState{}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+	})
+}
@@ -0,0 +1,65 @@
+// Package background tracks goroutines that must finish before the server
+// shuts down.
+package background
+
+import (
+	"context"
+	"sync"
+)
+
+// Manager runs background tasks and waits for them to drain on shutdown
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	stopping bool
+	wg       sync.WaitGroup
+}
+
+// NewManager creates a manager whose tasks are cancelled when parent is done
+// or Shutdown is called
+func NewManager(parent context.Context) *Manager {
+	ctx, cancel := context.WithCancel(parent)
+	return &Manager{ctx: ctx, cancel: cancel}
+}
+
+// Go runs task in its own goroutine. The task should return promptly once its
+// context is cancelled. Tasks started after Shutdown are not run, and Go
+// reports whether the task was started.
+func (m *Manager) Go(task func(ctx context.Context)) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopping {
+		return false
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		task(m.ctx)
+	}()
+	return true
+}
+
+// Shutdown cancels the tasks' context and waits for them to return, giving up
+// when ctx is done
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopping = true
+	m.mu.Unlock()
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package background
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerDrainsTasks(t *testing.T) {
+	manager := NewManager(context.Background())
+
+	var finished atomic.Bool
+	started := make(chan struct{})
+	manager.Go(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		// Work done after cancellation, like a final cleanup pass
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, manager.Shutdown(ctx))
+	assert.True(t, finished.Load(), "Shutdown returned before the task finished")
+}
+
+func TestManagerShutdownTimeout(t *testing.T) {
+	manager := NewManager(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	manager.Go(func(ctx context.Context) {
+		<-release // Ignores cancellation
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, manager.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestManagerRejectsTasksAfterShutdown(t *testing.T) {
+	manager := NewManager(context.Background())
+	assert.NoError(t, manager.Shutdown(context.Background()))
+
+	ran := make(chan struct{}, 1)
+	assert.False(t, manager.Go(func(ctx context.Context) {
+		ran <- struct{}{}
+	}))
+
+	select {
+	case <-ran:
+		t.Error("Task started after shutdown")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestManagerCancelledWithParent(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	manager := NewManager(parent)
+
+	stopped := make(chan struct{})
+	manager.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Task was not cancelled with the parent context")
+	}
+	assert.NoError(t, manager.Shutdown(context.Background()))
+}
@@ -0,0 +1,96 @@
+package captcha
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"secrets-share/internal/logger"
+)
+
+// ErrCircuitOpen is returned while verification is skipped after repeated
+// upstream failures
+var ErrCircuitOpen = errors.New("captcha verification unavailable: circuit open")
+
+// CircuitBreaker stops calling a failing verification endpoint. After
+// threshold consecutive errors it fails fast for the cooldown (or longer if the
+// upstream sent Retry-After), then lets a single probe through to decide
+// whether to close again. Rejected tokens are not failures.
+type CircuitBreaker struct {
+	verifier  TurnstileVerifier
+	threshold int
+	cooldown  time.Duration
+	failOpen  bool
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time // Zero while the circuit is closed
+	probing   bool
+}
+
+// NewCircuitBreaker wraps verifier with a breaker. With failOpen, tokens are
+// accepted without verification while the circuit is open.
+func NewCircuitBreaker(verifier TurnstileVerifier, threshold int, cooldown time.Duration, failOpen bool) *CircuitBreaker {
+	return &CircuitBreaker{
+		verifier:  verifier,
+		threshold: threshold,
+		cooldown:  cooldown,
+		failOpen:  failOpen,
+		now:       time.Now,
+	}
+}
+
+// Verify passes the token upstream unless the circuit is open
+func (b *CircuitBreaker) Verify(token string, remoteIP string) (*TurnstileResponse, error) {
+	b.mu.Lock()
+	if !b.openUntil.IsZero() {
+		if b.now().Before(b.openUntil) || b.probing {
+			b.mu.Unlock()
+			return b.rejectOpen()
+		}
+		// Half-open: let this request probe the upstream
+		b.probing = true
+	}
+	b.mu.Unlock()
+
+	response, err := b.verifier.Verify(token, remoteIP)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := !b.openUntil.IsZero()
+	b.probing = false
+
+	if err == nil {
+		if wasOpen {
+			logger.Info("Captcha circuit closed", nil)
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return response, nil
+	}
+
+	b.failures++
+	if wasOpen || b.failures >= b.threshold {
+		cooldown := b.cooldown
+		var upstreamErr *UpstreamError
+		if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter > cooldown {
+			cooldown = upstreamErr.RetryAfter
+		}
+		b.openUntil = b.now().Add(cooldown)
+		logger.Warn("Captcha circuit opened", map[string]interface{}{
+			"failures":  b.failures,
+			"cooldown":  cooldown.String(),
+			"fail_open": b.failOpen,
+			"error":     err.Error(),
+		})
+	}
+	return nil, err
+}
+
+func (b *CircuitBreaker) rejectOpen() (*TurnstileResponse, error) {
+	if b.failOpen {
+		return &TurnstileResponse{Success: true, Unverified: true}, nil
+	}
+	return nil, ErrCircuitOpen
+}
@@ -0,0 +1,150 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(TurnstileResponse{Success: true})
+	}))
+	defer server.Close()
+
+	newBreaker := func(failOpen bool) (*CircuitBreaker, *time.Time) {
+		client := NewTurnstileClient("key")
+		client.verifyURL = server.URL
+		breaker := NewCircuitBreaker(client, 3, time.Minute, failOpen)
+		now := time.Now()
+		breaker.now = func() time.Time { return now }
+		return breaker, &now
+	}
+
+	t.Run("Trips after consecutive failures and fails fast", func(t *testing.T) {
+		failing.Store(true)
+		calls.Store(0)
+		breaker, _ := newBreaker(false)
+
+		for i := 0; i < 3; i++ {
+			_, err := breaker.Verify("token", "")
+			var upstreamErr *UpstreamError
+			assert.ErrorAs(t, err, &upstreamErr)
+		}
+		assert.Equal(t, int32(3), calls.Load())
+
+		_, err := breaker.Verify("token", "")
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, int32(3), calls.Load(), "open circuit must not call upstream")
+	})
+
+	t.Run("Half-open probe closes the circuit", func(t *testing.T) {
+		failing.Store(true)
+		breaker, now := newBreaker(false)
+		for i := 0; i < 3; i++ {
+			breaker.Verify("token", "")
+		}
+
+		failing.Store(false)
+		*now = now.Add(time.Minute + time.Second)
+		response, err := breaker.Verify("token", "")
+		assert.NoError(t, err)
+		assert.True(t, response.Success)
+
+		// Closed again: later calls reach upstream
+		calls.Store(0)
+		_, err = breaker.Verify("token", "")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Failed probe reopens immediately", func(t *testing.T) {
+		failing.Store(true)
+		breaker, now := newBreaker(false)
+		for i := 0; i < 3; i++ {
+			breaker.Verify("token", "")
+		}
+
+		*now = now.Add(time.Minute + time.Second)
+		_, err := breaker.Verify("token", "")
+		assert.False(t, errors.Is(err, ErrCircuitOpen), "probe should reach upstream")
+
+		_, err = breaker.Verify("token", "")
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+
+	t.Run("Successes reset the failure count", func(t *testing.T) {
+		breaker, _ := newBreaker(false)
+		for i := 0; i < 5; i++ {
+			failing.Store(true)
+			breaker.Verify("token", "")
+			breaker.Verify("token", "")
+			failing.Store(false)
+			_, err := breaker.Verify("token", "")
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("Fail open accepts tokens while open", func(t *testing.T) {
+		failing.Store(true)
+		breaker, _ := newBreaker(true)
+		for i := 0; i < 3; i++ {
+			breaker.Verify("token", "")
+		}
+
+		response, err := breaker.Verify("token", "")
+		assert.NoError(t, err)
+		assert.True(t, response.Success)
+
+		// Unverified results carry no action, so required actions can't reject them
+		assert.True(t, response.Unverified)
+		assert.True(t, response.MatchesAction("create_secret"))
+	})
+}
+
+func TestCircuitBreakerRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "300")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewTurnstileClient("key")
+	client.verifyURL = server.URL
+	breaker := NewCircuitBreaker(client, 1, time.Minute, false)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	_, err := breaker.Verify("token", "")
+	var upstreamErr *UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, 5*time.Minute, upstreamErr.RetryAfter)
+
+	// Still open after the configured cooldown because upstream asked for longer
+	now = now.Add(2 * time.Minute)
+	_, err = breaker.Verify("token", "")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
@@ -0,0 +1,74 @@
+package captcha
+
+import (
+	"sync"
+	"time"
+)
+
+// CachingVerifier remembers successful verifications by token and client IP
+// for a short time, so a retried request doesn't trigger a second upstream
+// verification. Binding entries to the IP keeps a solved token from being
+// replayed by other clients.
+type CachingVerifier struct {
+	verifier TurnstileVerifier
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cachedResult
+}
+
+type cacheKey struct {
+	token    string
+	remoteIP string
+}
+
+type cachedResult struct {
+	response  *TurnstileResponse
+	expiresAt time.Time
+}
+
+// NewCachingVerifier wraps verifier with a result cache of the given TTL
+func NewCachingVerifier(verifier TurnstileVerifier, ttl time.Duration) *CachingVerifier {
+	return &CachingVerifier{
+		verifier: verifier,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[cacheKey]cachedResult),
+	}
+}
+
+// Verify returns a cached result for the token and IP if it is still fresh,
+// and otherwise verifies it upstream. Only verified successes are cached:
+// failures, errors and results accepted unverified while failing open are
+// checked again on the next request.
+func (v *CachingVerifier) Verify(token string, remoteIP string) (*TurnstileResponse, error) {
+	key := cacheKey{token: token, remoteIP: remoteIP}
+
+	v.mu.Lock()
+	entry, ok := v.entries[key]
+	v.mu.Unlock()
+	if ok && v.now().Before(entry.expiresAt) {
+		return entry.response, nil
+	}
+
+	response, err := v.verifier.Verify(token, remoteIP)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Success || response.Unverified {
+		return response, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	for cachedKey, cached := range v.entries {
+		if !now.Before(cached.expiresAt) {
+			delete(v.entries, cachedKey)
+		}
+	}
+	v.entries[key] = cachedResult{response: response, expiresAt: now.Add(v.ttl)}
+
+	return response, nil
+}
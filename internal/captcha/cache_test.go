@@ -0,0 +1,98 @@
+package captcha
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingVerifier records how often tokens are verified upstream
+type countingVerifier struct {
+	calls      int
+	err        error
+	unverified bool // Accept every token unverified, like a breaker failing open
+}
+
+func (v *countingVerifier) Verify(token string, remoteIP string) (*TurnstileResponse, error) {
+	v.calls++
+	if v.err != nil {
+		return nil, v.err
+	}
+	if v.unverified {
+		return &TurnstileResponse{Success: true, Unverified: true}, nil
+	}
+	return &TurnstileResponse{Success: token == "valid-token"}, nil
+}
+
+func TestCachingVerifier(t *testing.T) {
+	upstream := &countingVerifier{}
+	verifier := NewCachingVerifier(upstream, time.Minute)
+
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	t.Run("Repeated token within TTL verifies once", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			result, err := verifier.Verify("valid-token", "127.0.0.1")
+			assert.NoError(t, err)
+			assert.True(t, result.Success)
+		}
+		assert.Equal(t, 1, upstream.calls)
+	})
+
+	t.Run("Tokens are bound to the client IP", func(t *testing.T) {
+		upstream.calls = 0
+		result, err := verifier.Verify("valid-token", "192.0.2.7")
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, 1, upstream.calls, "another IP must not reuse the cached result")
+	})
+
+	t.Run("Failures are not cached", func(t *testing.T) {
+		upstream.calls = 0
+		for i := 0; i < 2; i++ {
+			result, err := verifier.Verify("bad-token", "127.0.0.1")
+			assert.NoError(t, err)
+			assert.False(t, result.Success)
+		}
+		assert.Equal(t, 2, upstream.calls)
+	})
+
+	t.Run("Unverified results are not cached", func(t *testing.T) {
+		upstream.calls = 0
+		upstream.unverified = true
+		result, err := verifier.Verify("garbage-token", "127.0.0.1")
+		assert.NoError(t, err)
+		assert.True(t, result.Unverified)
+		upstream.unverified = false
+
+		// Once upstream recovers the token is checked for real
+		result, err = verifier.Verify("garbage-token", "127.0.0.1")
+		assert.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, 2, upstream.calls)
+	})
+
+	t.Run("Expired entries are verified again", func(t *testing.T) {
+		upstream.calls = 0
+		now = now.Add(2 * time.Minute)
+
+		_, err := verifier.Verify("valid-token", "127.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, upstream.calls)
+		assert.Len(t, verifier.entries, 1)
+	})
+
+	t.Run("Errors are not cached", func(t *testing.T) {
+		upstream.calls = 0
+		upstream.err = errors.New("upstream unavailable")
+
+		for i := 0; i < 2; i++ {
+			_, err := verifier.Verify("other-token", "127.0.0.1")
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 2, upstream.calls)
+	})
+}
@@ -0,0 +1,50 @@
+package captcha
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTooManyVerifications is returned when the concurrent verification limit
+// is reached and no slot frees up in time
+var ErrTooManyVerifications = errors.New("captcha verification unavailable: too many concurrent verifications")
+
+// ConcurrencyLimiter bounds the number of verifications in flight, so a spike
+// of requests can't open an unbounded number of upstream connections
+type ConcurrencyLimiter struct {
+	verifier TurnstileVerifier
+	slots    chan struct{}
+	wait     time.Duration
+}
+
+// NewConcurrencyLimiter wraps verifier so at most limit verifications run at
+// once. Requests beyond the limit wait up to wait for a slot, or fail
+// immediately when wait is zero.
+func NewConcurrencyLimiter(verifier TurnstileVerifier, limit int, wait time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		verifier: verifier,
+		slots:    make(chan struct{}, limit),
+		wait:     wait,
+	}
+}
+
+// Verify passes the token on once a slot is free
+func (l *ConcurrencyLimiter) Verify(token string, remoteIP string) (*TurnstileResponse, error) {
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		if l.wait <= 0 {
+			return nil, ErrTooManyVerifications
+		}
+		timer := time.NewTimer(l.wait)
+		defer timer.Stop()
+		select {
+		case l.slots <- struct{}{}:
+		case <-timer.C:
+			return nil, ErrTooManyVerifications
+		}
+	}
+	defer func() { <-l.slots }()
+
+	return l.verifier.Verify(token, remoteIP)
+}
@@ -0,0 +1,97 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	var inFlight, maxInFlight, calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		<-release
+		json.NewEncoder(w).Encode(TurnstileResponse{Success: true})
+	}))
+	defer server.Close()
+
+	newLimiter := func(limit int, wait time.Duration) *ConcurrencyLimiter {
+		client := NewTurnstileClient("key")
+		client.verifyURL = server.URL
+		return NewConcurrencyLimiter(client, limit, wait)
+	}
+
+	// verifyAll runs n verifications at once, releasing the stub server once
+	// the expected number of upstream calls are in flight and the expected
+	// number of verifications have already returned
+	verifyAll := func(limiter *ConcurrencyLimiter, n int, upstream, returned int32) []error {
+		errs := make([]error, n)
+		var done atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = limiter.Verify("token", "")
+				done.Add(1)
+			}(i)
+		}
+		assert.Eventually(t, func() bool {
+			return inFlight.Load() == upstream && done.Load() == returned
+		}, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+		return errs
+	}
+
+	t.Run("Rejects beyond the limit without waiting", func(t *testing.T) {
+		calls.Store(0)
+		maxInFlight.Store(0)
+		limiter := newLimiter(3, 0)
+
+		errs := verifyAll(limiter, 10, 3, 7)
+
+		rejected := 0
+		for _, err := range errs {
+			if errors.Is(err, ErrTooManyVerifications) {
+				rejected++
+			} else {
+				assert.NoError(t, err)
+			}
+		}
+		assert.Equal(t, 7, rejected)
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, int32(3), maxInFlight.Load())
+	})
+
+	t.Run("Queued requests run once a slot frees up", func(t *testing.T) {
+		release = make(chan struct{})
+		calls.Store(0)
+		maxInFlight.Store(0)
+		limiter := newLimiter(2, 5*time.Second)
+
+		errs := verifyAll(limiter, 6, 2, 0)
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int32(6), calls.Load())
+		assert.Equal(t, int32(2), maxInFlight.Load())
+	})
+}
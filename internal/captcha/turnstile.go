@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,8 +18,9 @@ type TurnstileVerifier interface {
 }
 
 type TurnstileClient struct {
-	secretKey string
-	client    *http.Client
+	secretKeys []string
+	verifyURL  string
+	client     *http.Client
 }
 
 type TurnstileResponse struct {
@@ -28,27 +30,67 @@ type TurnstileResponse struct {
 	ErrorCodes  []string  `json:"error-codes"`
 	Action      string    `json:"action"`
 	CData       string    `json:"cdata"`
+	Unverified  bool      `json:"-"` // Accepted without upstream verification while failing open
 }
 
-func NewTurnstileClient(secretKey string) *TurnstileClient {
+// MatchesAction reports whether the token was minted for the expected
+// Turnstile action. An empty expected action accepts any, and so do results
+// accepted without verification, which carry no action.
+func (r *TurnstileResponse) MatchesAction(expected string) bool {
+	return expected == "" || r.Unverified || r.Action == expected
+}
+
+// NewTurnstileClient creates a client that verifies tokens against each secret
+// key in turn, so a new key can be rolled out while the old one is still accepted
+func NewTurnstileClient(secretKeys ...string) *TurnstileClient {
 	return &TurnstileClient{
-		secretKey: secretKey,
+		secretKeys: secretKeys,
+		verifyURL:  turnstileVerifyURL,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 	}
 }
 
+// ParseSecretKeys splits a comma-separated list of secret keys, dropping blanks
+func ParseSecretKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Verify checks a token against each configured secret key until one accepts
+// it. The last response is returned if none do.
 func (t *TurnstileClient) Verify(token string, remoteIP string) (*TurnstileResponse, error) {
+	if len(t.secretKeys) == 0 {
+		return t.verifyWithKey("", token, remoteIP)
+	}
+
+	var result *TurnstileResponse
+	var err error
+	for _, key := range t.secretKeys {
+		result, err = t.verifyWithKey(key, token, remoteIP)
+		if err == nil && result.Success {
+			return result, nil
+		}
+	}
+	return result, err
+}
+
+func (t *TurnstileClient) verifyWithKey(secretKey, token, remoteIP string) (*TurnstileResponse, error) {
 	data := url.Values{}
-	data.Set("secret", t.secretKey)
+	data.Set("secret", secretKey)
 	data.Set("response", token)
 	if remoteIP != "" {
 		data.Set("remoteip", remoteIP)
 	}
 
 	resp, err := t.client.Post(
-		turnstileVerifyURL,
+		t.verifyURL,
 		"application/x-www-form-urlencoded",
 		strings.NewReader(data.Encode()),
 	)
@@ -57,6 +99,13 @@ func (t *TurnstileClient) Verify(token string, remoteIP string) (*TurnstileRespo
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &UpstreamError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
 	var result TurnstileResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -64,3 +113,27 @@ func (t *TurnstileClient) Verify(token string, remoteIP string) (*TurnstileRespo
 
 	return &result, nil
 }
+
+// UpstreamError reports that the verification endpoint is overloaded or failing
+type UpstreamError struct {
+	StatusCode int
+	RetryAfter time.Duration // Zero when the response had no Retry-After header
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("captcha verification returned status %d", e.StatusCode)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package captcha
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newStubVerifyServer returns a siteverify stub that only accepts the given secret key
+func newStubVerifyServer(t *testing.T, acceptedKey string) (*httptest.Server, *[]string) {
+	var triedKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Failed to parse form: %v", err)
+		}
+		secret := r.PostForm.Get("secret")
+		triedKeys = append(triedKeys, secret)
+
+		response := TurnstileResponse{Success: secret == acceptedKey}
+		if !response.Success {
+			response.ErrorCodes = []string{"invalid-input-secret"}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	return server, &triedKeys
+}
+
+func TestSecretKeyRotation(t *testing.T) {
+	server, triedKeys := newStubVerifyServer(t, "new-key")
+	defer server.Close()
+
+	newClient := func(keys ...string) *TurnstileClient {
+		client := NewTurnstileClient(keys...)
+		client.verifyURL = server.URL
+		return client
+	}
+
+	t.Run("Fallback key verifies", func(t *testing.T) {
+		*triedKeys = nil
+		result, err := newClient("old-key", "new-key").Verify("token", "127.0.0.1")
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, []string{"old-key", "new-key"}, *triedKeys)
+	})
+
+	t.Run("Primary key verifies without fallback", func(t *testing.T) {
+		*triedKeys = nil
+		result, err := newClient("new-key", "old-key").Verify("token", "")
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, []string{"new-key"}, *triedKeys)
+	})
+
+	t.Run("All keys rejected", func(t *testing.T) {
+		result, err := newClient("old-key", "older-key").Verify("token", "")
+		assert.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, []string{"invalid-input-secret"}, result.ErrorCodes)
+	})
+}
+
+func TestParseSecretKeys(t *testing.T) {
+	assert.Equal(t, []string{"new-key", "old-key"}, ParseSecretKeys(" new-key, old-key ,"))
+	assert.Equal(t, []string{"only-key"}, ParseSecretKeys("only-key"))
+	assert.Nil(t, ParseSecretKeys(""))
+}
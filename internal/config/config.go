@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -19,14 +22,64 @@ type Config struct {
 }
 
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Host string `mapstructure:"host"`
-	Env  string `mapstructure:"env"`
+	Port            int               `mapstructure:"port"`
+	Host            string            `mapstructure:"host"`
+	Env             string            `mapstructure:"env"`
+	UnixSocket      string            `mapstructure:"unix_socket"`
+	BasePath        string            `mapstructure:"base_path"`
+	TrustedProxies  []string          `mapstructure:"trusted_proxies"`
+	MaintenanceMode bool              `mapstructure:"maintenance_mode"`
+	PrettyJSON      bool              `mapstructure:"pretty_json"`
+	Compression     CompressionConfig `mapstructure:"compression"`
+}
+
+type CompressionConfig struct {
+	Enabled      bool `mapstructure:"enabled"`
+	MinSizeBytes int  `mapstructure:"min_size_bytes"`
+}
+
+// HeadersConfig holds the security headers sent on every response. Empty
+// values leave a header out.
+type HeadersConfig struct {
+	FrameOptions          string `mapstructure:"frame_options"`
+	ReferrerPolicy        string `mapstructure:"referrer_policy"`
+	ContentSecurityPolicy string `mapstructure:"content_security_policy"`
+	HSTSMaxAgeSec         int    `mapstructure:"hsts_max_age_sec"`
 }
 
 type SecurityConfig struct {
-	EnableCaptcha        bool `mapstructure:"enable_captcha"`
-	ServerSideEncryption bool `mapstructure:"server_side_encryption"`
+	EnableCaptcha           bool              `mapstructure:"enable_captcha"`
+	ServerSideEncryption    bool              `mapstructure:"server_side_encryption"`
+	CiphertextEncoding      string            `mapstructure:"ciphertext_encoding"`
+	EncryptionSaltBytes     int               `mapstructure:"encryption_salt_bytes"`
+	UniformNotFound         bool              `mapstructure:"uniform_not_found"`
+	ConstantTimeResponses   bool              `mapstructure:"constant_time_responses"`
+	MinResponseMS           int               `mapstructure:"min_response_ms"`
+	EncryptionKeyFile       string            `mapstructure:"encryption_key_file"`
+	EncryptionKeyID         string            `mapstructure:"encryption_key_id"`
+	EncryptionKeyFiles      map[string]string `mapstructure:"encryption_key_files"`
+	AllowInsecureProduction bool              `mapstructure:"allow_insecure_production"`
+	IPAllowlist             []string          `mapstructure:"ip_allowlist"`
+	IPDenylist              []string          `mapstructure:"ip_denylist"`
+	Captcha                 CaptchaConfig     `mapstructure:"captcha"`
+	Headers                 HeadersConfig     `mapstructure:"headers"`
+	AdminToken              string
+	APIToken                string
+}
+
+// CaptchaConfig selects which routes require a captcha when captcha is enabled
+type CaptchaConfig struct {
+	Create             bool   `mapstructure:"create"`
+	View               bool   `mapstructure:"view"`
+	CreateAction       string `mapstructure:"create_action"`     // Turnstile action required on create, empty for any
+	ViewAction         string `mapstructure:"view_action"`       // Turnstile action required on views, empty for any
+	Middleware         bool   `mapstructure:"middleware"`        // Verify before the handler runs
+	CacheTTLSec        int    `mapstructure:"cache_ttl_sec"`     // Reuse results per token, 0 to disable
+	BreakerThreshold   int    `mapstructure:"breaker_threshold"` // Consecutive upstream errors that open the circuit, 0 to disable
+	BreakerCooldownSec int    `mapstructure:"breaker_cooldown_sec"`
+	FailOpen           bool   `mapstructure:"fail_open"`       // Accept tokens unverified while the circuit is open
+	MaxConcurrency     int    `mapstructure:"max_concurrency"` // Verifications in flight at once, 0 for no limit
+	ConcurrencyWaitMS  int    `mapstructure:"concurrency_wait_ms"`
 }
 
 type RouteRateLimit struct {
@@ -35,44 +88,94 @@ type RouteRateLimit struct {
 }
 
 type RateLimitConfig struct {
-	Enabled bool                      `mapstructure:"enabled"`
-	Routes  map[string]RouteRateLimit `mapstructure:"routes"`
-	Default RouteRateLimit            `mapstructure:"default"`
+	Enabled            bool                      `mapstructure:"enabled"`
+	MaxConcurrentPerIP int                       `mapstructure:"max_concurrent_per_ip"`
+	GlobalPerSecond    int                       `mapstructure:"global_per_second"`
+	Routes             map[string]RouteRateLimit `mapstructure:"routes"`
+	Default            RouteRateLimit            `mapstructure:"default"`
+	NameLookupFailures RouteRateLimit            `mapstructure:"name_lookup_failures"`
 }
 
 type SecretsConfig struct {
-	MaxSizeBytes         int    `mapstructure:"max_size_bytes"`
-	MaxCustomNameLength  int    `mapstructure:"max_custom_name_length"`
-	DefaultExpiryMinutes int    `mapstructure:"default_expiry_minutes"`
-	MaxExpiryDays        int    `mapstructure:"max_expiry_days"`
-	StoragePath          string `mapstructure:"storage_path"`
-	CleanupIntervalSec   int    `mapstructure:"cleanup_interval_sec"`
+	MaxSizeBytes         int            `mapstructure:"max_size_bytes"`
+	MinCiphertextBytes   int            `mapstructure:"min_ciphertext_bytes"`
+	SaltBytes            int            `mapstructure:"salt_bytes"`
+	IVBytes              int            `mapstructure:"iv_bytes"`
+	MaxCustomNameLength  int            `mapstructure:"max_custom_name_length"`
+	CustomNamePattern    string         `mapstructure:"custom_name_pattern"`
+	DefaultExpiryMinutes int            `mapstructure:"default_expiry_minutes"`
+	MaxExpiryDays        int            `mapstructure:"max_expiry_days"`
+	HardMaxAge           time.Duration  `mapstructure:"hard_max_age"`
+	MaxViewsLimit        int            `mapstructure:"max_views_limit"`
+	ExpirySkewSec        int            `mapstructure:"expiry_skew_sec"`
+	ExpiryGraceSeconds   int            `mapstructure:"expiry_grace_seconds"`
+	ExpiryJitterSeconds  int            `mapstructure:"expiry_jitter_seconds"`
+	ExpiredRetentionMin  int            `mapstructure:"expired_retention_minutes"`
+	StoragePath          string         `mapstructure:"storage_path"`
+	IDScheme             string         `mapstructure:"id_scheme"`
+	IDBytes              int            `mapstructure:"id_bytes"`
+	CleanupIntervalSec   int            `mapstructure:"cleanup_interval_sec"`
+	MaxTotal             int            `mapstructure:"max_total"`
+	CleanupDryRun        bool           `mapstructure:"cleanup_dry_run"`
+	BurnGraceSeconds     int            `mapstructure:"burn_grace_seconds"`
+	ViewTokenTTLSec      int            `mapstructure:"view_token_ttl_sec"`
+	DecryptCacheSize     int            `mapstructure:"decrypt_cache_size"`
+	CreatorSessions      bool           `mapstructure:"creator_sessions"`
+	ExposeCreatedAt      bool           `mapstructure:"expose_created_at"`
+	MaxRequestBytes      int64          `mapstructure:"max_request_bytes"`
+	MaxImportBytes       int64          `mapstructure:"max_import_bytes"`
+	MaxImportEntryBytes  int64          `mapstructure:"max_import_entry_bytes"`
+	CompressAtRest       bool           `mapstructure:"compress_at_rest"`
+	EncryptRecords       bool           `mapstructure:"encrypt_records"`
+	Autoname             AutonameConfig `mapstructure:"autoname"`
+}
+
+// AutonameConfig controls server-generated custom names
+type AutonameConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	Length      int  `mapstructure:"length"`
+	MaxAttempts int  `mapstructure:"max_attempts"`
 }
 
 type RedisConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	DB       int    `mapstructure:"db"`
-	Password string
-	Username string
+	Host           string `mapstructure:"host"`
+	Port           int    `mapstructure:"port"`
+	DB             int    `mapstructure:"db"`
+	PoolSize       int    `mapstructure:"pool_size"`
+	DialTimeoutMS  int    `mapstructure:"dial_timeout_ms"`
+	ReadTimeoutMS  int    `mapstructure:"read_timeout_ms"`
+	WriteTimeoutMS int    `mapstructure:"write_timeout_ms"`
+	Password       string
+	Username       string
 }
 
 type CORSConfig struct {
-	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	AllowedOrigins  []string `mapstructure:"allowed_origins"`
+	MergeEnvOrigins bool     `mapstructure:"merge_env_origins"` // Append env origins to the file list instead of replacing it
 }
 
 type LoggingConfig struct {
 	Enabled          bool               `mapstructure:"enabled"`
 	ConsoleOutput    bool               `mapstructure:"console_output"`
+	Stdout           bool               `mapstructure:"stdout"`
 	Directory        string             `mapstructure:"directory"`
 	ArchiveDirectory string             `mapstructure:"archive_directory"`
 	Rotation         LogRotationConfig  `mapstructure:"rotation"`
 	Retention        LogRetentionConfig `mapstructure:"retention"`
 	Files            LogFilesConfig     `mapstructure:"files"`
+	Audit            LogFileConfig      `mapstructure:"audit"`
+	StartupEnv       EnvRedactionConfig `mapstructure:"startup_env"`
+	SlowRequestMS    int                `mapstructure:"slow_request_ms"`
+	AccessErrorsOnly bool               `mapstructure:"access_errors_only"`
+	TimestampFormat  string             `mapstructure:"timestamp_format"`
+	Timezone         string             `mapstructure:"timezone"`
+	AuditHashKey     string
 }
 
 type LogRotationConfig struct {
-	SizeMB int `mapstructure:"size_mb"`
+	SizeMB     int `mapstructure:"size_mb"`
+	MaxBackups int `mapstructure:"max_backups"`
+	MaxTotalMB int `mapstructure:"max_total_mb"`
 }
 
 type LogRetentionConfig struct {
@@ -81,6 +184,17 @@ type LogRetentionConfig struct {
 
 type LogFileConfig struct {
 	Filename string `mapstructure:"filename"`
+	Enabled  bool   `mapstructure:"enabled"`
+}
+
+// EnvRedactionConfig controls which environment variable values are masked in
+// the startup banner. Allow takes precedence over Redact, which takes
+// precedence over RedactAll and SensitiveSubstrings. Keys match case-insensitively.
+type EnvRedactionConfig struct {
+	RedactAll           bool     `mapstructure:"redact_all"`
+	SensitiveSubstrings []string `mapstructure:"sensitive_substrings"`
+	Redact              []string `mapstructure:"redact"`
+	Allow               []string `mapstructure:"allow"`
 }
 
 type LogFilesConfig struct {
@@ -90,26 +204,96 @@ type LogFilesConfig struct {
 	Application LogFileConfig `mapstructure:"application"`
 }
 
+// EnvPrefix prefixes environment variables that override config keys, e.g.
+// ANONDROP_SERVER_PORT overrides server.port
+const EnvPrefix = "ANONDROP"
+
+// corsOriginsEnv overrides cors.allowed_origins with a comma-separated list
+const corsOriginsEnv = EnvPrefix + "_CORS_ALLOWED_ORIGINS"
+
+// configExtensions lists the supported config formats in order of preference
+var configExtensions = []string{"yaml", "yml", "json", "toml"}
+
+// LoadConfig reads config.yaml from configPath, falling back to config.yml,
+// config.json or config.toml. The CONFIG_FILE environment variable points at a
+// config file directly and CONFIG_PATH overrides the directory searched.
 func LoadConfig(configPath string) (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(configPath)
+	v := viper.New()
 
-	if err := viper.ReadInConfig(); err != nil {
+	configFile, err := findConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	v.SetConfigFile(configFile)
+	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
+	// Read before environment overrides apply, so env origins can be merged
+	fileOrigins := v.GetStringSlice("cors.allowed_origins")
+
+	// Every config key can be overridden from the environment
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	bindEnvKeys(v, reflect.TypeOf(Config{}), "")
+
+	// Captcha applies to every route unless explicitly turned off
+	v.SetDefault("security.captcha.create", true)
+	v.SetDefault("security.captcha.view", true)
+
+	// Stop calling a failing captcha upstream for a while
+	v.SetDefault("security.captcha.breaker_threshold", 5)
+	v.SetDefault("security.captcha.breaker_cooldown_sec", 30)
+
+	// The API only serves JSON, so nothing needs framing, referrers or sources
+	v.SetDefault("security.headers.frame_options", "DENY")
+	v.SetDefault("security.headers.referrer_policy", "no-referrer")
+	v.SetDefault("security.headers.content_security_policy", "default-src 'none'; frame-ancestors 'none'")
+	v.SetDefault("security.headers.hsts_max_age_sec", 31536000)
+
+	// Long enough to cover a typical lookup including captcha verification
+	v.SetDefault("security.min_response_ms", 250)
+
+	// Sizes produced by the web client's AES-GCM encryption
+	v.SetDefault("secrets.min_ciphertext_bytes", 16)
+	v.SetDefault("secrets.salt_bytes", 16)
+	v.SetDefault("secrets.iv_bytes", 12)
+
+	// Backups are far larger than API requests but must stay bounded
+	v.SetDefault("secrets.max_import_bytes", 104857600)
+	v.SetDefault("secrets.max_import_entry_bytes", 1048576)
+
+	// Multi-view secrets must still burn eventually
+	v.SetDefault("secrets.max_views_limit", 100)
+
+	// Generated names are short but leave room for retries on collision
+	v.SetDefault("secrets.autoname.length", 8)
+	v.SetDefault("secrets.autoname.max_attempts", 5)
+
+	// Mask anything that looks like a credential in the startup banner
+	v.SetDefault("logging.startup_env.sensitive_substrings", []string{"key", "secret", "password", "token"})
+
+	// Log files stay enabled unless explicitly turned off
+	for _, name := range []string{"error", "access", "ratelimit", "application"} {
+		v.SetDefault("logging.files."+name+".enabled", true)
+	}
+
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
-	// Load environment variables
-	viper.AutomaticEnv()
-
 	// Load sensitive configuration from environment
 	config.Redis.Password = os.Getenv("REDIS_PASSWORD")
 	config.Redis.Username = os.Getenv("REDIS_USERNAME")
+	config.Security.AdminToken = os.Getenv("ADMIN_TOKEN")
+	config.Security.APIToken = os.Getenv("API_TOKEN")
+	config.Logging.AuditHashKey = os.Getenv("AUDIT_HASH_KEY")
+
+	if envOrigins, ok := os.LookupEnv(corsOriginsEnv); ok {
+		config.CORS.AllowedOrigins = resolveCORSOrigins(fileOrigins, envOrigins, config.CORS.MergeEnvOrigins)
+	}
 
 	// Ensure storage directory exists
 	if err := os.MkdirAll(filepath.Join(configPath, config.Secrets.StoragePath), 0750); err != nil {
@@ -118,3 +302,68 @@ func LoadConfig(configPath string) (*Config, error) {
 
 	return &config, nil
 }
+
+// resolveCORSOrigins combines the origins from the config file with a
+// comma-separated list from the environment, which replaces the file list
+// unless merge is set. Blank entries and duplicates are dropped.
+func resolveCORSOrigins(fileOrigins []string, envOrigins string, merge bool) []string {
+	var origins []string
+	if merge {
+		origins = fileOrigins
+	}
+	origins = append(origins, strings.Split(envOrigins, ",")...)
+
+	resolved := make([]string, 0, len(origins))
+	seen := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" || seen[origin] {
+			continue
+		}
+		seen[origin] = true
+		resolved = append(resolved, origin)
+	}
+	return resolved
+}
+
+// bindEnvKeys registers every key of the config struct with viper so
+// environment overrides apply even to keys missing from the config file.
+// AutomaticEnv alone only covers keys viper already knows about.
+func bindEnvKeys(v *viper.Viper, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" {
+			continue // Loaded from dedicated environment variables
+		}
+
+		key := prefix + tag
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			bindEnvKeys(v, field.Type, key+".")
+			continue
+		case reflect.Map:
+			continue // Entries from the config file are covered by AutomaticEnv
+		}
+		_ = v.BindEnv(key) // Only fails without a key
+	}
+}
+
+// findConfigFile resolves the config file to load, honoring the CONFIG_FILE
+// and CONFIG_PATH overrides
+func findConfigFile(configPath string) (string, error) {
+	if configFile := os.Getenv("CONFIG_FILE"); configFile != "" {
+		return configFile, nil
+	}
+	if dir := os.Getenv("CONFIG_PATH"); dir != "" {
+		configPath = dir
+	}
+
+	for _, ext := range configExtensions {
+		path := filepath.Join(configPath, "config."+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("error reading config file: no config.%s found in %s", strings.Join(configExtensions, "/config."), configPath)
+}
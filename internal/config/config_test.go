@@ -0,0 +1,233 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testYAMLConfig = `
+server:
+  port: 8080
+  host: "localhost"
+  env: "development"
+security:
+  enable_captcha: true
+  captcha:
+    view: false
+rate_limit:
+  enabled: true
+  routes:
+    create_secret:
+      requests_per_hour: 100
+      requests_per_minute: 10
+secrets:
+  max_size_bytes: 500
+  storage_path: "data/secrets"
+cors:
+  allowed_origins:
+    - "http://localhost:3000"
+`
+
+const testJSONConfig = `{
+  "server": {"port": 8080, "host": "localhost", "env": "development"},
+  "security": {"enable_captcha": true, "captcha": {"view": false}},
+  "rate_limit": {
+    "enabled": true,
+    "routes": {"create_secret": {"requests_per_hour": 100, "requests_per_minute": 10}}
+  },
+  "secrets": {"max_size_bytes": 500, "storage_path": "data/secrets"},
+  "cors": {"allowed_origins": ["http://localhost:3000"]}
+}`
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CONFIG_PATH", "")
+
+	yamlDir := t.TempDir()
+	writeConfig(t, yamlDir, "config.yaml", testYAMLConfig)
+	jsonDir := t.TempDir()
+	writeConfig(t, jsonDir, "config.json", testJSONConfig)
+
+	yamlCfg, err := LoadConfig(yamlDir)
+	assert.NoError(t, err)
+	jsonCfg, err := LoadConfig(jsonDir)
+	assert.NoError(t, err)
+
+	assert.Equal(t, yamlCfg, jsonCfg)
+	assert.Equal(t, 8080, jsonCfg.Server.Port)
+	assert.Equal(t, 10, jsonCfg.RateLimit.Routes["create_secret"].RequestsPerMinute)
+	assert.True(t, jsonCfg.Security.Captcha.Create, "defaults apply to every format")
+	assert.False(t, jsonCfg.Security.Captcha.View)
+
+	_, err = os.Stat(filepath.Join(jsonDir, "data/secrets"))
+	assert.NoError(t, err, "storage directory is created")
+}
+
+func TestLoadConfigPrefersYAML(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CONFIG_PATH", "")
+
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", "server:\n  port: 1111\n")
+	writeConfig(t, dir, "config.json", `{"server": {"port": 2222}}`)
+
+	cfg, err := LoadConfig(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1111, cfg.Server.Port)
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	workDir := t.TempDir()
+
+	t.Run("CONFIG_PATH", func(t *testing.T) {
+		dir := t.TempDir()
+		writeConfig(t, dir, "config.toml", "[server]\nport = 3333\n")
+		t.Setenv("CONFIG_FILE", "")
+		t.Setenv("CONFIG_PATH", dir)
+
+		cfg, err := LoadConfig(workDir)
+		assert.NoError(t, err)
+		assert.Equal(t, 3333, cfg.Server.Port)
+	})
+
+	t.Run("CONFIG_FILE", func(t *testing.T) {
+		path := writeConfig(t, t.TempDir(), "anondrop.json", `{"server": {"port": 4444}}`)
+		t.Setenv("CONFIG_FILE", path)
+		t.Setenv("CONFIG_PATH", "")
+
+		cfg, err := LoadConfig(workDir)
+		assert.NoError(t, err)
+		assert.Equal(t, 4444, cfg.Server.Port)
+	})
+
+	t.Run("Missing config", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", "")
+		t.Setenv("CONFIG_PATH", "")
+
+		_, err := LoadConfig(t.TempDir())
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadConfigEnvKeys(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CONFIG_PATH", "")
+
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", testYAMLConfig)
+
+	t.Setenv("ANONDROP_SERVER_PORT", "9090")
+	t.Setenv("ANONDROP_RATE_LIMIT_ENABLED", "false")
+	t.Setenv("ANONDROP_RATE_LIMIT_ROUTES_CREATE_SECRET_REQUESTS_PER_MINUTE", "5")
+	t.Setenv("ANONDROP_SECURITY_CAPTCHA_VIEW", "true")
+	t.Setenv("ANONDROP_SECRETS_MAX_EXPIRY_DAYS", "3")
+	t.Setenv("ANONDROP_SECRETS_HARD_MAX_AGE", "720h")
+	t.Setenv("ANONDROP_CORS_ALLOWED_ORIGINS", "https://a.example,https://b.example")
+
+	cfg, err := LoadConfig(dir)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 9090, cfg.Server.Port)
+	assert.False(t, cfg.RateLimit.Enabled)
+	assert.Equal(t, 5, cfg.RateLimit.Routes["create_secret"].RequestsPerMinute)
+	assert.True(t, cfg.Security.Captcha.View)
+	assert.Equal(t, 3, cfg.Secrets.MaxExpiryDays, "keys missing from the file can be set")
+	assert.Equal(t, 720*time.Hour, cfg.Secrets.HardMaxAge, "durations are parsed")
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.CORS.AllowedOrigins)
+
+	// Keys without an override keep their file values
+	assert.Equal(t, "localhost", cfg.Server.Host)
+	assert.Equal(t, 500, cfg.Secrets.MaxSizeBytes)
+}
+
+func TestLoadConfigStartupEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CONFIG_PATH", "")
+
+	dir := t.TempDir()
+	writeConfig(t, dir, "config.yaml", testYAMLConfig)
+	cfg, err := LoadConfig(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key", "secret", "password", "token"}, cfg.Logging.StartupEnv.SensitiveSubstrings)
+
+	// An explicitly empty list replaces the default
+	writeConfig(t, dir, "config.yaml", testYAMLConfig+"logging:\n  startup_env:\n    sensitive_substrings: []\n")
+	cfg, err = LoadConfig(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.Logging.StartupEnv.SensitiveSubstrings)
+}
+
+func TestLoadConfigCORSOrigins(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CONFIG_PATH", "")
+
+	withOrigins := t.TempDir()
+	writeConfig(t, withOrigins, "config.yaml", testYAMLConfig)
+	merging := t.TempDir()
+	writeConfig(t, merging, "config.yaml", testYAMLConfig+"  merge_env_origins: true\n")
+	withoutOrigins := t.TempDir()
+	writeConfig(t, withoutOrigins, "config.yaml", "server:\n  port: 8080\n")
+
+	testCases := []struct {
+		name string
+		dir  string
+		env  *string
+		want []string
+	}{
+		{
+			name: "File only",
+			dir:  withOrigins,
+			want: []string{"http://localhost:3000"},
+		},
+		{
+			name: "Env only",
+			dir:  withoutOrigins,
+			env:  ptr("https://a.example, https://b.example"),
+			want: []string{"https://a.example", "https://b.example"},
+		},
+		{
+			name: "Env replaces the file list",
+			dir:  withOrigins,
+			env:  ptr("https://a.example"),
+			want: []string{"https://a.example"},
+		},
+		{
+			name: "Env merged into the file list",
+			dir:  merging,
+			env:  ptr("https://a.example,,http://localhost:3000"),
+			want: []string{"http://localhost:3000", "https://a.example"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != nil {
+				t.Setenv("ANONDROP_CORS_ALLOWED_ORIGINS", *tc.env)
+			} else {
+				t.Setenv("ANONDROP_CORS_ALLOWED_ORIGINS", "")
+				os.Unsetenv("ANONDROP_CORS_ALLOWED_ORIGINS")
+			}
+
+			cfg, err := LoadConfig(tc.dir)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, cfg.CORS.AllowedOrigins)
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
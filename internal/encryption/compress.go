@@ -0,0 +1,39 @@
+package encryption
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressPlaintext gzips data for EncryptCompressed. Compression happens
+// before encryption, which is safe here because the stored data is never mixed
+// with attacker-chosen content.
+func compressPlaintext(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressPlaintext reverses compressPlaintext for envelopes carrying the
+// compressed flag
+func decompressPlaintext(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	defer zr.Close()
+
+	plaintext, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,108 @@
+package encryption
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncryptCompressed(t *testing.T) {
+	cleanup := setupTestLogger(t)
+	defer cleanup()
+
+	encryptor := NewEncryptor("test-server-key-that-is-32-bytes!")
+	large := []byte(strings.Repeat("QUJDREVGR0hJSktMTU5PUA==", 200) + ".c2FsdHNhbHRzYWx0c2FsdA==.aXZpdml2aXZpdml2")
+	small := []byte("YQ==.Yg==.Yw==")
+
+	t.Run("Large data is compressed and round-trips", func(t *testing.T) {
+		plain, err := encryptor.Encrypt(large, "")
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		packed, err := encryptor.EncryptCompressed(large, "")
+		if err != nil {
+			t.Fatalf("Failed to encrypt compressed: %v", err)
+		}
+		if !IsCompressed(packed) {
+			t.Fatal("Expected the compressed flag in the envelope header")
+		}
+		if len(packed) >= len(plain) {
+			t.Errorf("Expected compressed data to be smaller, got %d >= %d bytes", len(packed), len(plain))
+		}
+
+		decrypted, err := encryptor.Decrypt(packed, "")
+		if err != nil {
+			t.Fatalf("Failed to decrypt: %v", err)
+		}
+		if !bytes.Equal(decrypted, large) {
+			t.Error("Round-tripped data differs from the original")
+		}
+	})
+
+	t.Run("Data that doesn't shrink is left uncompressed", func(t *testing.T) {
+		packed, err := encryptor.EncryptCompressed(small, "")
+		if err != nil {
+			t.Fatalf("Failed to encrypt compressed: %v", err)
+		}
+		if IsCompressed(packed) {
+			t.Error("Expected no compressed flag for data that doesn't shrink")
+		}
+
+		decrypted, err := encryptor.Decrypt(packed, "")
+		if err != nil {
+			t.Fatalf("Failed to decrypt: %v", err)
+		}
+		if !bytes.Equal(decrypted, small) {
+			t.Errorf("Expected small data unchanged, got %q", decrypted)
+		}
+	})
+
+	t.Run("The header flag drives decompression", func(t *testing.T) {
+		// A gzip stream encrypted without the flag is returned as stored
+		gzipped, err := compressPlaintext(large)
+		if err != nil {
+			t.Fatalf("Failed to compress: %v", err)
+		}
+		encrypted, err := encryptor.Encrypt(gzipped, "")
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		decrypted, err := encryptor.Decrypt(encrypted, "")
+		if err != nil {
+			t.Fatalf("Failed to decrypt: %v", err)
+		}
+		if !bytes.Equal(decrypted, gzipped) {
+			t.Error("Data without the header flag was decompressed")
+		}
+
+		// The flag is authenticated, so it can't be flipped on stored data
+		flipped := append([]byte{}, encrypted...)
+		flipped[2] |= envelopeCompressed
+		if _, err := encryptor.Decrypt(flipped, ""); err == nil {
+			t.Error("Expected an error after setting the flag on sealed data")
+		}
+	})
+
+	t.Run("Works with a key ring", func(t *testing.T) {
+		ring, err := NewKeyRing("k1", map[string]string{"k1": "ring-key-that-is-32-bytes-long!!"})
+		if err != nil {
+			t.Fatalf("Failed to create key ring: %v", err)
+		}
+		ringEncryptor := NewEncryptor("test-server-key-that-is-32-bytes!", WithKeyRing(ring))
+
+		packed, err := ringEncryptor.EncryptCompressed(large, "")
+		if err != nil {
+			t.Fatalf("Failed to encrypt compressed: %v", err)
+		}
+		if packed[2] != envelopeKeyIDVersion|envelopeCompressed {
+			t.Errorf("Expected a compressed version 2 header, got version byte %#x", packed[2])
+		}
+		decrypted, err := ringEncryptor.Decrypt(packed, "")
+		if err != nil {
+			t.Fatalf("Failed to decrypt: %v", err)
+		}
+		if !bytes.Equal(decrypted, large) {
+			t.Error("Round-tripped data differs from the original")
+		}
+	})
+}
@@ -1,13 +1,17 @@
 package encryption
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"sync/atomic"
 
 	"secrets-share/internal/logger"
 
@@ -16,63 +20,161 @@ import (
 
 const (
 	keySize    = 32 // AES-256
-	saltSize   = 16
+	saltSize   = 16 // Default salt size, and the fixed size in legacy data
+	nonceSize  = 12 // Standard GCM nonce
 	iterations = 10000
+
+	// MinSaltSize and MaxSaltSize bound the configurable salt size
+	MinSaltSize = 16
+	MaxSaltSize = 64
+)
+
+// Encrypted data starts with a header of envelopeMagic, a version byte and the
+// salt size, followed by the salt, nonce and ciphertext. Version 2 headers
+// add the length and bytes of the ID of the key used, after the salt size.
+// The envelopeCompressed bit of the version byte marks plaintext that was
+// gzipped before encryption. The header is authenticated with the ciphertext. Data written before the
+// header existed starts directly with a 16-byte salt and is still accepted.
+var envelopeMagic = []byte{0xad, 0x5e}
+
+const (
+	envelopeVersion      byte = 1
+	envelopeKeyIDVersion byte = 2
+	envelopeCompressed   byte = 0x80
+	envelopeHeaderSize        = 4
 )
 
 type Encryptor struct {
 	serverKey []byte
+	keyRing   *KeyRing // Nil encrypts under serverKey without a key ID
+	saltSize  int
+	random    io.Reader // Source of salts and nonces
+	failures  atomic.Int64
+}
+
+// Option configures optional Encryptor behavior
+type Option func(*Encryptor)
+
+// WithSaltSize sets the salt size in bytes for newly encrypted data. Zero
+// keeps the default of 16; sizes outside MinSaltSize..MaxSaltSize make
+// encryption fail.
+func WithSaltSize(size int) Option {
+	return func(e *Encryptor) {
+		if size != 0 {
+			e.saltSize = size
+		}
+	}
+}
+
+// WithKeyRing encrypts new data under the ring's primary key and decrypts data
+// by the key ID in its header. Data without a key ID still uses the server key.
+func WithKeyRing(ring *KeyRing) Option {
+	return func(e *Encryptor) {
+		e.keyRing = ring
+	}
 }
 
-func NewEncryptor(serverKey string) *Encryptor {
-	return &Encryptor{
+func NewEncryptor(serverKey string, opts ...Option) *Encryptor {
+	e := &Encryptor{
 		serverKey: []byte(serverKey),
+		saltSize:  saltSize,
+		random:    rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// SelfTest encrypts a known plaintext twice and fails if the salts or nonces
+// repeat, which would mean the random source is broken and GCM nonces could be
+// reused. It also checks that the result decrypts back to the plaintext.
+func (e *Encryptor) SelfTest() error {
+	plaintext := []byte("anondrop encryption self-test")
+
+	first, err := e.Encrypt(plaintext, "")
+	if err != nil {
+		return fmt.Errorf("self-test encryption failed: %w", err)
+	}
+	second, err := e.Encrypt(plaintext, "")
+	if err != nil {
+		return fmt.Errorf("self-test encryption failed: %w", err)
+	}
+
+	_, header := e.sealingKey()
+	saltEnd := len(header) + e.saltSize
+	nonceEnd := saltEnd + nonceSize
+	if bytes.Equal(first[len(header):saltEnd], second[len(header):saltEnd]) || bytes.Equal(first[saltEnd:nonceEnd], second[saltEnd:nonceEnd]) {
+		return fmt.Errorf("random source returned repeated values, refusing to encrypt with reused nonces")
+	}
+
+	decrypted, err := e.Decrypt(first, "")
+	if err != nil {
+		return fmt.Errorf("self-test decryption failed: %w", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		return fmt.Errorf("self-test decryption returned different data")
 	}
+	return nil
 }
 
 func (e *Encryptor) Encrypt(data []byte, password string) ([]byte, error) {
+	return e.seal(data, password, 0)
+}
+
+// EncryptCompressed gzips data before encrypting it and sets the compressed
+// flag in the envelope header, so Decrypt decompresses it again. Data that
+// doesn't shrink is encrypted as is, without the flag.
+func (e *Encryptor) EncryptCompressed(data []byte, password string) ([]byte, error) {
+	compressed, err := compressPlaintext(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) >= len(data) {
+		return e.seal(data, password, 0)
+	}
+	return e.seal(compressed, password, envelopeCompressed)
+}
+
+// seal encrypts data under the sealing key with the given flags set in the
+// header's version byte
+func (e *Encryptor) seal(data []byte, password string, flags byte) ([]byte, error) {
 	logger.Debug("Encrypting data", map[string]interface{}{
 		"data_length": len(data),
 		"password":    password,
 	})
+	if e.saltSize < MinSaltSize || e.saltSize > MaxSaltSize {
+		return nil, fmt.Errorf("salt size %d is outside the supported range %d-%d", e.saltSize, MinSaltSize, MaxSaltSize)
+	}
+
 	// Generate a random salt
-	salt := make([]byte, saltSize)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+	salt := make([]byte, e.saltSize)
+	if _, err := io.ReadFull(e.random, salt); err != nil {
 		return nil, fmt.Errorf("failed to generate salt: %w", err)
 	}
 	logger.Debug("Generated salt", map[string]interface{}{
 		"salt": fmt.Sprintf("%x", salt),
 	})
 
-	// Derive key from password and salt
-	key := e.deriveKey(password, salt)
-	logger.Debug("Derived key", map[string]interface{}{
-		"key": fmt.Sprintf("%x", key),
-	})
-
-	// Create cipher block
-	block, err := aes.NewCipher(key)
+	key, header := e.sealingKey()
+	header[2] |= flags
+	gcm, err := e.newGCM(key, password, salt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	// Generate nonce
 	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	if _, err := io.ReadFull(e.random, nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	// Encrypt the data
-	ciphertext := gcm.Seal(nil, nonce, data, nil)
+	// Encrypt the data, authenticating the header with it
+	ciphertext := gcm.Seal(nil, nonce, data, header)
 
-	// Combine salt + nonce + ciphertext
-	result := make([]byte, 0, len(salt)+len(nonce)+len(ciphertext))
+	// Combine header + salt + nonce + ciphertext
+	result := make([]byte, 0, len(header)+len(salt)+len(nonce)+len(ciphertext))
+	result = append(result, header...)
 	result = append(result, salt...)
 	result = append(result, nonce...)
 	result = append(result, ciphertext...)
@@ -80,23 +182,149 @@ func (e *Encryptor) Encrypt(data []byte, password string) ([]byte, error) {
 	return result, nil
 }
 
+// sealingKey returns the key new data is encrypted under and the header
+// recording it
+func (e *Encryptor) sealingKey() ([]byte, []byte) {
+	header := append([]byte{}, envelopeMagic...)
+	if e.keyRing == nil {
+		return e.serverKey, append(header, envelopeVersion, byte(e.saltSize))
+	}
+	id := e.keyRing.PrimaryID()
+	header = append(header, envelopeKeyIDVersion, byte(e.saltSize), byte(len(id)))
+	return e.keyRing.keys[id], append(header, id...)
+}
+
+// IsCompressed reports whether encrypted data has an envelope header with the
+// compressed flag, which Decrypt undoes
+func IsCompressed(encrypted []byte) bool {
+	return len(encrypted) >= envelopeHeaderSize && bytes.HasPrefix(encrypted, envelopeMagic) && encrypted[2]&envelopeCompressed != 0
+}
+
+// DecryptFailures returns how many times Decrypt has failed, which usually
+// means data was encrypted under a different server key or is corrupt
+func (e *Encryptor) DecryptFailures() int64 {
+	return e.failures.Load()
+}
+
 func (e *Encryptor) Decrypt(encrypted []byte, password string) ([]byte, error) {
 	logger.Debug("Decrypting data", map[string]interface{}{
 		"data_length": len(encrypted),
 		"password":    password,
 	})
-	if len(encrypted) < saltSize+12 { // 12 is the minimum nonce size for GCM
+
+	plaintext, err := e.decrypt(encrypted, password)
+	if err != nil {
+		e.failures.Add(1)
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+func (e *Encryptor) decrypt(encrypted []byte, password string) ([]byte, error) {
+	if !bytes.HasPrefix(encrypted, envelopeMagic) {
+		return e.decryptLegacy(encrypted, password)
+	}
+
+	plaintext, err := e.decryptEnvelope(encrypted, password)
+	if err != nil {
+		// A legacy salt can start with the magic bytes by chance
+		if legacy, legacyErr := e.decryptLegacy(encrypted, password); legacyErr == nil {
+			return legacy, nil
+		}
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// decryptEnvelope decrypts data written with a header, checking the sizes it
+// claims against the data before slicing, and decompresses it if the header
+// has the compressed flag
+func (e *Encryptor) decryptEnvelope(encrypted []byte, password string) ([]byte, error) {
+	if len(encrypted) < envelopeHeaderSize {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+
+	key := e.serverKey
+	headerSize := envelopeHeaderSize
+	compressed := encrypted[2]&envelopeCompressed != 0
+	switch version := encrypted[2] &^ envelopeCompressed; version {
+	case envelopeVersion:
+	case envelopeKeyIDVersion:
+		if len(encrypted) < envelopeHeaderSize+1 {
+			return nil, fmt.Errorf("encrypted data is too short")
+		}
+		headerSize = envelopeHeaderSize + 1 + int(encrypted[envelopeHeaderSize])
+		if len(encrypted) < headerSize {
+			return nil, fmt.Errorf("encrypted data is too short")
+		}
+		var err error
+		if key, err = e.keyRing.key(string(encrypted[envelopeHeaderSize+1 : headerSize])); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encryption envelope version %d", version)
+	}
+
+	headerSaltSize := int(encrypted[3])
+	if headerSaltSize < MinSaltSize || headerSaltSize > MaxSaltSize {
+		return nil, fmt.Errorf("invalid salt size %d in encrypted data", headerSaltSize)
+	}
+
+	saltEnd := headerSize + headerSaltSize
+	if len(encrypted) < saltEnd {
 		return nil, fmt.Errorf("encrypted data is too short")
 	}
+	plaintext, err := e.open(key, password, encrypted[headerSize:saltEnd], encrypted[saltEnd:], encrypted[:headerSize])
+	if err != nil || !compressed {
+		return plaintext, err
+	}
+	return decompressPlaintext(plaintext)
+}
 
-	// Extract salt
-	salt := encrypted[:saltSize]
+// decryptLegacy decrypts data written before the header was introduced
+func (e *Encryptor) decryptLegacy(encrypted []byte, password string) ([]byte, error) {
+	if len(encrypted) < saltSize {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	return e.open(e.serverKey, password, encrypted[:saltSize], encrypted[saltSize:], nil)
+}
+
+// open decrypts nonce+ciphertext with the key derived from the server key,
+// password and salt
+func (e *Encryptor) open(serverKey []byte, password string, salt, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
 	logger.Debug("Extracted salt", map[string]interface{}{
 		"salt": fmt.Sprintf("%x", salt),
 	})
 
+	gcm, err := e.newGCM(serverKey, password, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+
+	// Extract nonce and ciphertext
+	nonce := sealed[:gcm.NonceSize()]
+	ciphertext := sealed[gcm.NonceSize():]
+
+	// Decrypt the data
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// newGCM creates the AES-GCM cipher for the key derived from the server key,
+// password and salt
+func (e *Encryptor) newGCM(serverKey []byte, password string, salt []byte) (cipher.AEAD, error) {
 	// Derive key from password and salt
-	key := e.deriveKey(password, salt)
+	key := deriveKey(serverKey, password, salt)
 	logger.Debug("Derived key", map[string]interface{}{
 		"key": fmt.Sprintf("%x", key),
 	})
@@ -112,29 +340,74 @@ func (e *Encryptor) Decrypt(encrypted []byte, password string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
+	return gcm, nil
+}
 
-	nonceSize := gcm.NonceSize()
-	if len(encrypted) < saltSize+nonceSize {
-		return nil, fmt.Errorf("encrypted data is too short")
-	}
+// KeyedHash returns a hex HMAC-SHA256 of data under the server key, so values
+// like custom names can be indexed on disk without being readable
+func (e *Encryptor) KeyedHash(data []byte) string {
+	mac := hmac.New(sha256.New, e.serverKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
 
-	// Extract nonce and ciphertext
-	nonce := encrypted[saltSize : saltSize+nonceSize]
-	ciphertext := encrypted[saltSize+nonceSize:]
+func deriveKey(serverKey []byte, password string, salt []byte) []byte {
+	// Combine password with server key for additional security
+	combinedPassword := append([]byte(password), serverKey...)
+	return pbkdf2.Key(combinedPassword, salt, iterations, keySize, sha256.New)
+}
 
-	// Decrypt the data
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt: %w", err)
+// Encoding selects the text encoding used to store server-side encrypted data
+type Encoding string
+
+const (
+	// EncodingBase64 is standard padded base64 (the historical default)
+	EncodingBase64 Encoding = "base64"
+	// EncodingBase64URL is unpadded URL-safe base64
+	EncodingBase64URL Encoding = "base64url"
+)
+
+// ParseEncoding resolves a configured encoding name, defaulting to base64
+func ParseEncoding(name string) (Encoding, error) {
+	switch Encoding(name) {
+	case "", EncodingBase64:
+		return EncodingBase64, nil
+	case EncodingBase64URL:
+		return EncodingBase64URL, nil
+	default:
+		return "", fmt.Errorf("unsupported ciphertext encoding %q", name)
 	}
+}
 
-	return plaintext, nil
+func (e Encoding) encoding() *base64.Encoding {
+	if e == EncodingBase64URL {
+		return base64.RawURLEncoding
+	}
+	return base64.StdEncoding
 }
 
-func (e *Encryptor) deriveKey(password string, salt []byte) []byte {
-	// Combine password with server key for additional security
-	combinedPassword := append([]byte(password), e.serverKey...)
-	return pbkdf2.Key(combinedPassword, salt, iterations, keySize, sha256.New)
+// EncodeToString encodes the encrypted data using the selected encoding
+func (e Encoding) EncodeToString(data []byte) string {
+	return e.encoding().EncodeToString(data)
+}
+
+// DecodeString decodes data written with the selected encoding. Data written
+// before the deployment switched encodings is still accepted, so existing
+// secrets remain readable after a change.
+func (e Encoding) DecodeString(s string) ([]byte, error) {
+	data, err := e.encoding().DecodeString(s)
+	if err == nil {
+		return data, nil
+	}
+
+	fallback := EncodingBase64URL
+	if e == EncodingBase64URL {
+		fallback = EncodingBase64
+	}
+	if data, fallbackErr := fallback.encoding().DecodeString(s); fallbackErr == nil {
+		return data, nil
+	}
+	return nil, err
 }
 
 // EncodeToString encodes the encrypted data to a base64 string
@@ -3,6 +3,7 @@ package encryption
 import (
 	"bytes"
 	"secrets-share/internal/logger"
+	"strings"
 	"testing"
 )
 
@@ -20,7 +21,8 @@ func setupTestLogger(t *testing.T) func() {
 		},
 	}
 
-	// Initialize logger
+	// Initialize logger, replacing one configured by an earlier test
+	logger.Reset()
 	err := logger.Init(cfg, false)
 	if err != nil {
 		t.Fatalf("Failed to initialize logger: %v", err)
@@ -170,4 +172,204 @@ func TestDecryptionWithInvalidData(t *testing.T) {
 	if err == nil {
 		t.Error("Expected decryption to fail with invalid data, but it succeeded")
 	}
+	if failures := encryptor.DecryptFailures(); failures != 1 {
+		t.Errorf("Expected 1 decryption failure to be counted, got %d", failures)
+	}
+}
+
+func TestConfigurableEncoding(t *testing.T) {
+	cleanup := setupTestLogger(t)
+	defer cleanup()
+
+	// Bytes chosen so the standard alphabet produces '+' and '/'
+	testData := []byte{0xfb, 0xff, 0xbf, 0x00, 0x01}
+
+	t.Run("Round trip both encodings", func(t *testing.T) {
+		for _, name := range []string{"base64", "base64url"} {
+			encoding, err := ParseEncoding(name)
+			if err != nil {
+				t.Fatalf("Failed to parse encoding %q: %v", name, err)
+			}
+
+			encoded := encoding.EncodeToString(testData)
+			decoded, err := encoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("Decoding %q failed: %v", name, err)
+			}
+			if !bytes.Equal(decoded, testData) {
+				t.Errorf("Decoded %q data does not match original data", name)
+			}
+		}
+	})
+
+	t.Run("Base64url output is URL safe", func(t *testing.T) {
+		encoded := EncodingBase64URL.EncodeToString(testData)
+		if strings.ContainsAny(encoded, "+/=") {
+			t.Errorf("Expected URL-safe output, got %q", encoded)
+		}
+	})
+
+	t.Run("Decoding accepts the other encoding", func(t *testing.T) {
+		decoded, err := EncodingBase64URL.DecodeString(EncodingBase64.EncodeToString(testData))
+		if err != nil || !bytes.Equal(decoded, testData) {
+			t.Errorf("Expected base64url decoder to read legacy base64 data, got err %v", err)
+		}
+
+		decoded, err = EncodingBase64.DecodeString(EncodingBase64URL.EncodeToString(testData))
+		if err != nil || !bytes.Equal(decoded, testData) {
+			t.Errorf("Expected base64 decoder to read base64url data, got err %v", err)
+		}
+	})
+
+	t.Run("Empty name defaults to base64", func(t *testing.T) {
+		encoding, err := ParseEncoding("")
+		if err != nil {
+			t.Fatalf("Failed to parse empty encoding: %v", err)
+		}
+		if encoding != EncodingBase64 {
+			t.Errorf("Expected base64, got %q", encoding)
+		}
+	})
+
+	t.Run("Unknown encoding is rejected", func(t *testing.T) {
+		if _, err := ParseEncoding("hex"); err == nil {
+			t.Error("Expected an error for an unsupported encoding")
+		}
+	})
+}
+
+// constantReader stands in for a broken random source
+type constantReader struct{}
+
+func (constantReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0x42
+	}
+	return len(p), nil
+}
+
+func TestSelfTest(t *testing.T) {
+	cleanup := setupTestLogger(t)
+	defer cleanup()
+
+	t.Run("Healthy random source passes", func(t *testing.T) {
+		if err := NewEncryptor("test-server-key").SelfTest(); err != nil {
+			t.Errorf("Self-test failed: %v", err)
+		}
+	})
+
+	t.Run("Constant random source is detected", func(t *testing.T) {
+		encryptor := NewEncryptor("test-server-key")
+		encryptor.random = constantReader{}
+
+		if err := encryptor.SelfTest(); err == nil {
+			t.Error("Expected self-test to detect repeated nonces")
+		}
+	})
+}
+
+func TestSaltSize(t *testing.T) {
+	cleanup := setupTestLogger(t)
+	defer cleanup()
+
+	data := []byte("salted secret")
+	password := "test-password"
+
+	t.Run("Configured size is recorded in the header", func(t *testing.T) {
+		encryptor := NewEncryptor("test-server-key", WithSaltSize(32))
+		encrypted, err := encryptor.Encrypt(data, password)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		if encrypted[3] != 32 {
+			t.Errorf("Expected header salt size 32, got %d", encrypted[3])
+		}
+
+		// Decryption follows the header, not the encryptor's own setting
+		decrypted, err := NewEncryptor("test-server-key").Decrypt(encrypted, password)
+		if err != nil {
+			t.Fatalf("Decryption failed: %v", err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("Decrypted data doesn't match original")
+		}
+		if err := encryptor.SelfTest(); err != nil {
+			t.Errorf("Self-test failed with custom salt size: %v", err)
+		}
+	})
+
+	t.Run("Out of range sizes are rejected", func(t *testing.T) {
+		for _, size := range []int{8, MaxSaltSize + 1} {
+			encryptor := NewEncryptor("test-server-key", WithSaltSize(size))
+			if _, err := encryptor.Encrypt(data, password); err == nil {
+				t.Errorf("Expected salt size %d to be rejected", size)
+			}
+			if err := encryptor.SelfTest(); err == nil {
+				t.Errorf("Expected self-test to fail with salt size %d", size)
+			}
+		}
+	})
+
+	t.Run("Data without a header still decrypts", func(t *testing.T) {
+		encryptor := NewEncryptor("test-server-key")
+		salt := bytes.Repeat([]byte{0x01}, saltSize)
+		nonce := bytes.Repeat([]byte{0x02}, nonceSize)
+		gcm, err := encryptor.newGCM(encryptor.serverKey, password, salt)
+		if err != nil {
+			t.Fatalf("Failed to create cipher: %v", err)
+		}
+		legacy := append(append(append([]byte{}, salt...), nonce...), gcm.Seal(nil, nonce, data, nil)...)
+
+		decrypted, err := encryptor.Decrypt(legacy, password)
+		if err != nil {
+			t.Fatalf("Legacy decryption failed: %v", err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("Decrypted data doesn't match original")
+		}
+	})
+}
+
+func TestDecryptMalformedData(t *testing.T) {
+	cleanup := setupTestLogger(t)
+	defer cleanup()
+
+	encryptor := NewEncryptor("test-server-key")
+	password := "test-password"
+	encrypted, err := encryptor.Encrypt([]byte("well-formed secret"), password)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	t.Run("Truncated data", func(t *testing.T) {
+		for n := 0; n < len(encrypted); n++ {
+			if _, err := encryptor.Decrypt(encrypted[:n], password); err == nil {
+				t.Errorf("Expected decryption of %d-byte prefix to fail", n)
+			}
+		}
+	})
+
+	tamper := func(offset int, value byte) []byte {
+		data := append([]byte{}, encrypted...)
+		data[offset] = value
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Salt size larger than the data", tamper(3, 0xff)},
+		{"Salt size larger than the maximum", tamper(3, MaxSaltSize+1)},
+		{"Salt size smaller than the minimum", tamper(3, 4)},
+		{"Unknown version", tamper(2, 0x7f)},
+		{"Header only", append([]byte{}, encrypted[:envelopeHeaderSize]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := encryptor.Decrypt(tt.data, password); err == nil {
+				t.Error("Expected decryption to fail")
+			}
+		})
+	}
 }
@@ -0,0 +1,68 @@
+package encryption
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"secrets-share/internal/logger"
+)
+
+// ServerKeyEnv is the environment variable holding the server encryption key
+const ServerKeyEnv = "SERVER_ENCRYPTION_KEY"
+
+// KeyProvider supplies the server encryption key. Implementations can fetch
+// the key from other sources such as a secret manager.
+type KeyProvider interface {
+	Key() (string, error)
+}
+
+// EnvKeyProvider reads the key from an environment variable
+type EnvKeyProvider struct {
+	Name string
+}
+
+func (p EnvKeyProvider) Key() (string, error) {
+	return os.Getenv(p.Name), nil
+}
+
+// FileKeyProvider reads the key from a file, keeping it out of the process
+// environment. A trailing newline is ignored.
+type FileKeyProvider struct {
+	Path string
+}
+
+func (p FileKeyProvider) Key() (string, error) {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat encryption key file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("encryption key file %s is not a regular file", p.Path)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		logger.Warn("Encryption key file is accessible by other users", map[string]interface{}{
+			"path":        p.Path,
+			"permissions": fmt.Sprintf("%#o", perm),
+		})
+	}
+
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+	key := strings.TrimRight(string(data), "\r\n")
+	if key == "" {
+		return "", fmt.Errorf("encryption key file %s is empty", p.Path)
+	}
+	return key, nil
+}
+
+// NewKeyProvider returns a file provider when a key file is configured and
+// falls back to the SERVER_ENCRYPTION_KEY environment variable otherwise
+func NewKeyProvider(keyFile string) KeyProvider {
+	if keyFile != "" {
+		return FileKeyProvider{Path: keyFile}
+	}
+	return EnvKeyProvider{Name: ServerKeyEnv}
+}
@@ -0,0 +1,95 @@
+package encryption
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"secrets-share/internal/logger"
+)
+
+func TestEnvKeyProvider(t *testing.T) {
+	t.Setenv(ServerKeyEnv, "env-server-key")
+
+	provider := NewKeyProvider("")
+	if _, ok := provider.(EnvKeyProvider); !ok {
+		t.Fatalf("Expected env provider without a key file, got %T", provider)
+	}
+
+	key, err := provider.Key()
+	if err != nil {
+		t.Fatalf("Key failed: %v", err)
+	}
+	if key != "env-server-key" {
+		t.Errorf("Expected key from environment, got %q", key)
+	}
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	var output bytes.Buffer
+	testLogger, err := logger.NewLogger(&logger.Config{
+		Enabled: true,
+		Stdout:  true,
+		Output:  &output,
+		Files: map[string]logger.FileConfig{
+			"application": {Filename: "app.log"},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.SetDefault(logger.SetDefault(testLogger))
+
+	writeKey := func(t *testing.T, content string, perm os.FileMode) string {
+		path := filepath.Join(t.TempDir(), "server.key")
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatalf("Failed to write key file: %v", err)
+		}
+		// WriteFile is subject to the umask
+		if err := os.Chmod(path, perm); err != nil {
+			t.Fatalf("Failed to chmod key file: %v", err)
+		}
+		return path
+	}
+
+	t.Run("Reads key and trims newline", func(t *testing.T) {
+		output.Reset()
+		key, err := NewKeyProvider(writeKey(t, "file-server-key\n", 0600)).Key()
+		if err != nil {
+			t.Fatalf("Key failed: %v", err)
+		}
+		if key != "file-server-key" {
+			t.Errorf("Expected key from file, got %q", key)
+		}
+		if output.Len() != 0 {
+			t.Errorf("Expected no warning for private key file, got %s", output.String())
+		}
+	})
+
+	t.Run("Warns when permissions are too open", func(t *testing.T) {
+		output.Reset()
+		key, err := NewKeyProvider(writeKey(t, "file-server-key", 0644)).Key()
+		if err != nil {
+			t.Fatalf("Key failed: %v", err)
+		}
+		if key != "file-server-key" {
+			t.Errorf("Expected key from file, got %q", key)
+		}
+		if !bytes.Contains(output.Bytes(), []byte("accessible by other users")) {
+			t.Errorf("Expected permission warning, got %q", output.String())
+		}
+	})
+
+	t.Run("Empty file is an error", func(t *testing.T) {
+		if _, err := NewKeyProvider(writeKey(t, "\n", 0600)).Key(); err == nil {
+			t.Error("Expected error for empty key file")
+		}
+	})
+
+	t.Run("Missing file is an error", func(t *testing.T) {
+		if _, err := NewKeyProvider(filepath.Join(t.TempDir(), "missing.key")).Key(); err == nil {
+			t.Error("Expected error for missing key file")
+		}
+	})
+}
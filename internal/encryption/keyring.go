@@ -0,0 +1,58 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownKeyID is returned when encrypted data names a key ID that is not
+// in the key ring
+var ErrUnknownKeyID = errors.New("unknown encryption key ID")
+
+// maxKeyIDLength is the longest key ID the one-byte length in the header allows
+const maxKeyIDLength = 255
+
+// KeyRing maps key IDs to server keys. New data is encrypted under the
+// primary key and records its ID, so data written under any key in the ring
+// stays readable after the primary changes.
+type KeyRing struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyRing returns a key ring holding keys by ID, encrypting with the key
+// named primary
+func NewKeyRing(primary string, keys map[string]string) (*KeyRing, error) {
+	ring := &KeyRing{
+		primary: primary,
+		keys:    make(map[string][]byte, len(keys)),
+	}
+	for id, key := range keys {
+		if id == "" || len(id) > maxKeyIDLength {
+			return nil, fmt.Errorf("encryption key ID must be 1-%d bytes long", maxKeyIDLength)
+		}
+		if key == "" {
+			return nil, fmt.Errorf("encryption key %q is empty", id)
+		}
+		ring.keys[id] = []byte(key)
+	}
+	if _, ok := ring.keys[primary]; !ok {
+		return nil, fmt.Errorf("primary encryption key %q is not in the key ring", primary)
+	}
+	return ring, nil
+}
+
+// PrimaryID returns the ID of the key new data is encrypted under
+func (r *KeyRing) PrimaryID() string {
+	return r.primary
+}
+
+// key returns the key with the given ID
+func (r *KeyRing) key(id string) ([]byte, error) {
+	if r != nil {
+		if key, ok := r.keys[id]; ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKeyID, id)
+}
@@ -0,0 +1,121 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestKeyRing(t *testing.T) {
+	cleanup := setupTestLogger(t)
+	defer cleanup()
+
+	data := []byte("tenant secret")
+	password := "test-password"
+	keys := map[string]string{
+		"tenant-a": "tenant-a-server-key",
+		"tenant-b": "tenant-b-server-key",
+	}
+
+	newEncryptor := func(primary string) *Encryptor {
+		ring, err := NewKeyRing(primary, keys)
+		if err != nil {
+			t.Fatalf("Failed to create key ring: %v", err)
+		}
+		return NewEncryptor(keys[primary], WithKeyRing(ring))
+	}
+
+	t.Run("Decrypts by the key ID in the header", func(t *testing.T) {
+		encrypted, err := newEncryptor("tenant-a").Encrypt(data, password)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		if !bytes.Contains(encrypted[:envelopeHeaderSize+1+len("tenant-a")], []byte("tenant-a")) {
+			t.Error("Expected the key ID in the header")
+		}
+
+		// The primary has moved on, but the ring still holds the old key
+		decrypted, err := newEncryptor("tenant-b").Decrypt(encrypted, password)
+		if err != nil {
+			t.Fatalf("Decryption failed: %v", err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("Decrypted data doesn't match original")
+		}
+	})
+
+	t.Run("Unknown key ID", func(t *testing.T) {
+		ring, err := NewKeyRing("tenant-c", map[string]string{"tenant-c": "tenant-c-server-key"})
+		if err != nil {
+			t.Fatalf("Failed to create key ring: %v", err)
+		}
+		encrypted, err := NewEncryptor("tenant-c-server-key", WithKeyRing(ring)).Encrypt(data, password)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+
+		if _, err := newEncryptor("tenant-a").Decrypt(encrypted, password); !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("Expected ErrUnknownKeyID, got %v", err)
+		}
+		if _, err := NewEncryptor("tenant-c-server-key").Decrypt(encrypted, password); !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("Expected ErrUnknownKeyID without a key ring, got %v", err)
+		}
+	})
+
+	t.Run("Data without a key ID uses the server key", func(t *testing.T) {
+		encrypted, err := NewEncryptor(keys["tenant-a"]).Encrypt(data, password)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		decrypted, err := newEncryptor("tenant-a").Decrypt(encrypted, password)
+		if err != nil {
+			t.Fatalf("Decryption failed: %v", err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("Decrypted data doesn't match original")
+		}
+	})
+
+	t.Run("Tampered key ID fails", func(t *testing.T) {
+		encrypted, err := newEncryptor("tenant-a").Encrypt(data, password)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		// Pointing the header at another key in the ring must not decrypt
+		tampered := bytes.Replace(encrypted, []byte("tenant-a"), []byte("tenant-b"), 1)
+		if _, err := newEncryptor("tenant-a").Decrypt(tampered, password); err == nil {
+			t.Error("Expected decryption to fail")
+		}
+	})
+
+	t.Run("Truncated data", func(t *testing.T) {
+		encryptor := newEncryptor("tenant-a")
+		encrypted, err := encryptor.Encrypt(data, password)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		for n := 0; n < len(encrypted); n++ {
+			if _, err := encryptor.Decrypt(encrypted[:n], password); err == nil {
+				t.Errorf("Expected decryption of %d-byte prefix to fail", n)
+			}
+		}
+	})
+
+	t.Run("Self-test with a key ring", func(t *testing.T) {
+		if err := newEncryptor("tenant-a").SelfTest(); err != nil {
+			t.Errorf("Self-test failed: %v", err)
+		}
+	})
+
+	t.Run("Invalid rings are rejected", func(t *testing.T) {
+		if _, err := NewKeyRing("missing", keys); err == nil {
+			t.Error("Expected a missing primary key to be rejected")
+		}
+		if _, err := NewKeyRing("", map[string]string{"": "key"}); err == nil {
+			t.Error("Expected an empty key ID to be rejected")
+		}
+		if _, err := NewKeyRing("tenant-a", map[string]string{"tenant-a": ""}); err == nil {
+			t.Error("Expected an empty key to be rejected")
+		}
+	})
+}
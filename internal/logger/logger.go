@@ -1,13 +1,20 @@
 package logger
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"secrets-share/internal/config"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -30,20 +37,48 @@ type Logger struct {
 	writers    map[string]io.Writer
 	mu         sync.Mutex
 	production bool
+	auditKey   []byte
+	logDir     string
+	archiveDir string
+	written    int64          // Bytes written since old log files were last pruned
+	timeLayout string         // Layout for entry timestamps
+	location   *time.Location // Time zone for entry timestamps
 }
 
 type Config struct {
-	Enabled        bool
-	ConsoleOutput  bool
-	Directory      string
-	ArchiveDir     string
-	RotationSizeMB int
-	RetentionDays  int
-	Files          map[string]FileConfig
+	Enabled          bool
+	ConsoleOutput    bool
+	Stdout           bool      // Write every log type to Output instead of rotated files
+	Output           io.Writer // Destination for stdout mode, defaults to os.Stdout
+	Directory        string
+	ArchiveDir       string
+	RotationSizeMB   int
+	RetentionDays    int
+	MaxBackups       int  // Rotated files kept per log, 0 for the default of 10
+	MaxTotalMB       int  // Cap on the total size of rotated and archived logs, 0 for no cap
+	SlowRequestMS    int  // Requests taking longer are also logged at warn level, 0 to disable
+	AccessErrorsOnly bool // Skip 2xx responses, log 3xx/4xx at info and 5xx at warn in the access log
+
+	// TimestampFormat is "RFC3339" (the default), "RFC3339Nano" or a Go time
+	// layout such as "2006-01-02 15:04:05.000"
+	TimestampFormat string
+	// Timezone is an IANA name such as "Europe/Warsaw" or "Local", UTC when empty
+	Timezone string
+	Files    map[string]FileConfig
+	AuditKey string // Key for hashing audit identifiers, random per process when empty
 }
 
+// FileConfig names one log file. Enabled is a pointer so that leaving it
+// unset, as the zero value does, keeps the file on; only an explicit false
+// drops everything logged to it.
 type FileConfig struct {
 	Filename string
+	Enabled  *bool
+}
+
+// enabled reports whether entries are written to the file
+func (f FileConfig) enabled() bool {
+	return f.Enabled == nil || *f.Enabled
 }
 
 type LogEntry struct {
@@ -67,7 +102,41 @@ func Init(cfg *Config, production bool) error {
 	return err
 }
 
+// Reset discards the default logger so the next Init configures a new one.
+// Init only takes effect once per process, so tests that need a differently
+// configured default logger call Reset first.
+func Reset() {
+	defaultLogger = nil
+	once = sync.Once{}
+}
+
+// SetDefault replaces the logger used by the package-level helpers and returns
+// the previous one
+func SetDefault(l *Logger) *Logger {
+	previous := defaultLogger
+	defaultLogger = l
+	return previous
+}
+
 func NewLogger(cfg *Config, production bool) (*Logger, error) {
+	auditKey, err := newAuditKey(cfg.AuditKey)
+	if err != nil {
+		return nil, err
+	}
+	timeLayout := timestampLayout(cfg.TimestampFormat)
+	location, err := timestampLocation(cfg.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Stdout {
+		l := newStdoutLogger(cfg, production)
+		l.auditKey = auditKey
+		l.timeLayout = timeLayout
+		l.location = location
+		return l, nil
+	}
+
 	// Get the project root directory (where the config.yaml is located)
 	projectRoot, err := os.Getwd()
 	if err != nil {
@@ -90,32 +159,134 @@ func NewLogger(cfg *Config, production bool) (*Logger, error) {
 		config:     cfg,
 		writers:    make(map[string]io.Writer),
 		production: production,
+		auditKey:   auditKey,
+		logDir:     logDir,
+		archiveDir: archiveDir,
+		timeLayout: timeLayout,
+		location:   location,
+	}
+
+	maxBackups := cfg.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxBackups
 	}
 
 	// Configure writers for each log file
 	for name, fileCfg := range cfg.Files {
+		// Disabled sinks keep their entry but drop everything written to them
+		if !fileCfg.enabled() {
+			l.writers[name] = io.Discard
+			continue
+		}
+
 		logPath := filepath.Join(logDir, fileCfg.Filename)
 		writer := &lumberjack.Logger{
 			Filename:   logPath,
 			MaxSize:    cfg.RotationSizeMB,
 			MaxAge:     cfg.RetentionDays,
-			MaxBackups: 10, // Keep at most 10 old files
+			MaxBackups: maxBackups,
 			Compress:   true,
 			LocalTime:  true,
 		}
 		l.writers[name] = writer
 	}
 
+	// Files left over from earlier runs may already exceed the cap
+	if err := l.pruneLogs(); err != nil {
+		return nil, err
+	}
+
 	return l, nil
 }
 
+// timestampLayout resolves logging.timestamp_format to a time layout
+func timestampLayout(format string) string {
+	switch format {
+	case "", "RFC3339":
+		return time.RFC3339
+	case "RFC3339Nano":
+		return time.RFC3339Nano
+	default:
+		return format
+	}
+}
+
+// timestampLocation resolves logging.timezone, defaulting to UTC
+func timestampLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logging timezone %q: %w", name, err)
+	}
+	return location, nil
+}
+
+// timestamp renders t in the configured time zone and format
+func (l *Logger) timestamp(t time.Time) string {
+	location, layout := l.location, l.timeLayout
+	if location == nil {
+		location = time.UTC
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return t.In(location).Format(layout)
+}
+
+// newAuditKey returns the configured audit hashing key, or a random one so that
+// audit identifiers can still be correlated within a single process
+func newAuditKey(configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate audit key: %v", err)
+	}
+	return key, nil
+}
+
+// newStdoutLogger creates a logger that writes all log types as JSON lines to a
+// single stream, for platforms that collect container output
+func newStdoutLogger(cfg *Config, production bool) *Logger {
+	output := cfg.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	l := &Logger{
+		config:     cfg,
+		writers:    make(map[string]io.Writer),
+		production: production,
+	}
+	for name, fileCfg := range cfg.Files {
+		if !fileCfg.enabled() {
+			l.writers[name] = io.Discard
+			continue
+		}
+		l.writers[name] = output
+	}
+
+	return l
+}
+
 func (l *Logger) log(level LogLevel, logType string, message string, data interface{}) {
+	if l == nil {
+		// Logger was never initialized; surface warnings and errors on stderr
+		// instead of dereferencing a nil logger
+		if level >= WarnLevel {
+			writeFallback(level, logType, message, data)
+		}
+		return
+	}
 	if !l.config.Enabled {
 		return
 	}
 
 	entry := LogEntry{
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Timestamp: l.timestamp(time.Now()),
 		Level:     level.String(),
 		Message:   message,
 		Type:      logType,
@@ -133,17 +304,63 @@ func (l *Logger) log(level LogLevel, logType string, message string, data interf
 
 	// Write to appropriate log file
 	if writer, ok := l.writers[logType]; ok {
-		if _, err := writer.Write(append(jsonData, '\n')); err != nil {
+		n, err := writer.Write(append(jsonData, '\n'))
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to log file: %v\n", err)
 		}
+		l.trackWrite(n)
 	}
 
-	// Write to console in development mode
-	if !l.production && l.config.ConsoleOutput {
+	// Write to console in development mode (stdout mode already does)
+	if !l.production && l.config.ConsoleOutput && !l.config.Stdout {
 		fmt.Println(string(jsonData))
 	}
 }
 
+// Rotate starts a new file for every rotating log writer, moving the current
+// file aside as a backup. External tools such as logrotate that rename the
+// files from under the logger use this to make it reopen them. Stdout and
+// disabled sinks are left alone.
+func (l *Logger) Rotate() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for name, writer := range l.writers {
+		rotator, ok := writer.(interface{ Rotate() error })
+		if !ok {
+			continue
+		}
+		if err := rotator.Rotate(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to rotate %s log: %w", name, err))
+		}
+	}
+	if err := l.pruneLogs(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// writeFallback writes a log entry to stderr when no logger is available
+func writeFallback(level LogLevel, logType string, message string, data interface{}) {
+	jsonData, err := json.Marshal(LogEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Level:     level.String(),
+		Message:   message,
+		Type:      logType,
+		Data:      data,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling log entry: %v\n", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(jsonData))
+}
+
 func (l LogLevel) String() string {
 	switch l {
 	case DebugLevel:
@@ -161,27 +378,98 @@ func (l LogLevel) String() string {
 
 // Helper functions for the default logger
 func Debug(message string, data interface{}) {
-	defaultLogger.log(DebugLevel, "application", message, data)
+	defaultLogger.Debug(message, data)
 }
 
 func Info(message string, data interface{}) {
-	defaultLogger.log(InfoLevel, "application", message, data)
+	defaultLogger.Info(message, data)
 }
 
 func Warn(message string, data interface{}) {
-	defaultLogger.log(WarnLevel, "application", message, data)
+	defaultLogger.Warn(message, data)
 }
 
 func Error(message string, data interface{}) {
-	defaultLogger.log(ErrorLevel, "error", message, data)
+	defaultLogger.Error(message, data)
 }
 
 func Access(message string, data interface{}) {
-	defaultLogger.log(InfoLevel, "access", message, data)
+	defaultLogger.Access(message, data)
 }
 
 func RateLimit(message string, data interface{}) {
-	defaultLogger.log(InfoLevel, "ratelimit", message, data)
+	defaultLogger.RateLimit(message, data)
+}
+
+// Rotate starts new files for the default logger
+func Rotate() error {
+	return defaultLogger.Rotate()
+}
+
+// Methods for loggers used directly rather than through the default logger.
+// Like the helpers, they are safe to call on a nil Logger.
+func (l *Logger) Debug(message string, data interface{}) {
+	l.log(DebugLevel, "application", message, data)
+}
+
+func (l *Logger) Info(message string, data interface{}) {
+	l.log(InfoLevel, "application", message, data)
+}
+
+func (l *Logger) Warn(message string, data interface{}) {
+	l.log(WarnLevel, "application", message, data)
+}
+
+func (l *Logger) Error(message string, data interface{}) {
+	l.log(ErrorLevel, "error", message, data)
+}
+
+func (l *Logger) Access(message string, data interface{}) {
+	l.log(InfoLevel, "access", message, data)
+}
+
+func (l *Logger) RateLimit(message string, data interface{}) {
+	l.log(InfoLevel, "ratelimit", message, data)
+}
+
+// Audit records a secret lifecycle event (create, view, burn, expire). The
+// secret ID and client IP are only ever written as keyed hashes, and no other
+// data apart from an age is accepted so content and keys cannot end up in the
+// audit log.
+func Audit(event string, secretID string, ip string) {
+	defaultLogger.audit(event, secretID, ip, nil)
+}
+
+// AuditAge records a secret lifecycle event like Audit, along with the
+// secret's age in whole seconds
+func AuditAge(event string, secretID string, ip string, age time.Duration) {
+	defaultLogger.audit(event, secretID, ip, &age)
+}
+
+func (l *Logger) audit(event string, secretID string, ip string, age *time.Duration) {
+	if l == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"event":     event,
+		"secret_id": l.hashIdentifier(secretID),
+	}
+	if ip != "" {
+		data["ip_hash"] = l.hashIdentifier(ip)
+	}
+	if age != nil {
+		data["age_seconds"] = int64(age.Seconds())
+	}
+	l.log(InfoLevel, "audit", "Secret "+event, data)
+}
+
+// hashIdentifier returns a keyed hash of value, so identifiers can be
+// correlated across audit entries without being recoverable
+func (l *Logger) hashIdentifier(value string) string {
+	mac := hmac.New(sha256.New, l.auditKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
 }
 
 // LogStartupInfo prints server startup information in a nice ASCII format
@@ -201,26 +489,7 @@ func LogStartupInfo(cfg interface{}, redisConnected bool, envVars map[string]str
 	osInfo := fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
 	hostname, _ := os.Hostname()
 
-	// Format environment variables
-	var envList []string
-	sensitiveKeys := []string{"key", "secret", "password", "token"}
-	for k, v := range envVars {
-		shouldRedact := false
-		lowerKey := strings.ToLower(k)
-		for _, sensitive := range sensitiveKeys {
-			if strings.Contains(lowerKey, sensitive) {
-				shouldRedact = true
-				break
-			}
-		}
-		if v != "" { // Only show set variables
-			if shouldRedact {
-				envList = append(envList, fmt.Sprintf("%s=********", k))
-			} else {
-				envList = append(envList, fmt.Sprintf("%s=%s", k, v))
-			}
-		}
-	}
+	envList := formatEnvVars(envVars, serverCfg.Logging.StartupEnv)
 
 	// Format the output
 	fmt.Printf("\033[1;33m=== Server Information ===\033[0m\n")
@@ -245,6 +514,49 @@ func LogStartupInfo(cfg interface{}, redisConnected bool, envVars map[string]str
 	fmt.Printf("\033[1;32m=== Server is ready ===\033[0m\n\n")
 }
 
+// formatEnvVars returns the set variables as sorted KEY=value lines, masking
+// the values that the redaction settings select
+func formatEnvVars(envVars map[string]string, redaction config.EnvRedactionConfig) []string {
+	var envList []string
+	for k, v := range envVars {
+		if v == "" { // Only show set variables
+			continue
+		}
+		if shouldRedactEnv(k, redaction) {
+			envList = append(envList, fmt.Sprintf("%s=********", k))
+		} else {
+			envList = append(envList, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	sort.Strings(envList)
+	return envList
+}
+
+// shouldRedactEnv reports whether the value of key must be masked
+func shouldRedactEnv(key string, redaction config.EnvRedactionConfig) bool {
+	for _, allowed := range redaction.Allow {
+		if strings.EqualFold(key, allowed) {
+			return false
+		}
+	}
+	for _, redacted := range redaction.Redact {
+		if strings.EqualFold(key, redacted) {
+			return true
+		}
+	}
+	if redaction.RedactAll {
+		return true
+	}
+
+	lowerKey := strings.ToLower(key)
+	for _, sensitive := range redaction.SensitiveSubstrings {
+		if sensitive != "" && strings.Contains(lowerKey, strings.ToLower(sensitive)) {
+			return true
+		}
+	}
+	return false
+}
+
 // formatStatus returns a colored status string
 func formatStatus(enabled bool) string {
 	if enabled {
@@ -266,15 +578,56 @@ func GinLogger() gin.HandlerFunc {
 			path = path + "?" + raw
 		}
 
+		latency := time.Since(start)
 		data := map[string]interface{}{
 			"status":     c.Writer.Status(),
 			"method":     c.Request.Method,
 			"path":       path,
 			"ip":         c.ClientIP(),
-			"latency":    time.Since(start).String(),
+			"latency":    latency.String(),
 			"user_agent": c.Request.UserAgent(),
 		}
 
-		Access(fmt.Sprintf("%s %s", c.Request.Method, path), data)
+		if level, ok := defaultLogger.accessLevel(c.Writer.Status()); ok {
+			defaultLogger.log(level, "access", fmt.Sprintf("%s %s", c.Request.Method, path), data)
+		}
+
+		// Slow requests get a second, warn-level entry that is easy to alert on
+		if threshold := defaultLogger.slowRequestThreshold(); threshold > 0 && latency > threshold {
+			slow := make(map[string]interface{}, len(data)+2)
+			for key, value := range data {
+				slow[key] = value
+			}
+			slow["slow"] = true
+			slow["latency_ms"] = latency.Milliseconds()
+			defaultLogger.log(WarnLevel, "access", fmt.Sprintf("Slow request: %s %s", c.Request.Method, path), slow)
+		}
+	}
+}
+
+// accessLevel returns the level to log a response with the given status at,
+// and false if it is left out. With access_errors_only successful responses
+// are skipped, redirects and client errors stay at info and server errors are
+// raised to warn
+func (l *Logger) accessLevel(status int) (LogLevel, bool) {
+	if l == nil || !l.config.AccessErrorsOnly {
+		return InfoLevel, true
+	}
+	switch {
+	case status >= http.StatusInternalServerError:
+		return WarnLevel, true
+	case status >= http.StatusMultipleChoices:
+		return InfoLevel, true
+	default:
+		return InfoLevel, false
+	}
+}
+
+// slowRequestThreshold returns the latency above which requests are logged as
+// slow, or zero if slow request logging is off
+func (l *Logger) slowRequestThreshold() time.Duration {
+	if l == nil {
+		return 0
 	}
+	return time.Duration(l.config.SlowRequestMS) * time.Millisecond
 }
@@ -4,9 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"secrets-share/internal/config"
+	"strings"
 	"testing"
+	"time"
+	_ "time/tzdata" // Time zones for TestTimestampFormat, whatever the host has
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // testWriter is a simple io.Writer for testing
@@ -193,3 +202,714 @@ func TestLoggerDisabled(t *testing.T) {
 		t.Error("Expected no output when logging is disabled")
 	}
 }
+
+func TestUninitializedLogger(t *testing.T) {
+	previous := defaultLogger
+	defaultLogger = nil
+	defer func() { defaultLogger = previous }()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Logging before Init panicked: %v", r)
+		}
+	}()
+
+	Debug("debug before init", nil)
+	Info("info before init", nil)
+	Warn("warn before init", map[string]interface{}{"key": "value"})
+	Error("error before init", nil)
+	Access("access before init", nil)
+	RateLimit("ratelimit before init", nil)
+}
+
+func TestDisabledLogFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "logger-disabled-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	disabled := false
+
+	cfg := &Config{
+		Enabled:    true,
+		Directory:  tmpDir,
+		ArchiveDir: filepath.Join(tmpDir, "archive"),
+		Files: map[string]FileConfig{
+			"application": {Filename: "app.log"},
+			"access":      {Filename: "access.log", Enabled: &disabled},
+		},
+	}
+
+	logger, err := NewLogger(cfg, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	if logger.writers["access"] != io.Discard {
+		t.Fatal("Expected disabled access log to use a discard writer")
+	}
+	// A file that leaves Enabled unset keeps logging, as before the option existed
+	if _, ok := logger.writers["application"].(*lumberjack.Logger); !ok {
+		t.Fatalf("Expected the application log to write to a file, got %T", logger.writers["application"])
+	}
+
+	// Capture the enabled sink
+	tw := &testWriter{}
+	logger.writers["application"] = tw
+
+	logger.log(InfoLevel, "access", "access message", nil)
+	if tw.String() != "" {
+		t.Error("Expected no output from the disabled access log")
+	}
+
+	logger.log(InfoLevel, "application", "application message", nil)
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(tw.String()), &entry); err != nil {
+		t.Fatalf("Failed to parse log entry: %v", err)
+	}
+	if entry.Message != "application message" {
+		t.Errorf("Expected application message, got %q", entry.Message)
+	}
+}
+
+func TestStdoutLogger(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "logger-stdout-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tw := &testWriter{}
+	logDir := filepath.Join(tmpDir, "logs")
+	cfg := &Config{
+		Enabled:       true,
+		ConsoleOutput: true,
+		Stdout:        true,
+		Output:        tw,
+		Directory:     logDir,
+		ArchiveDir:    filepath.Join(logDir, "archive"),
+		Files: map[string]FileConfig{
+			"application": {Filename: "app.log"},
+			"error":       {Filename: "error.log"},
+			"access":      {Filename: "access.log"},
+			"ratelimit":   {Filename: "ratelimit.log"},
+		},
+	}
+
+	logger, err := NewLogger(cfg, false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	types := []string{"application", "error", "access", "ratelimit"}
+	for _, logType := range types {
+		logger.log(InfoLevel, logType, logType+" message", nil)
+	}
+
+	lines := strings.Split(strings.TrimSpace(tw.String()), "\n")
+	if len(lines) != len(types) {
+		t.Fatalf("Expected %d lines, got %d: %q", len(types), len(lines), tw.String())
+	}
+	for i, line := range lines {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse log entry %q: %v", line, err)
+		}
+		if entry.Type != types[i] {
+			t.Errorf("Expected type %s, got %s", types[i], entry.Type)
+		}
+	}
+
+	// No log files or directories are created in stdout mode
+	if _, err := os.Stat(logDir); !os.IsNotExist(err) {
+		t.Error("Expected no log directory in stdout mode")
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	tw := &testWriter{}
+	newAuditLogger := func(key string) *Logger {
+		logger, err := NewLogger(&Config{
+			Enabled:  true,
+			Stdout:   true,
+			Output:   tw,
+			AuditKey: key,
+			Files: map[string]FileConfig{
+				"audit": {Filename: "audit.log"},
+			},
+		}, true)
+		if err != nil {
+			t.Fatalf("Failed to create logger: %v", err)
+		}
+		return logger
+	}
+
+	secretID := "0b5c6f5e-6d7a-4c53-9a1e-2f0d8a3b9c11"
+	ip := "203.0.113.7"
+
+	logger := newAuditLogger("audit-key")
+	logger.audit("view", secretID, ip, nil)
+
+	output := tw.String()
+	if strings.Contains(output, secretID) || strings.Contains(output, ip) {
+		t.Fatalf("Audit entry contains a raw identifier: %s", output)
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entry); err != nil {
+		t.Fatalf("Failed to parse audit entry: %v", err)
+	}
+	if entry.Type != "audit" {
+		t.Errorf("Expected audit type, got %s", entry.Type)
+	}
+	data := entry.Data.(map[string]interface{})
+	if data["event"] != "view" {
+		t.Errorf("Expected view event, got %v", data["event"])
+	}
+
+	// Hashes are stable for a key, so entries about one secret can be correlated
+	if data["secret_id"] != logger.hashIdentifier(secretID) || data["ip_hash"] != logger.hashIdentifier(ip) {
+		t.Errorf("Unexpected hashes in audit entry: %v", data)
+	}
+	if newAuditLogger("other-key").hashIdentifier(secretID) == logger.hashIdentifier(secretID) {
+		t.Error("Expected different keys to produce different hashes")
+	}
+
+	// An age is recorded in whole seconds
+	tw.buffer.Reset()
+	age := 90*time.Second + 500*time.Millisecond
+	logger.audit("expire", secretID, "", &age)
+	entry = LogEntry{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(tw.String())), &entry); err != nil {
+		t.Fatalf("Failed to parse audit entry: %v", err)
+	}
+	if data := entry.Data.(map[string]interface{}); data["age_seconds"] != float64(90) {
+		t.Errorf("Expected age_seconds 90, got %v", data["age_seconds"])
+	}
+}
+
+func TestStartupEnvRedaction(t *testing.T) {
+	envVars := map[string]string{
+		"ADMIN_TOKEN":   "admin-secret",
+		"REDIS_HOST":    "redis.internal",
+		"INTERNAL_DSN":  "postgres://db.internal",
+		"REDIS_API_KEY": "k",
+		"UNSET_TOKEN":   "",
+	}
+	defaults := config.EnvRedactionConfig{
+		SensitiveSubstrings: []string{"key", "secret", "password", "token"},
+	}
+
+	tests := []struct {
+		name      string
+		redaction config.EnvRedactionConfig
+		want      []string
+	}{
+		{
+			name:      "Default substrings",
+			redaction: defaults,
+			want:      []string{"ADMIN_TOKEN=********", "INTERNAL_DSN=postgres://db.internal", "REDIS_API_KEY=********", "REDIS_HOST=redis.internal"},
+		},
+		{
+			name:      "Custom sensitive substring",
+			redaction: config.EnvRedactionConfig{SensitiveSubstrings: []string{"DSN"}},
+			want:      []string{"ADMIN_TOKEN=admin-secret", "INTERNAL_DSN=********", "REDIS_API_KEY=k", "REDIS_HOST=redis.internal"},
+		},
+		{
+			name:      "Cleared list redacts nothing",
+			redaction: config.EnvRedactionConfig{SensitiveSubstrings: []string{}},
+			want:      []string{"ADMIN_TOKEN=admin-secret", "INTERNAL_DSN=postgres://db.internal", "REDIS_API_KEY=k", "REDIS_HOST=redis.internal"},
+		},
+		{
+			name: "Explicit redact and allow",
+			redaction: config.EnvRedactionConfig{
+				SensitiveSubstrings: defaults.SensitiveSubstrings,
+				Redact:              []string{"redis_host"},
+				Allow:               []string{"REDIS_API_KEY"},
+			},
+			want: []string{"ADMIN_TOKEN=********", "INTERNAL_DSN=postgres://db.internal", "REDIS_API_KEY=k", "REDIS_HOST=********"},
+		},
+		{
+			name:      "Redact all except allowed",
+			redaction: config.EnvRedactionConfig{RedactAll: true, Allow: []string{"REDIS_HOST"}},
+			want:      []string{"ADMIN_TOKEN=********", "INTERNAL_DSN=********", "REDIS_API_KEY=********", "REDIS_HOST=redis.internal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatEnvVars(envVars, tt.redaction)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIndependentLoggers(t *testing.T) {
+	newStdout := func(tw *testWriter, files map[string]FileConfig) *Logger {
+		logger, err := NewLogger(&Config{Enabled: true, Stdout: true, Output: tw, Files: files}, true)
+		if err != nil {
+			t.Fatalf("Failed to create logger: %v", err)
+		}
+		return logger
+	}
+
+	first, second := &testWriter{}, &testWriter{}
+	firstLogger := newStdout(first, map[string]FileConfig{
+		"application": {Filename: "app.log"},
+	})
+	secondLogger := newStdout(second, map[string]FileConfig{
+		"error": {Filename: "error.log"},
+	})
+
+	firstLogger.Info("first info", nil)
+	secondLogger.Error("second error", nil)
+	secondLogger.Info("second info", nil) // No application sink configured
+
+	if output := first.String(); !strings.Contains(output, "first info") || strings.Contains(output, "second") {
+		t.Errorf("Unexpected output from the first logger: %s", output)
+	}
+	if output := second.String(); !strings.Contains(output, "second error") || strings.Contains(output, "info") {
+		t.Errorf("Unexpected output from the second logger: %s", output)
+	}
+
+	// Reset lets Init configure a new default logger
+	defer SetDefault(SetDefault(nil))
+	for _, tw := range []*testWriter{first, second} {
+		Reset()
+		tw.buffer.Reset()
+		if err := Init(&Config{Enabled: true, Stdout: true, Output: tw, Files: map[string]FileConfig{
+			"application": {Filename: "app.log"},
+		}}, true); err != nil {
+			t.Fatalf("Failed to initialize logger: %v", err)
+		}
+		Info("default info", nil)
+		if !strings.Contains(tw.String(), "default info") {
+			t.Errorf("Expected the reinitialized default logger to write to its own output")
+		}
+	}
+	if count := strings.Count(first.String(), "default info"); count != 1 {
+		t.Errorf("Expected the replaced default logger to stop writing, got %d entries", count)
+	}
+	Reset()
+}
+
+func TestMaxBackups(t *testing.T) {
+	// Log paths are resolved against the working directory
+	tmpDir, err := os.MkdirTemp(".", "logger-prune-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	logger, err := NewLogger(&Config{
+		Enabled:        true,
+		Directory:      tmpDir,
+		ArchiveDir:     filepath.Join(tmpDir, "archive"),
+		RotationSizeMB: 1,
+		MaxBackups:     2,
+		Files: map[string]FileConfig{
+			"application": {Filename: "app.log"},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	writer, ok := logger.writers["application"].(*lumberjack.Logger)
+	if !ok {
+		t.Fatalf("Expected a rotating writer, got %T", logger.writers["application"])
+	}
+	defer writer.Close()
+	if writer.MaxBackups != 2 {
+		t.Errorf("Expected MaxBackups 2, got %d", writer.MaxBackups)
+	}
+
+	for i := 0; i < 5; i++ {
+		logger.Info("entry before rotation", map[string]interface{}{"i": i})
+		if err := writer.Rotate(); err != nil {
+			t.Fatalf("Failed to rotate: %v", err)
+		}
+		// Backup names carry a millisecond timestamp
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Old backups are compressed and removed in the background after a rotation
+	var backups []string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backups = nil
+		entries, err := os.ReadDir(logger.logDir)
+		if err != nil {
+			t.Fatalf("Failed to read log directory: %v", err)
+		}
+		compressed := true
+		for _, entry := range entries {
+			if logger.isRotatedLog(entry.Name()) {
+				backups = append(backups, entry.Name())
+				compressed = compressed && strings.HasSuffix(entry.Name(), ".gz")
+			}
+		}
+		if (len(backups) <= 2 && compressed) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups to be kept, got %d: %v", len(backups), backups)
+	}
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	// Log paths are resolved against the working directory
+	tmpDir, err := os.MkdirTemp(".", "logger-prune-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	logger, err := NewLogger(&Config{
+		Enabled:    true,
+		Directory:  tmpDir,
+		ArchiveDir: filepath.Join(tmpDir, "archive"),
+		Files: map[string]FileConfig{
+			"application": {Filename: "app.log"},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	// Four 512KB files, oldest first: two rotated backups and two archives
+	now := time.Now()
+	files := []string{
+		filepath.Join(logger.logDir, "app-2026-01-01T00-00-00.000.log.gz"),
+		filepath.Join(logger.archiveDir, "app-2025.tar.gz"),
+		filepath.Join(logger.logDir, "app-2026-01-03T00-00-00.000.log.gz"),
+		filepath.Join(logger.archiveDir, "app-2026.tar.gz"),
+	}
+	for i, path := range files {
+		if err := os.WriteFile(path, make([]byte, 512*1024), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		modTime := now.Add(time.Duration(i-len(files)) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set times on %s: %v", path, err)
+		}
+	}
+	active := filepath.Join(logger.logDir, "app.log")
+	if err := os.WriteFile(active, make([]byte, 2*1024*1024), 0644); err != nil {
+		t.Fatalf("Failed to write active log: %v", err)
+	}
+
+	// Without a cap nothing is removed
+	if err := logger.pruneLogs(); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	for _, path := range files {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept without a cap: %v", path, err)
+		}
+	}
+
+	logger.config.MaxTotalMB = 1
+	if err := logger.pruneLogs(); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	for i, path := range files {
+		_, err := os.Stat(path)
+		if i < 2 && !os.IsNotExist(err) {
+			t.Errorf("Expected oldest file %s to be removed", path)
+		}
+		if i >= 2 && err != nil {
+			t.Errorf("Expected newest file %s to be kept: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(active); err != nil {
+		t.Errorf("Expected active log to be kept: %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	// Log paths are resolved against the working directory
+	tmpDir, err := os.MkdirTemp(".", "logger-rotate-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	disabled := false
+
+	logger, err := NewLogger(&Config{
+		Enabled:    true,
+		Directory:  tmpDir,
+		ArchiveDir: filepath.Join(tmpDir, "archive"),
+		Files: map[string]FileConfig{
+			"application": {Filename: "app.log"},
+			"access":      {Filename: "access.log", Enabled: &disabled},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.writers["application"].(io.Closer).Close()
+
+	active := filepath.Join(logger.logDir, "app.log")
+	logger.Info("before rotation", nil)
+	if err := logger.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+
+	// The new file is empty until something is logged
+	info, err := os.Stat(active)
+	if err != nil {
+		t.Fatalf("Expected a new log file to be started: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("Expected the new log file to be empty, got %d bytes", info.Size())
+	}
+
+	logger.Info("after rotation", nil)
+	data, err := os.ReadFile(active)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(data), "before rotation") || !strings.Contains(string(data), "after rotation") {
+		t.Errorf("Expected only entries written after rotation, got %q", data)
+	}
+
+	// The old entries are moved to a backup that is compressed in the background
+	var backups []string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backups = nil
+		entries, err := os.ReadDir(logger.logDir)
+		if err != nil {
+			t.Fatalf("Failed to read log directory: %v", err)
+		}
+		compressed := true
+		for _, entry := range entries {
+			if logger.isRotatedLog(entry.Name()) {
+				backups = append(backups, entry.Name())
+				compressed = compressed && strings.HasSuffix(entry.Name(), ".gz")
+			}
+		}
+		if (len(backups) > 0 && compressed) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(backups) != 1 {
+		t.Errorf("Expected the previous log file to be kept as a backup, got %v", backups)
+	}
+
+	// Rotating an uninitialized logger is a no-op
+	var nilLogger *Logger
+	if err := nilLogger.Rotate(); err != nil {
+		t.Errorf("Expected nil logger rotation to succeed, got %v", err)
+	}
+}
+
+func TestSlowRequestLogging(t *testing.T) {
+	tw := &testWriter{}
+	logger, err := NewLogger(&Config{
+		Enabled:       true,
+		Stdout:        true,
+		Output:        tw,
+		SlowRequestMS: 20,
+		Files: map[string]FileConfig{
+			"access": {Filename: "access.log"},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer SetDefault(SetDefault(logger))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinLogger())
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(40 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	entries := func(path string) []LogEntry {
+		tw.buffer.Reset()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+
+		var result []LogEntry
+		for _, line := range strings.Split(strings.TrimSpace(tw.String()), "\n") {
+			var entry LogEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("Failed to parse log entry: %v", err)
+			}
+			result = append(result, entry)
+		}
+		return result
+	}
+
+	fast := entries("/fast")
+	if len(fast) != 1 || fast[0].Level != "INFO" {
+		t.Errorf("Expected a single info entry for a fast request, got %+v", fast)
+	}
+
+	slow := entries("/slow")
+	if len(slow) != 2 {
+		t.Fatalf("Expected an info and a slow entry, got %+v", slow)
+	}
+	if slow[0].Level != "INFO" {
+		t.Errorf("Expected the regular entry at info level, got %s", slow[0].Level)
+	}
+	if slow[1].Level != "WARN" || slow[1].Type != "access" {
+		t.Errorf("Expected a warn-level access entry, got %s %s", slow[1].Level, slow[1].Type)
+	}
+	data := slow[1].Data.(map[string]interface{})
+	if data["slow"] != true || data["path"] != "/slow" {
+		t.Errorf("Unexpected slow entry data: %v", data)
+	}
+	if ms, ok := data["latency_ms"].(float64); !ok || ms < 20 {
+		t.Errorf("Expected latency_ms of at least 20, got %v", data["latency_ms"])
+	}
+}
+
+func TestAccessErrorsOnly(t *testing.T) {
+	tw := &testWriter{}
+	logger, err := NewLogger(&Config{
+		Enabled:          true,
+		Stdout:           true,
+		Output:           tw,
+		AccessErrorsOnly: true,
+		Files: map[string]FileConfig{
+			"access": {Filename: "access.log"},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer SetDefault(SetDefault(logger))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinLogger())
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/cached", func(c *gin.Context) { c.Status(http.StatusNotModified) })
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	entries := func(path string) []LogEntry {
+		tw.buffer.Reset()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+
+		var result []LogEntry
+		for _, line := range strings.Split(strings.TrimSpace(tw.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry LogEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("Failed to parse log entry: %v", err)
+			}
+			result = append(result, entry)
+		}
+		return result
+	}
+
+	if ok := entries("/ok"); len(ok) != 0 {
+		t.Errorf("Expected no entry for a 200, got %+v", ok)
+	}
+
+	if cached := entries("/cached"); len(cached) != 1 || cached[0].Level != "INFO" {
+		t.Errorf("Expected a single info entry for a 304, got %+v", cached)
+	}
+
+	missing := entries("/missing")
+	if len(missing) != 1 || missing[0].Level != "INFO" || missing[0].Type != "access" {
+		t.Fatalf("Expected a single info access entry for a 404, got %+v", missing)
+	}
+	if status := missing[0].Data.(map[string]interface{})["status"]; status != float64(http.StatusNotFound) {
+		t.Errorf("Expected status 404, got %v", status)
+	}
+
+	if failed := entries("/fail"); len(failed) != 1 || failed[0].Level != "WARN" {
+		t.Errorf("Expected a single warn entry for a 500, got %+v", failed)
+	}
+}
+
+func TestTimestampFormat(t *testing.T) {
+	newLogger := func(format, timezone string) (*Logger, *testWriter, error) {
+		tw := &testWriter{}
+		logger, err := NewLogger(&Config{
+			Enabled:         true,
+			Stdout:          true,
+			Output:          tw,
+			TimestampFormat: format,
+			Timezone:        timezone,
+			Files: map[string]FileConfig{
+				"application": {Filename: "app.log"},
+			},
+		}, true)
+		return logger, tw, err
+	}
+	timestampOf := func(t *testing.T, tw *testWriter) string {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(strings.TrimSpace(tw.String())), &entry); err != nil {
+			t.Fatalf("Failed to parse log entry: %v", err)
+		}
+		return entry.Timestamp
+	}
+
+	t.Run("UTC RFC3339 by default", func(t *testing.T) {
+		logger, tw, err := newLogger("", "")
+		if err != nil {
+			t.Fatalf("Failed to create logger: %v", err)
+		}
+		logger.Info("entry", nil)
+
+		ts := timestampOf(t, tw)
+		parsed, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			t.Fatalf("Expected an RFC3339 timestamp, got %q: %v", ts, err)
+		}
+		if !strings.HasSuffix(ts, "Z") || time.Since(parsed) > time.Minute {
+			t.Errorf("Expected a current UTC timestamp, got %q", ts)
+		}
+	})
+
+	t.Run("Custom format and time zone", func(t *testing.T) {
+		logger, tw, err := newLogger("2006-01-02 15:04:05.000 MST", "Asia/Tokyo")
+		if err != nil {
+			t.Fatalf("Failed to create logger: %v", err)
+		}
+		logger.Info("entry", nil)
+
+		ts := timestampOf(t, tw)
+		tokyo, _ := time.LoadLocation("Asia/Tokyo")
+		parsed, err := time.ParseInLocation("2006-01-02 15:04:05.000 MST", ts, tokyo)
+		if err != nil {
+			t.Fatalf("Expected the custom layout, got %q: %v", ts, err)
+		}
+		if !strings.HasSuffix(ts, " JST") || time.Since(parsed).Abs() > time.Minute {
+			t.Errorf("Expected a current Tokyo timestamp, got %q", ts)
+		}
+	})
+
+	t.Run("Named formats", func(t *testing.T) {
+		logger, tw, err := newLogger("RFC3339Nano", "")
+		if err != nil {
+			t.Fatalf("Failed to create logger: %v", err)
+		}
+		logger.Info("entry", nil)
+		if ts := timestampOf(t, tw); !strings.Contains(ts, ".") {
+			t.Errorf("Expected fractional seconds, got %q", ts)
+		}
+	})
+
+	t.Run("Invalid time zone", func(t *testing.T) {
+		if _, _, err := newLogger("", "Not/AZone"); err == nil {
+			t.Error("Expected an error for an unknown time zone")
+		}
+	})
+}
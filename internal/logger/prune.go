@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultMaxBackups is how many rotated files are kept per log when unset
+	defaultMaxBackups = 10
+
+	// defaultRotationSizeMB is lumberjack's rotation size when unset
+	defaultRotationSizeMB = 100
+)
+
+// logFile is a rotated or archived log file that may be pruned
+type logFile struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+// pruneLogs deletes the oldest rotated and archived log files until their
+// total size is within MaxTotalMB. Active log files are never deleted.
+// Callers must hold l.mu.
+func (l *Logger) pruneLogs() error {
+	if l.config.MaxTotalMB <= 0 {
+		return nil
+	}
+
+	var files []logFile
+	var total int64
+	add := func(dir string, include func(name string) bool) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to read log directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !include(entry.Name()) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue // Removed since the directory was read
+			}
+			files = append(files, logFile{
+				path:    filepath.Join(dir, entry.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime().UnixNano(),
+			})
+			total += info.Size()
+		}
+		return nil
+	}
+
+	// Rotated files are named after their log, e.g. errors-<timestamp>.log.gz
+	if err := add(l.logDir, l.isRotatedLog); err != nil {
+		return err
+	}
+	if l.archiveDir != l.logDir {
+		if err := add(l.archiveDir, func(string) bool { return true }); err != nil {
+			return err
+		}
+	}
+
+	limit := int64(l.config.MaxTotalMB) * 1024 * 1024
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime < files[j].modTime })
+	for _, file := range files {
+		if total <= limit {
+			break
+		}
+		if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old log file: %w", err)
+		}
+		total -= file.size
+	}
+	return nil
+}
+
+// isRotatedLog reports whether name is a rotated backup of one of the
+// configured log files, rather than an active log file or something else
+func (l *Logger) isRotatedLog(name string) bool {
+	for _, fileCfg := range l.config.Files {
+		if fileCfg.Filename == "" || name == fileCfg.Filename {
+			continue
+		}
+		ext := filepath.Ext(fileCfg.Filename)
+		prefix := strings.TrimSuffix(fileCfg.Filename, ext) + "-"
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackWrite counts bytes written to log files and prunes old files once
+// enough has been written for a rotation to have happened. Callers must hold
+// l.mu.
+func (l *Logger) trackWrite(n int) {
+	if l.config.MaxTotalMB <= 0 || l.config.Stdout {
+		return
+	}
+
+	sizeMB := l.config.RotationSizeMB
+	if sizeMB <= 0 {
+		sizeMB = defaultRotationSizeMB
+	}
+	l.written += int64(n)
+	if l.written < int64(sizeMB)*1024*1024 {
+		return
+	}
+	l.written = 0
+
+	if err := l.pruneLogs(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error pruning log files: %v\n", err)
+	}
+}
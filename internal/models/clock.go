@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Clock tells the current time. Expiry, burn grace and cleanup all read time
+// through a Clock so tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by the system time
+type SystemClock struct{}
+
+// Now returns the current system time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
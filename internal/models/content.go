@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+const (
+	// contentMagic starts encrypted content in the structured format. Legacy
+	// content is dot-joined base64 text and compressed content starts with
+	// 0x01, so neither can be mistaken for it.
+	contentMagic byte = 0x00
+
+	// contentVersion is the current structured format version
+	contentVersion byte = 1
+
+	// LegacyContentDelimiter joined the encrypted content parts before the
+	// structured format
+	LegacyContentDelimiter = "."
+)
+
+// ErrInvalidContent is returned when stored encrypted content can't be decoded
+var ErrInvalidContent = errors.New("invalid data format")
+
+// MarshalBinary encodes the encrypted content for storage: the magic byte and
+// version, then each part (encrypted, salt, IV) prefixed with its length
+func (c EncryptedContent) MarshalBinary() ([]byte, error) {
+	parts := []string{c.Encrypted, c.Salt, c.IV}
+
+	size := 2
+	for _, part := range parts {
+		size += binary.MaxVarintLen64 + len(part)
+	}
+	data := make([]byte, 0, size)
+	data = append(data, contentMagic, contentVersion)
+	for _, part := range parts {
+		data = binary.AppendUvarint(data, uint64(len(part)))
+		data = append(data, part...)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes content encoded by MarshalBinary, or stored in the
+// legacy dot-joined format
+func (c *EncryptedContent) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != contentMagic {
+		return c.unmarshalLegacy(string(data))
+	}
+	if len(data) < 2 || data[1] != contentVersion {
+		return ErrInvalidContent
+	}
+
+	rest := data[2:]
+	parts := make([]string, 3)
+	for i := range parts {
+		length, n := binary.Uvarint(rest)
+		if n <= 0 || length > uint64(len(rest)-n) {
+			return ErrInvalidContent
+		}
+		rest = rest[n:]
+		parts[i] = string(rest[:length])
+		rest = rest[length:]
+	}
+	if len(rest) != 0 {
+		return ErrInvalidContent
+	}
+
+	*c = EncryptedContent{Encrypted: parts[0], Salt: parts[1], IV: parts[2]}
+	return nil
+}
+
+func (c *EncryptedContent) unmarshalLegacy(data string) error {
+	parts := strings.Split(data, LegacyContentDelimiter)
+	if len(parts) != 3 {
+		return ErrInvalidContent
+	}
+
+	*c = EncryptedContent{Encrypted: parts[0], Salt: parts[1], IV: parts[2]}
+	return nil
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// IDScheme selects how secret IDs are generated
+type IDScheme string
+
+const (
+	// IDSchemeUUID generates random (version 4) UUIDs
+	IDSchemeUUID IDScheme = "uuid"
+	// IDSchemeBase62 generates shorter base62-encoded random IDs
+	IDSchemeBase62 IDScheme = "base62"
+
+	// DefaultIDBytes is the amount of randomness in a base62 ID when unset
+	DefaultIDBytes = 12
+)
+
+var (
+	// uuidPattern matches lowercase version 4 UUIDs
+	uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	// base62Pattern matches the characters used by base62 IDs
+	base62Pattern = regexp.MustCompile(`^[0-9a-zA-Z]+$`)
+)
+
+// ParseIDScheme validates a configured ID scheme name; empty selects UUIDs
+func ParseIDScheme(name string) (IDScheme, error) {
+	switch IDScheme(name) {
+	case "", IDSchemeUUID:
+		return IDSchemeUUID, nil
+	case IDSchemeBase62:
+		return IDSchemeBase62, nil
+	default:
+		return "", fmt.Errorf("unsupported secret ID scheme %q", name)
+	}
+}
+
+// NewID generates a secret ID. idBytes sets the randomness of base62 IDs and
+// is ignored for UUIDs.
+func (s IDScheme) NewID(idBytes int) (string, error) {
+	if s != IDSchemeBase62 {
+		return uuid.NewString(), nil
+	}
+
+	if idBytes <= 0 {
+		idBytes = DefaultIDBytes
+	}
+	buf := make([]byte, idBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret ID: %w", err)
+	}
+
+	// Pad to a fixed length so every ID of a scheme looks the same
+	id := new(big.Int).SetBytes(buf).Text(62)
+	for len(id) < base62Length(idBytes) {
+		id = "0" + id
+	}
+	return id, nil
+}
+
+// ValidID reports whether id is well-formed. UUIDs are always accepted so
+// secrets created before switching to base62 IDs stay reachable.
+func (s IDScheme) ValidID(id string, idBytes int) bool {
+	if uuidPattern.MatchString(strings.ToLower(id)) {
+		return true
+	}
+	if s != IDSchemeBase62 {
+		return false
+	}
+
+	if idBytes <= 0 {
+		idBytes = DefaultIDBytes
+	}
+	return len(id) == base62Length(idBytes) && base62Pattern.MatchString(id)
+}
+
+// base62Length returns the number of base62 digits needed to encode n bytes
+func base62Length(n int) int {
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(n*8))
+	length := 0
+	for v := big.NewInt(1); v.Cmp(limit) < 0; length++ {
+		v.Mul(v, big.NewInt(62))
+	}
+	return length
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// NamePatternAlnum allows letters and numbers only
+	NamePatternAlnum = "alnum"
+	// NamePatternSlug also allows single dashes and underscores between them
+	NamePatternSlug = "slug"
+)
+
+// NamePattern is a compiled rule for the characters allowed in custom names
+type NamePattern struct {
+	regex       *regexp.Regexp
+	description string // Completes "can only contain ..."
+}
+
+var (
+	alnumNamePattern = &NamePattern{
+		regex:       CustomNameRegex,
+		description: "letters and numbers (A-Z, a-z, 0-9)",
+	}
+	slugNamePattern = &NamePattern{
+		regex:       regexp.MustCompile(`^[a-zA-Z0-9]+([-_][a-zA-Z0-9]+)*$`),
+		description: "letters and numbers (A-Z, a-z, 0-9), separated by single dashes or underscores",
+	}
+)
+
+// DefaultNamePattern returns the alphanumeric pattern used when none is configured
+func DefaultNamePattern() *NamePattern {
+	return alnumNamePattern
+}
+
+// ParseNamePattern compiles a configured custom name pattern: empty or "alnum"
+// for letters and numbers, "slug" to also allow dashes and underscores, or
+// any other value as a regular expression that must match the whole name
+func ParseNamePattern(spec string) (*NamePattern, error) {
+	switch spec {
+	case "", NamePatternAlnum:
+		return alnumNamePattern, nil
+	case NamePatternSlug:
+		return slugNamePattern, nil
+	}
+
+	regex, err := regexp.Compile(`^(?:` + spec + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid custom name pattern: %w", err)
+	}
+	return &NamePattern{
+		regex:       regex,
+		description: fmt.Sprintf("characters matching %s", spec),
+	}, nil
+}
+
+// MatchString reports whether name is allowed by the pattern
+func (p *NamePattern) MatchString(name string) bool {
+	return p.regex.MatchString(name)
+}
+
+// Validate checks a custom name against the pattern; empty names are valid
+// because the field is optional
+func (p *NamePattern) Validate(name string) error {
+	if name == "" || p.MatchString(name) {
+		return nil
+	}
+	return fmt.Errorf("custom name can only contain %s", p.description)
+}
+
+// Description describes the allowed characters, e.g. for error messages
+func (p *NamePattern) Description() string {
+	return p.description
+}
+
+// String returns the regular expression names must match
+func (p *NamePattern) String() string {
+	return p.regex.String()
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	passphraseSaltSize   = 16
+	passphraseKeySize    = 32
+	passphraseIterations = 10000
+)
+
+// HashPassphrase returns a salted hash of a view passphrase for storage. The
+// passphrase is a convenience gate checked by the server, not part of the
+// secret's encryption.
+func HashPassphrase(passphrase string) (string, error) {
+	salt := make([]byte, passphraseSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate passphrase salt: %w", err)
+	}
+	return hex.EncodeToString(salt) + ":" + hex.EncodeToString(derivePassphraseKey(passphrase, salt)), nil
+}
+
+// CheckPassphrase reports whether passphrase unlocks the secret. Secrets
+// created without a passphrase accept any value.
+func (s *Secret) CheckPassphrase(passphrase string) bool {
+	if s.PassphraseHash == "" {
+		return true
+	}
+
+	saltHex, keyHex, ok := strings.Cut(s.PassphraseHash, ":")
+	if !ok {
+		return false
+	}
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return false
+	}
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(derivePassphraseKey(passphrase, salt), key) == 1
+}
+
+func derivePassphraseKey(passphrase string, salt []byte) []byte {
+	return pbkdf2.Key([]byte(passphrase), salt, passphraseIterations, passphraseKeySize, sha256.New)
+}
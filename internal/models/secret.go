@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/rand"
 	"fmt"
+	"math/big"
 	"regexp"
 	"time"
 
@@ -13,26 +15,48 @@ var (
 	CustomNameRegex = regexp.MustCompile("^[a-zA-Z0-9]+$")
 )
 
-// ValidateCustomName checks if the custom name is valid
+// customNameAlphabet is the character set used for generated custom names
+const customNameAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// ValidateCustomName checks if the custom name is valid under the default
+// alphanumeric pattern
 func ValidateCustomName(name string) error {
-	if name == "" {
-		return nil // Empty name is valid (optional field)
+	return DefaultNamePattern().Validate(name)
+}
+
+// GenerateCustomName returns a random alphanumeric custom name of the given length
+func GenerateCustomName(length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("custom name length must be positive")
 	}
 
-	if !CustomNameRegex.MatchString(name) {
-		return fmt.Errorf("custom name can only contain letters and numbers (A-Z, a-z, 0-9)")
+	name := make([]byte, length)
+	max := big.NewInt(int64(len(customNameAlphabet)))
+	for i := range name {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate custom name: %w", err)
+		}
+		name[i] = customNameAlphabet[n.Int64()]
 	}
 
-	return nil
+	return string(name), nil
 }
 
 type Secret struct {
-	ID                 uuid.UUID  `json:"id"`
+	ID                 string     `json:"id"`
 	CustomName         string     `json:"custom_name,omitempty"`
 	CreatedAt          time.Time  `json:"created_at"`
 	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
 	IsBurnAfterReading bool       `json:"is_burn_after_reading"`
-	EncryptedData      []byte     `json:"encrypted_data"` // Server-encrypted data
+	MaxViews           *int       `json:"max_views,omitempty"`
+	ViewCount          int        `json:"view_count"`
+	ContentLength      int        `json:"content_length,omitempty"`     // Creator-supplied plaintext size hint
+	ContentKind        string     `json:"content_kind,omitempty"`       // Creator-supplied rendering hint
+	BurnPendingSince   *time.Time `json:"burn_pending_since,omitempty"` // First view that exhausted the secret during a burn grace window
+	PassphraseHash     string     `json:"passphrase_hash,omitempty"`    // Salted hash of the optional view passphrase
+	ServerEncrypted    *bool      `json:"server_encrypted,omitempty"`   // Whether EncryptedData is server-encrypted, unset on older records
+	EncryptedData      []byte     `json:"encrypted_data"`               // Server-encrypted data
 }
 
 type EncryptedContent struct {
@@ -46,6 +70,9 @@ type SecretInput struct {
 	CustomName         string           `json:"customName,omitempty"`
 	ExpiresAt          *time.Time       `json:"expires_at,omitempty"`
 	IsBurnAfterReading bool             `json:"isBurnAfterReading"`
+	MaxViews           *int             `json:"maxViews,omitempty"`
+	ContentLength      int              `json:"contentLength,omitempty"`
+	ContentKind        string           `json:"contentKind,omitempty"`
 	CaptchaToken       string           `json:"captchaToken" binding:"required"`
 }
 
@@ -61,19 +88,46 @@ type SecretAccess struct {
 	CaptchaToken string `json:"captchaToken" binding:"required"`
 }
 
-func NewSecret(input *SecretInput) *Secret {
+// NewSecret creates a secret from the input, created at now
+func NewSecret(input *SecretInput, now time.Time) *Secret {
 	return &Secret{
-		ID:                 uuid.New(),
+		ID:                 uuid.NewString(),
 		CustomName:         input.CustomName,
-		CreatedAt:          time.Now(),
+		CreatedAt:          now,
 		ExpiresAt:          input.ExpiresAt,
 		IsBurnAfterReading: input.IsBurnAfterReading,
+		MaxViews:           input.MaxViews,
+		ContentLength:      input.ContentLength,
+		ContentKind:        input.ContentKind,
 	}
 }
 
-func (s *Secret) IsExpired() bool {
+// IsExpired reports whether the secret's expiry time is before now
+func (s *Secret) IsExpired(now time.Time) bool {
 	if s.ExpiresAt == nil {
 		return false
 	}
-	return time.Now().After(*s.ExpiresAt)
+	return now.After(*s.ExpiresAt)
+}
+
+// Lifetime returns how long the secret lives from creation to expiry, or zero
+// if it never expires
+func (s *Secret) Lifetime() time.Duration {
+	if s.ExpiresAt == nil {
+		return 0
+	}
+	return s.ExpiresAt.Sub(s.CreatedAt)
+}
+
+// ViewsExhausted reports whether the secret has been viewed as many times as allowed
+func (s *Secret) ViewsExhausted() bool {
+	if s.IsBurnAfterReading {
+		return s.ViewCount >= 1
+	}
+	return s.MaxViews != nil && s.ViewCount >= *s.MaxViews
+}
+
+// BurnDue reports whether a secret pending burn has outlived its grace window
+func (s *Secret) BurnDue(now time.Time, grace time.Duration) bool {
+	return s.BurnPendingSince != nil && now.Sub(*s.BurnPendingSince) > grace
 }
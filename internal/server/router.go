@@ -0,0 +1,293 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/api/handlers"
+	"secrets-share/internal/api/middleware"
+	"secrets-share/internal/captcha"
+	"secrets-share/internal/config"
+	"secrets-share/internal/encryption"
+	"secrets-share/internal/logger"
+	"secrets-share/internal/storage/file"
+	"secrets-share/internal/storage/redis"
+)
+
+// Deps holds the services the router's handlers and middleware use
+type Deps struct {
+	FileStore       *file.FileStore
+	RedisStore      *redis.RedisStore // Nil when Redis is unavailable, which disables rate limiting
+	Encryptor       *encryption.Encryptor
+	CaptchaVerifier captcha.TurnstileVerifier
+	IPAllowlist     []netip.Prefix
+	IPDenylist      []netip.Prefix
+}
+
+// NewRouter assembles the middleware stack and routes. The server and the
+// end-to-end tests share it, so tests exercise the same stack as production.
+func NewRouter(cfg *config.Config, deps Deps) *gin.Engine {
+	// Initialize handlers
+	secretHandler := handlers.NewSecretAPIHandler(deps.FileStore, deps.RedisStore, deps.Encryptor, deps.CaptchaVerifier, cfg)
+	adminHandler := handlers.NewAdminAPIHandler(deps.FileStore, deps.RedisStore, deps.Encryptor, secretHandler.Maintenance(), cfg)
+	healthHandler := handlers.NewHealthAPIHandler(deps.FileStore, deps.RedisStore)
+
+	// Initialize Gin router
+	router := gin.New()
+
+	// Gin trusts X-Forwarded-For from everyone by default, which would let
+	// clients pick the address the IP filter and rate limits see
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Error("Invalid trusted proxies, trusting none", map[string]interface{}{
+			"error": err.Error(),
+		})
+		router.SetTrustedProxies(nil)
+	}
+	router.Use(logger.GinLogger())
+	router.Use(middleware.Recover())
+	router.Use(middleware.NoSniff())
+	// Parse errors already made gin trust no proxies above, so match that
+	trustedProxies, _ := middleware.ParsePrefixes(cfg.Server.TrustedProxies)
+	router.Use(middleware.SecurityHeaders(map[string]string{
+		"X-Frame-Options":         cfg.Security.Headers.FrameOptions,
+		"Referrer-Policy":         cfg.Security.Headers.ReferrerPolicy,
+		"Content-Security-Policy": cfg.Security.Headers.ContentSecurityPolicy,
+	}, time.Duration(cfg.Security.Headers.HSTSMaxAgeSec)*time.Second, trustedProxies))
+
+	// Indented responses are a development aid only
+	if cfg.Server.PrettyJSON {
+		if cfg.Server.Env == "production" {
+			logger.Warn("Ignoring server.pretty_json in production", nil)
+		} else {
+			router.Use(middleware.PrettyJSON())
+		}
+	}
+
+	// CORS middleware
+	router.Use(func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin != "" {
+			for _, allowed := range cfg.CORS.AllowedOrigins {
+				if origin == allowed {
+					c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+					break
+				}
+			}
+		}
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{
+			"Content-Type", "Authorization", handlers.ViewPassphraseHeader, handlers.CreatorTokenHeader,
+		}, ", "))
+		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	})
+
+	// Reject disallowed client IPs before any captcha or rate-limit work
+	router.Use(middleware.FilterIPs(deps.IPAllowlist, deps.IPDenylist))
+
+	// Cap the request rate of the whole process before any per-IP checks
+	router.Use(middleware.LimitGlobalRate(cfg.RateLimit.GlobalPerSecond))
+
+	// Rate limiting middleware (only if Redis is available)
+	if cfg.RateLimit.Enabled && deps.RedisStore != nil {
+		router.Use(func(c *gin.Context) {
+			ip := c.ClientIP()
+			route := c.FullPath()
+			requestsPerHour, requestsPerMinute := getRateLimits(c, cfg)
+
+			allowed, err := deps.RedisStore.CheckRateLimit(
+				c.Request.Context(),
+				ip,
+				route,
+				requestsPerHour,
+				requestsPerMinute,
+			)
+			if err != nil {
+				logger.Error("Rate limit check failed", err)
+				c.Next()
+				return
+			}
+			if !allowed {
+				logger.RateLimit("Rate limit exceeded", map[string]interface{}{
+					"route": route,
+					"ip":    ip,
+				})
+				api.AbortWithError(c, http.StatusTooManyRequests, api.CodeTooManyRequests, "Rate limit exceeded. Please try again later.")
+				return
+			}
+			c.Next()
+		})
+	}
+
+	// Cap concurrent requests per client so one IP can't tie up many slow
+	// captcha and disk operations at once
+	router.Use(middleware.LimitConcurrency(cfg.RateLimit.MaxConcurrentPerIP))
+
+	// Response compression runs after CORS and rate limiting so their early
+	// responses are never buffered
+	if cfg.Server.Compression.Enabled {
+		router.Use(middleware.Compress(cfg.Server.Compression.MinSizeBytes))
+	}
+
+	// Every route lives under server.base_path, so the service can share a
+	// host with others behind a proxy
+	base := router.Group(basePath(&cfg.Server))
+
+	// Readiness for load balancers and orchestrators
+	base.GET("/readyz", healthHandler.Ready)
+
+	// API routes
+	apiRoutes := base.Group("/api")
+	{
+		// Bound request bodies on every route that accepts one
+		bodyLimit := middleware.MaxBodySize(cfg.Secrets.MaxRequestBytes)
+
+		// JSON endpoints refuse other media types before binding
+		requireJSON := middleware.RequireJSON()
+
+		// Optionally equalize lookup timing so it doesn't reveal whether a secret exists
+		padResponse := noopMiddleware
+		if cfg.Security.ConstantTimeResponses {
+			padResponse = middleware.PadResponseTime(time.Duration(cfg.Security.MinResponseMS) * time.Millisecond)
+		}
+
+		// Optionally verify captchas before dispatch so failures short-circuit cheaply
+		createCaptcha, viewCaptcha := noopMiddleware, noopMiddleware
+		if cfg.Security.EnableCaptcha && cfg.Security.Captcha.Middleware {
+			if cfg.Security.Captcha.Create {
+				createCaptcha = middleware.RequireCaptcha(deps.CaptchaVerifier, cfg.Security.APIToken, cfg.Security.Captcha.CreateAction)
+			}
+			if cfg.Security.Captcha.View {
+				viewCaptcha = middleware.RequireCaptcha(deps.CaptchaVerifier, cfg.Security.APIToken, cfg.Security.Captcha.ViewAction)
+			}
+		}
+
+		apiRoutes.GET("/openapi.json", handlers.GetOpenAPI)
+
+		secrets := apiRoutes.Group("/secrets")
+		{
+			secrets.POST("", requireJSON, bodyLimit, createCaptcha, secretHandler.CreateSecret)
+			secrets.POST("/name/:name", padResponse, requireJSON, bodyLimit, viewCaptcha, secretHandler.GetSecretByName)
+			secrets.POST("/:id", padResponse, requireJSON, bodyLimit, viewCaptcha, secretHandler.GetSecret)
+			secrets.GET("/mine", secretHandler.ListCreatorSecrets)
+			secrets.GET("/:id", padResponse, middleware.RequireToken(cfg.Security.APIToken), secretHandler.GetSecretWithToken)
+			secrets.GET("/:id/status", padResponse, secretHandler.GetSecretStatus)
+		}
+
+		admin := apiRoutes.Group("/admin", middleware.RequireToken(cfg.Security.AdminToken))
+		{
+			admin.GET("/stats", adminHandler.GetStats)
+			admin.GET("/metrics", adminHandler.GetMetrics)
+			admin.GET("/export", adminHandler.ExportSecrets)
+			admin.POST("/import", middleware.MaxBodySize(cfg.Secrets.MaxImportBytes), adminHandler.ImportSecrets)
+			admin.PUT("/maintenance", requireJSON, bodyLimit, adminHandler.SetMaintenance)
+		}
+	}
+
+	// A mapping that can't match silently falls back to the default limits
+	if cfg.RateLimit.Enabled {
+		for _, problem := range rateLimitMismatches(router.Routes(), cfg) {
+			logger.Warn("Rate limit mapping never applies", map[string]interface{}{
+				"problem": problem,
+			})
+		}
+		logger.Debug("Rate limit routes", rateLimitRoutes)
+	}
+
+	return router
+}
+
+// noopMiddleware stands in for optional middleware that is turned off
+func noopMiddleware(c *gin.Context) {
+	c.Next()
+}
+
+// basePath returns server.base_path as a route prefix: with a leading slash,
+// without a trailing one, and empty when routes are served from the root
+func basePath(cfg *config.ServerConfig) string {
+	path := strings.Trim(strings.TrimSpace(cfg.BasePath), "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// rateLimitRoutes maps route patterns to their rate_limit.routes section. The
+// keys must match c.FullPath() without server.base_path exactly, i.e. the
+// pattern the route was registered with including parameters, not the request
+// path. Unmatched routes use rate_limit.default.
+var rateLimitRoutes = map[string]string{
+	"/api/secrets":            "create_secret",
+	"/api/secrets/:id":        "view_secret",
+	"/api/secrets/name/:name": "view_secret_by_name",
+}
+
+// defaultRateLimitSection names the bucket used for routes without their own
+const defaultRateLimitSection = "default"
+
+// rateLimitBucket resolves the config section and limits for a route pattern
+func rateLimitBucket(route string, cfg *config.Config) (string, config.RouteRateLimit) {
+	route = strings.TrimPrefix(route, basePath(&cfg.Server))
+	if section, exists := rateLimitRoutes[route]; exists {
+		if limits, ok := cfg.RateLimit.Routes[section]; ok {
+			return section, limits
+		}
+	}
+	return defaultRateLimitSection, cfg.RateLimit.Default
+}
+
+func getRateLimits(c *gin.Context, cfg *config.Config) (int, int) {
+	route := c.FullPath()
+	section, limits := rateLimitBucket(route, cfg)
+
+	logger.Debug("Rate limit bucket selected", map[string]interface{}{
+		"route":               route,
+		"section":             section,
+		"requests_per_hour":   limits.RequestsPerHour,
+		"requests_per_minute": limits.RequestsPerMinute,
+	})
+	return limits.RequestsPerHour, limits.RequestsPerMinute
+}
+
+// rateLimitMismatches lists rate-limit mappings that can never apply: entries
+// in rateLimitRoutes for routes that aren't registered, or pointing at
+// sections missing from the config, and config sections no route uses
+func rateLimitMismatches(routes gin.RoutesInfo, cfg *config.Config) []string {
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		registered[route.Path] = true
+	}
+
+	var problems []string
+	used := make(map[string]bool, len(rateLimitRoutes))
+	for route, section := range rateLimitRoutes {
+		used[section] = true
+		route = basePath(&cfg.Server) + route
+		if !registered[route] {
+			problems = append(problems, fmt.Sprintf("route %s for rate_limit.routes.%s is not registered", route, section))
+		}
+		if _, ok := cfg.RateLimit.Routes[section]; !ok {
+			problems = append(problems, fmt.Sprintf("rate_limit.routes.%s is not configured, %s uses the default limits", section, route))
+		}
+	}
+	for section := range cfg.RateLimit.Routes {
+		if !used[section] {
+			problems = append(problems, fmt.Sprintf("rate_limit.routes.%s does not match any route", section))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
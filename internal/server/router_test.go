@@ -0,0 +1,339 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/api"
+	"secrets-share/internal/api/handlers"
+	"secrets-share/internal/api/middleware"
+	"secrets-share/internal/config"
+	"secrets-share/internal/encryption"
+	"secrets-share/internal/logger"
+	"secrets-share/internal/models"
+	"secrets-share/internal/storage/file"
+	"secrets-share/internal/storage/redis"
+)
+
+// setupTestRouter builds the production router over a temporary store and an
+// in-memory Redis, with captcha disabled and logging silenced
+func setupTestRouter(t *testing.T, modify func(cfg *config.Config)) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg, err := config.LoadConfig("../..")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Secrets.StoragePath = t.TempDir()
+	cfg.Security.EnableCaptcha = false
+	if modify != nil {
+		modify(cfg)
+	}
+
+	silent, err := logger.NewLogger(&logger.Config{Enabled: false}, true)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	previous := logger.SetDefault(silent)
+	t.Cleanup(func() { logger.SetDefault(previous) })
+
+	fileStore, err := file.NewFileStore(cfg.Secrets.StoragePath)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	port, _ := strconv.Atoi(mr.Port())
+	redisStore, err := redis.NewRedisStore(mr.Host(), port, "", "", 0)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	t.Cleanup(func() { redisStore.Close() })
+
+	ipAllowlist, err := middleware.ParsePrefixes(cfg.Security.IPAllowlist)
+	if err != nil {
+		t.Fatalf("Failed to parse IP allowlist: %v", err)
+	}
+	ipDenylist, err := middleware.ParsePrefixes(cfg.Security.IPDenylist)
+	if err != nil {
+		t.Fatalf("Failed to parse IP denylist: %v", err)
+	}
+
+	return NewRouter(cfg, Deps{
+		FileStore:   fileStore,
+		RedisStore:  redisStore,
+		Encryptor:   encryption.NewEncryptor("test-server-key"),
+		IPAllowlist: ipAllowlist,
+		IPDenylist:  ipDenylist,
+	})
+}
+
+func createSecretBody(t *testing.T) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"encryptedContent": models.EncryptedContent{
+			Encrypted: "ZW5jcnlwdGVkLWNvbnRlbnQtZGF0YQ==",
+			Salt:      "c2FsdC1zYWx0LXNhbHQtMQ==",
+			IV:        "aXYtaXYtaXYtaXYx",
+		},
+	})
+	assert.NoError(t, err)
+	return body
+}
+
+func TestRouterCreateAndView(t *testing.T) {
+	router := setupTestRouter(t, nil)
+
+	req := httptest.NewRequest("POST", "/api/secrets", bytes.NewReader(createSecretBody(t)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var created handlers.APISecretResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+
+	req = httptest.NewRequest("POST", "/api/secrets/"+created.ID, bytes.NewReader([]byte("{}")))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var viewed handlers.APISecretContentResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &viewed))
+	assert.Equal(t, "ZW5jcnlwdGVkLWNvbnRlbnQtZGF0YQ==", viewed.EncryptedContent.Encrypted)
+}
+
+func TestRouterCORS(t *testing.T) {
+	router := setupTestRouter(t, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = []string{"https://anondrop.link"}
+	})
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api/secrets", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://anondrop.link")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://anondrop.link", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+
+	// Browsers only send the view passphrase and creator token headers if
+	// the preflight allows them
+	allowed := w.Header().Get("Access-Control-Allow-Headers")
+	for _, header := range []string{"Content-Type", "Authorization", "X-View-Passphrase", "X-Creator-Token"} {
+		assert.Contains(t, allowed, header)
+	}
+
+	w = preflight("https://evil.example")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Real requests through the handlers carry the headers too
+	req := httptest.NewRequest("POST", "/api/secrets", bytes.NewReader(createSecretBody(t)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://anondrop.link")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "https://anondrop.link", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+}
+
+func TestRouterRateLimit(t *testing.T) {
+	router := setupTestRouter(t, func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.Routes["create_secret"] = config.RouteRateLimit{RequestsPerHour: 100, RequestsPerMinute: 2}
+	})
+
+	create := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/secrets", bytes.NewReader(createSecretBody(t)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := create()
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	w := create()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// The 429 uses the same envelope as every other API error
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]interface{}{
+		"error": "Rate limit exceeded. Please try again later.",
+		"code":  api.CodeTooManyRequests,
+	}, response)
+
+	// Other buckets are unaffected
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouterGlobalRateLimit(t *testing.T) {
+	router := setupTestRouter(t, func(cfg *config.Config) {
+		cfg.RateLimit.GlobalPerSecond = 3
+	})
+
+	busy := 0
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+		req.RemoteAddr = "192.0.2." + strconv.Itoa(i+1) + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code == http.StatusServiceUnavailable {
+			busy++
+		}
+	}
+	// A token may refill while the loop runs
+	assert.GreaterOrEqual(t, busy, 6)
+}
+
+func TestRouterBasePath(t *testing.T) {
+	var cfg *config.Config
+	router := setupTestRouter(t, func(c *config.Config) {
+		c.Server.BasePath = "/anondrop/"
+		c.RateLimit.Enabled = true
+		c.RateLimit.Routes["create_secret"] = config.RouteRateLimit{RequestsPerHour: 100, RequestsPerMinute: 2}
+		cfg = c
+	})
+
+	request := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Routes resolve under the prefix", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request("GET", "/anondrop/api/openapi.json", nil).Code)
+		assert.NotEqual(t, http.StatusNotFound, request("GET", "/anondrop/readyz", nil).Code)
+		assert.Equal(t, http.StatusNotFound, request("GET", "/api/openapi.json", nil).Code)
+		assert.Equal(t, http.StatusNotFound, request("GET", "/readyz", nil).Code)
+	})
+
+	t.Run("Rate limits key by the route without the prefix", func(t *testing.T) {
+		section, _ := rateLimitBucket("/anondrop/api/secrets/name/:name", cfg)
+		assert.Equal(t, "view_secret_by_name", section)
+		assert.Empty(t, rateLimitMismatches(router.Routes(), cfg))
+
+		for i := 0; i < 2; i++ {
+			w := request("POST", "/anondrop/api/secrets", createSecretBody(t))
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}
+		assert.Equal(t, http.StatusTooManyRequests, request("POST", "/anondrop/api/secrets", createSecretBody(t)).Code)
+	})
+}
+
+func TestBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":           "",
+		"/":          "",
+		"anondrop":   "/anondrop",
+		"/anondrop/": "/anondrop",
+		"/a/b":       "/a/b",
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, basePath(&config.ServerConfig{BasePath: input}), "base path %q", input)
+	}
+}
+
+func TestRouterTrustedProxies(t *testing.T) {
+	request := func(router *gin.Engine, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Spoofed header from an untrusted peer", func(t *testing.T) {
+		router := setupTestRouter(t, func(cfg *config.Config) {
+			cfg.Server.TrustedProxies = nil
+			cfg.Security.IPAllowlist = []string{"10.0.0.0/8"}
+		})
+		assert.Equal(t, http.StatusForbidden, request(router, "203.0.113.5:1234", "10.1.2.3"))
+		assert.Equal(t, http.StatusOK, request(router, "10.1.2.3:1234", ""))
+	})
+
+	t.Run("Denylist can't be dodged", func(t *testing.T) {
+		router := setupTestRouter(t, func(cfg *config.Config) {
+			cfg.Server.TrustedProxies = nil
+			cfg.Security.IPDenylist = []string{"203.0.113.0/24"}
+		})
+		assert.Equal(t, http.StatusForbidden, request(router, "203.0.113.5:1234", "198.51.100.7"))
+	})
+
+	t.Run("Header from a trusted proxy", func(t *testing.T) {
+		router := setupTestRouter(t, func(cfg *config.Config) {
+			cfg.Server.TrustedProxies = []string{"127.0.0.1"}
+			cfg.Security.IPAllowlist = []string{"10.0.0.0/8"}
+		})
+		assert.Equal(t, http.StatusOK, request(router, "127.0.0.1:1234", "10.1.2.3"))
+		assert.Equal(t, http.StatusForbidden, request(router, "127.0.0.1:1234", "203.0.113.5"))
+	})
+}
+
+func TestRouterRecovery(t *testing.T) {
+	router := setupTestRouter(t, nil)
+	router.GET("/panic", func(c *gin.Context) {
+		panic("handler bug")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var response api.APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, api.CodeInternal, response.Code)
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+
+	// The server keeps serving after a panic
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouterImportBodyLimit(t *testing.T) {
+	router := setupTestRouter(t, func(cfg *config.Config) {
+		cfg.Security.AdminToken = "admin-token"
+		cfg.Secrets.MaxImportBytes = 64
+	})
+
+	req := httptest.NewRequest("POST", "/api/admin/import", bytes.NewReader(make([]byte, 65)))
+	req.Header.Set("Authorization", "Bearer admin-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	var response api.APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, api.CodeRequestTooLarge, response.Code)
+}
@@ -0,0 +1,515 @@
+// Package server wires the storage, encryption and captcha services into the
+// HTTP router and runs it until shut down.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"secrets-share/internal/api/middleware"
+	"secrets-share/internal/background"
+	"secrets-share/internal/captcha"
+	"secrets-share/internal/config"
+	"secrets-share/internal/encryption"
+	"secrets-share/internal/logger"
+	"secrets-share/internal/models"
+	"secrets-share/internal/storage/file"
+	"secrets-share/internal/storage/redis"
+)
+
+// unixSocketMode restricts the socket to the service user and its group (e.g. a local proxy)
+const unixSocketMode = 0660
+
+// newListener opens a Unix domain socket when server.unix_socket is set,
+// otherwise a TCP listener on host:port. The socket file is removed again when
+// the listener is closed.
+func newListener(cfg *config.ServerConfig) (net.Listener, error) {
+	if cfg.UnixSocket == "" {
+		return net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Host, cfg.Port))
+	}
+
+	// Remove a stale socket left behind by an unclean shutdown
+	if info, err := os.Stat(cfg.UnixSocket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unix socket path %s exists and is not a socket", cfg.UnixSocket)
+		}
+		if err := os.Remove(cfg.UnixSocket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", cfg.UnixSocket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(cfg.UnixSocket, unixSocketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set unix socket permissions: %w", err)
+	}
+
+	return listener, nil
+}
+
+// newKeyRing builds the key ring of the server key under its configured ID and
+// the keys read from security.encryption_key_files
+func newKeyRing(cfg *config.SecurityConfig, serverKey string) (*encryption.KeyRing, error) {
+	// Viper lower-cases map keys, so other IDs could never match this one later
+	if cfg.EncryptionKeyID != strings.ToLower(cfg.EncryptionKeyID) {
+		return nil, fmt.Errorf("encryption key ID %q must be lower case", cfg.EncryptionKeyID)
+	}
+
+	keys := map[string]string{cfg.EncryptionKeyID: serverKey}
+	for id, path := range cfg.EncryptionKeyFiles {
+		if id == cfg.EncryptionKeyID {
+			return nil, fmt.Errorf("encryption key ID %q is already used by the server key", id)
+		}
+		key, err := encryption.FileKeyProvider{Path: path}.Key()
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return encryption.NewKeyRing(cfg.EncryptionKeyID, keys)
+}
+
+// storageProbeInterval is how often the storage directory is checked for writability
+const storageProbeInterval = 30 * time.Second
+
+// redisHealthInterval is how often Redis is pinged after startup
+const redisHealthInterval = 10 * time.Second
+
+// defaultCleanupInterval is used when the configured cleanup interval is not positive
+const defaultCleanupInterval = 300 * time.Second
+
+// cleanupInterval returns the configured cleanup interval, falling back to
+// defaultCleanupInterval for zero or negative values, which would make
+// time.NewTicker panic
+func cleanupInterval(cfg *config.SecretsConfig) time.Duration {
+	if cfg.CleanupIntervalSec <= 0 {
+		logger.Warn("Invalid cleanup interval, using default", map[string]interface{}{
+			"configured_sec": cfg.CleanupIntervalSec,
+			"default":        defaultCleanupInterval.String(),
+		})
+		return defaultCleanupInterval
+	}
+	return time.Duration(cfg.CleanupIntervalSec) * time.Second
+}
+
+// insecureProductionSettings lists settings that are unsafe for a production
+// deployment. The server has no TLS of its own, so it should listen on
+// localhost or a Unix socket behind a TLS-terminating proxy.
+func insecureProductionSettings(cfg *config.Config) []string {
+	if cfg.Server.Env != "production" {
+		return nil
+	}
+
+	var problems []string
+	if !cfg.Security.EnableCaptcha {
+		problems = append(problems, "captcha is disabled")
+	}
+	if !cfg.Security.ServerSideEncryption {
+		problems = append(problems, "server-side encryption is disabled")
+	}
+	if cfg.Server.UnixSocket == "" {
+		switch cfg.Server.Host {
+		case "", "0.0.0.0", "::", "[::]":
+			problems = append(problems, "listening on all interfaces without TLS")
+		}
+	}
+	return problems
+}
+
+// checkProductionSafety refuses insecure production settings unless
+// security.allow_insecure_production is set, in which case they are logged
+func checkProductionSafety(cfg *config.Config) error {
+	problems := insecureProductionSettings(cfg)
+	if len(problems) == 0 {
+		return nil
+	}
+	if cfg.Security.AllowInsecureProduction {
+		logger.Warn("Running in production with insecure settings", map[string]interface{}{
+			"problems": problems,
+		})
+		return nil
+	}
+	return fmt.Errorf("insecure production settings: %s (set security.allow_insecure_production to override)", strings.Join(problems, ", "))
+}
+
+// Run initializes the services described by cfg and serves HTTP until ctx is
+// cancelled, then shuts down gracefully. The logger must already be initialized.
+func Run(ctx context.Context, cfg *config.Config) error {
+	// Initialize encryptor
+	serverKey, err := encryption.NewKeyProvider(cfg.Security.EncryptionKeyFile).Key()
+	if err != nil {
+		return fmt.Errorf("failed to load server encryption key: %w", err)
+	}
+	encryptorOpts := []encryption.Option{encryption.WithSaltSize(cfg.Security.EncryptionSaltBytes)}
+	if cfg.Security.EncryptionKeyID != "" {
+		ring, err := newKeyRing(&cfg.Security, serverKey)
+		if err != nil {
+			return fmt.Errorf("invalid security configuration: %w", err)
+		}
+		encryptorOpts = append(encryptorOpts, encryption.WithKeyRing(ring))
+	}
+	encryptor := encryption.NewEncryptor(serverKey, encryptorOpts...)
+	if err := encryptor.SelfTest(); err != nil {
+		return fmt.Errorf("encryption self-test failed: %w", err)
+	}
+
+	// Initialize storage
+	if cfg.Secrets.CleanupDryRun {
+		logger.Warn("Cleanup dry run is enabled: expired secrets will not be deleted", nil)
+	}
+	storeOpts := []file.Option{
+		file.WithCleanupDryRun(cfg.Secrets.CleanupDryRun),
+		file.WithBurnGrace(time.Duration(cfg.Secrets.BurnGraceSeconds) * time.Second),
+		file.WithMaxAge(cfg.Secrets.HardMaxAge),
+		file.WithExpiredRetention(time.Duration(cfg.Secrets.ExpiredRetentionMin) * time.Minute),
+	}
+	if cfg.Secrets.EncryptRecords {
+		storeOpts = append(storeOpts, file.WithRecordEncryption(encryptor))
+	}
+	fileStore, err := file.NewFileStore(cfg.Secrets.StoragePath, storeOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize file store: %w", err)
+	}
+
+	// Initialize Redis store (optional)
+	var redisStore *redis.RedisStore
+	logger.Info("Connecting to Redis", map[string]interface{}{
+		"host": cfg.Redis.Host,
+		"port": cfg.Redis.Port,
+	})
+
+	redisStore, err = redis.NewRedisStore(
+		cfg.Redis.Host,
+		cfg.Redis.Port,
+		cfg.Redis.Password,
+		cfg.Redis.Username,
+		cfg.Redis.DB,
+		redis.WithPoolSize(cfg.Redis.PoolSize),
+		redis.WithTimeouts(
+			time.Duration(cfg.Redis.DialTimeoutMS)*time.Millisecond,
+			time.Duration(cfg.Redis.ReadTimeoutMS)*time.Millisecond,
+			time.Duration(cfg.Redis.WriteTimeoutMS)*time.Millisecond,
+		),
+	)
+	if err != nil {
+		logger.Warn("Redis store not available", err)
+		logger.Warn("Running without Redis features (rate limiting disabled)", nil)
+	} else {
+		logger.Info("Successfully connected to Redis", map[string]interface{}{
+			"host": cfg.Redis.Host,
+			"port": cfg.Redis.Port,
+		})
+		logger.Info("Rate limiting is enabled", nil)
+	}
+
+	// Validate ciphertext encoding
+	if _, err := encryption.ParseEncoding(cfg.Security.CiphertextEncoding); err != nil {
+		return fmt.Errorf("invalid security configuration: %w", err)
+	}
+
+	// Refuse obviously unsafe production deployments
+	if err := checkProductionSafety(cfg); err != nil {
+		return fmt.Errorf("invalid security configuration: %w", err)
+	}
+
+	// Forwarded client addresses are only believed from these proxies
+	if _, err := middleware.ParsePrefixes(cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid server configuration: %w", err)
+	}
+
+	// Parse client IP allow and deny lists
+	ipAllowlist, err := middleware.ParsePrefixes(cfg.Security.IPAllowlist)
+	if err != nil {
+		return fmt.Errorf("invalid security configuration: %w", err)
+	}
+	ipDenylist, err := middleware.ParsePrefixes(cfg.Security.IPDenylist)
+	if err != nil {
+		return fmt.Errorf("invalid security configuration: %w", err)
+	}
+
+	// Validate secret ID scheme
+	if _, err := models.ParseIDScheme(cfg.Secrets.IDScheme); err != nil {
+		return fmt.Errorf("invalid secrets configuration: %w", err)
+	}
+
+	// Validate custom name pattern
+	if _, err := models.ParseNamePattern(cfg.Secrets.CustomNamePattern); err != nil {
+		return fmt.Errorf("invalid secrets configuration: %w", err)
+	}
+
+	// Initialize Turnstile client
+	var captchaVerifier captcha.TurnstileVerifier = captcha.NewTurnstileClient(captcha.ParseSecretKeys(os.Getenv("CAPTCHA_SECRET_KEY"))...)
+	if captchaCfg := cfg.Security.Captcha; captchaCfg.BreakerThreshold > 0 {
+		captchaVerifier = captcha.NewCircuitBreaker(
+			captchaVerifier,
+			captchaCfg.BreakerThreshold,
+			time.Duration(captchaCfg.BreakerCooldownSec)*time.Second,
+			captchaCfg.FailOpen,
+		)
+	}
+	if captchaCfg := cfg.Security.Captcha; captchaCfg.MaxConcurrency > 0 {
+		// Outside the breaker, so rejections at the limit don't count as
+		// upstream failures
+		captchaVerifier = captcha.NewConcurrencyLimiter(
+			captchaVerifier,
+			captchaCfg.MaxConcurrency,
+			time.Duration(captchaCfg.ConcurrencyWaitMS)*time.Millisecond,
+		)
+	}
+	if cfg.Security.Captcha.CacheTTLSec > 0 {
+		captchaVerifier = captcha.NewCachingVerifier(captchaVerifier, time.Duration(cfg.Security.Captcha.CacheTTLSec)*time.Second)
+	}
+
+	// Log startup information
+	envVars := map[string]string{
+		"SERVER_ENCRYPTION_KEY": os.Getenv("SERVER_ENCRYPTION_KEY"),
+		"CAPTCHA_SECRET_KEY":    os.Getenv("CAPTCHA_SECRET_KEY"),
+		"REDIS_USERNAME":        os.Getenv("REDIS_USERNAME"),
+		"REDIS_PASSWORD":        os.Getenv("REDIS_PASSWORD"),
+		"ADMIN_TOKEN":           os.Getenv("ADMIN_TOKEN"),
+		"API_TOKEN":             os.Getenv("API_TOKEN"),
+		"AUDIT_HASH_KEY":        os.Getenv("AUDIT_HASH_KEY"),
+	}
+	logger.LogStartupInfo(cfg, redisStore != nil, envVars)
+
+	// Initialize Gin router
+	router := NewRouter(cfg, Deps{
+		FileStore:       fileStore,
+		RedisStore:      redisStore,
+		Encryptor:       encryptor,
+		CaptchaVerifier: captchaVerifier,
+		IPAllowlist:     ipAllowlist,
+		IPDenylist:      ipDenylist,
+	})
+
+	// Close Redis connection if it exists, also when startup fails below
+	if redisStore != nil {
+		defer func() {
+			if err := redisStore.Close(); err != nil {
+				logger.Error("Redis connection close error", err)
+			}
+		}()
+	}
+
+	// Listen before starting background tasks, so a failure leaves nothing to stop
+	listener, err := newListener(&cfg.Server)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	// Stop serving when ctx is cancelled or the server fails
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// Background tasks are drained before shutdown completes
+	tasks := background.NewManager(ctx)
+
+	// Start cleanup goroutine
+	interval := cleanupInterval(&cfg.Secrets)
+	cleanupTicker := time.NewTicker(interval)
+	tasks.Go(func(ctx context.Context) {
+		defer cleanupTicker.Stop()
+
+		logger.Info("Starting cleanup routine", map[string]interface{}{
+			"interval": interval,
+		})
+
+		// The startup cleanup runs while the server is already listening;
+		// /readyz reports 503 until a scan has completed
+		logger.Info("Performing startup cleanup of expired secrets", nil)
+		if err := fileStore.CleanExpired(); err != nil {
+			logger.Warn("Startup cleanup failed", err)
+		} else {
+			stats := fileStore.GetCleanupStats()
+			if stats.SecretsCleaned > 0 || stats.WouldClean > 0 {
+				logger.Info("Startup cleanup completed", map[string]interface{}{
+					"secrets_cleaned": stats.SecretsCleaned,
+					"bytes_cleaned":   stats.BytesCleaned,
+					"would_clean":     stats.WouldClean,
+				})
+			} else {
+				logger.Info("Startup cleanup completed: no expired secrets found", nil)
+			}
+		}
+
+		// Expired secrets are gone now, so their leftover name entries can be
+		// told apart from live ones
+		if removed, err := fileStore.ReconcileNames(); err != nil {
+			logger.Warn("Failed to reconcile custom names", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else if removed > 0 {
+			logger.Info("Removed orphaned custom name entries", map[string]interface{}{
+				"count": removed,
+			})
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Info("Cleanup routine shutting down", nil)
+				// Perform one final cleanup
+				if err := fileStore.CleanExpired(); err != nil {
+					logger.Error("Final cleanup failed", err)
+				} else {
+					stats := fileStore.GetCleanupStats()
+					if stats.SecretsCleaned > 0 {
+						logger.Info("Final cleanup completed", map[string]interface{}{
+							"secrets_cleaned": stats.SecretsCleaned,
+							"bytes_cleaned":   stats.BytesCleaned,
+						})
+					}
+				}
+				return
+			case <-cleanupTicker.C:
+				if err := fileStore.CleanExpired(); err != nil {
+					logger.Error("Failed to clean expired secrets", err)
+					continue
+				}
+
+				// Log cleanup statistics
+				stats := fileStore.GetCleanupStats()
+				if stats.SecretsCleaned > 0 || stats.WouldClean > 0 || stats.Errors > 0 {
+					logger.Info("Periodic cleanup completed", map[string]interface{}{
+						"secrets_cleaned": stats.SecretsCleaned,
+						"bytes_cleaned":   stats.BytesCleaned,
+						"would_clean":     stats.WouldClean,
+						"errors":          stats.Errors,
+						"last_run":        stats.LastRun.Format(time.RFC3339),
+					})
+				}
+			}
+		}
+	})
+
+	// Periodically check that the storage directory still accepts writes
+	probeTicker := time.NewTicker(storageProbeInterval)
+	tasks.Go(func(ctx context.Context) {
+		defer probeTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-probeTicker.C:
+				wasWritable := fileStore.Writable()
+				err := fileStore.ProbeWritable()
+				if err != nil && wasWritable {
+					logger.Error("Secret storage is not writable", map[string]interface{}{
+						"error_type": "storage_unwritable",
+						"error":      err.Error(),
+					})
+				} else if err == nil && !wasWritable {
+					logger.Info("Secret storage is writable again", nil)
+				}
+			}
+		}
+	})
+
+	// Watch Redis after startup, since rate limiting lets requests through
+	// while it is unreachable
+	if redisStore != nil {
+		redisTicker := time.NewTicker(redisHealthInterval)
+		tasks.Go(func(ctx context.Context) {
+			defer redisTicker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-redisTicker.C:
+					wasHealthy := redisStore.Healthy()
+					checkCtx, cancel := context.WithTimeout(ctx, redisHealthInterval)
+					err := redisStore.CheckHealth(checkCtx)
+					cancel()
+					if err != nil && wasHealthy {
+						logger.Error("Redis is down", map[string]interface{}{
+							"error_type": "redis_down",
+							"error":      err.Error(),
+						})
+					} else if err == nil && !wasHealthy {
+						logger.Info("Redis is up again", nil)
+					}
+				}
+			}
+		})
+	}
+
+	// Reopen log files on SIGUSR1, e.g. after logrotate has renamed them
+	rotateSignals := make(chan os.Signal, 1)
+	signal.Notify(rotateSignals, syscall.SIGUSR1)
+	tasks.Go(func(ctx context.Context) {
+		defer signal.Stop(rotateSignals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-rotateSignals:
+				if err := logger.Rotate(); err != nil {
+					logger.Error("Failed to rotate log files", map[string]interface{}{
+						"error": err.Error(),
+					})
+					continue
+				}
+				logger.Info("Log files rotated", nil)
+			}
+		}
+	})
+
+	// Start HTTP server
+	srv := &http.Server{
+		Handler: router,
+	}
+
+	// Start server in a goroutine
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting", map[string]interface{}{
+			"address": listener.Addr().String(),
+			"network": listener.Addr().Network(),
+		})
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+			stop()
+		}
+	}()
+
+	// Wait for interrupt signal
+	<-ctx.Done()
+	logger.Info("Shutdown signal received", nil)
+
+	// Create shutdown context with timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Shutdown HTTP server
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Server shutdown error", err)
+	}
+
+	// Wait for background tasks such as the final cleanup to finish
+	if err := tasks.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Background tasks did not finish before shutdown", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	logger.Info("Server shutdown complete", nil)
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server failed: %w", err)
+	default:
+		return nil
+	}
+}
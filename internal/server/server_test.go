@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"secrets-share/internal/config"
+)
+
+func TestUnixSocketListener(t *testing.T) {
+	// Keep the path short, socket paths are limited to ~100 bytes
+	dir, err := os.MkdirTemp("", "anondrop")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "api.sock")
+
+	listener, err := newListener(&config.ServerConfig{UnixSocket: socketPath})
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+
+	info, err := os.Stat(socketPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(unixSocketMode), info.Mode().Perm())
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go srv.Serve(listener)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatalf("Request over unix socket failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(body))
+
+	// Shutting down removes the socket file
+	assert.NoError(t, srv.Shutdown(context.Background()))
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err), "Expected socket file to be removed on shutdown")
+}
+
+func TestUnixSocketListenerRefusesRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	assert.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+
+	_, err := newListener(&config.ServerConfig{UnixSocket: path})
+	assert.Error(t, err)
+
+	// The existing file is left untouched
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
+
+func TestCleanupInterval(t *testing.T) {
+	testCases := []struct {
+		name       string
+		configured int
+		expected   time.Duration
+	}{
+		{name: "Configured value", configured: 30, expected: 30 * time.Second},
+		{name: "Zero falls back to default", configured: 0, expected: defaultCleanupInterval},
+		{name: "Negative falls back to default", configured: -5, expected: defaultCleanupInterval},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interval := cleanupInterval(&config.SecretsConfig{CleanupIntervalSec: tc.configured})
+			assert.Equal(t, tc.expected, interval)
+
+			// The ticker must be constructible with the result
+			ticker := time.NewTicker(interval)
+			ticker.Stop()
+		})
+	}
+}
+
+func TestProductionSafety(t *testing.T) {
+	safeConfig := func() *config.Config {
+		return &config.Config{
+			Server:   config.ServerConfig{Env: "production", Host: "127.0.0.1"},
+			Security: config.SecurityConfig{EnableCaptcha: true, ServerSideEncryption: true},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		modify   func(cfg *config.Config)
+		problems []string
+	}{
+		{name: "Safe settings", modify: func(cfg *config.Config) {}},
+		{
+			name:     "Captcha disabled",
+			modify:   func(cfg *config.Config) { cfg.Security.EnableCaptcha = false },
+			problems: []string{"captcha is disabled"},
+		},
+		{
+			name:     "Server-side encryption disabled",
+			modify:   func(cfg *config.Config) { cfg.Security.ServerSideEncryption = false },
+			problems: []string{"server-side encryption is disabled"},
+		},
+		{
+			name:     "All interfaces",
+			modify:   func(cfg *config.Config) { cfg.Server.Host = "0.0.0.0" },
+			problems: []string{"listening on all interfaces without TLS"},
+		},
+		{
+			name:     "Empty host binds all interfaces",
+			modify:   func(cfg *config.Config) { cfg.Server.Host = "" },
+			problems: []string{"listening on all interfaces without TLS"},
+		},
+		{
+			name: "Unix socket ignores host",
+			modify: func(cfg *config.Config) {
+				cfg.Server.Host = "0.0.0.0"
+				cfg.Server.UnixSocket = "/run/anondrop.sock"
+			},
+		},
+		{
+			name: "Development is not checked",
+			modify: func(cfg *config.Config) {
+				cfg.Server.Env = "development"
+				cfg.Server.Host = "0.0.0.0"
+				cfg.Security.EnableCaptcha = false
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := safeConfig()
+			tc.modify(cfg)
+
+			assert.Equal(t, tc.problems, insecureProductionSettings(cfg))
+
+			err := checkProductionSafety(cfg)
+			if len(tc.problems) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err, "insecure settings refuse to start")
+
+			// The override downgrades the refusal to a warning
+			cfg.Security.AllowInsecureProduction = true
+			assert.NoError(t, checkProductionSafety(cfg))
+		})
+	}
+}
+
+func TestNameLookupRateLimit(t *testing.T) {
+	cfg, err := config.LoadConfig("../..")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Resolve the limits the way the rate-limit middleware does, per route
+	limits := make(map[string][2]int)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	record := func(c *gin.Context) {
+		hour, minute := getRateLimits(c, cfg)
+		limits[c.FullPath()] = [2]int{hour, minute}
+	}
+	router.POST("/api/secrets/:id", record)
+	router.POST("/api/secrets/name/:name", record)
+	for _, path := range []string{"/api/secrets/abc", "/api/secrets/name/invoice"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", path, nil))
+	}
+
+	byID, byName := limits["/api/secrets/:id"], limits["/api/secrets/name/:name"]
+	assert.LessOrEqual(t, byName[0], byID[0])
+	assert.LessOrEqual(t, byName[1], byID[1])
+	assert.True(t, byName != byID, "name lookups should have a stricter bucket than ID lookups")
+
+	failures := cfg.RateLimit.NameLookupFailures
+	assert.Positive(t, failures.RequestsPerMinute)
+	assert.Less(t, failures.RequestsPerHour, byName[0])
+}
+
+func TestRateLimitBucket(t *testing.T) {
+	cfg, err := config.LoadConfig("../..")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Record the bucket chosen for each request by its route pattern
+	sections := make(map[string]string)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	record := func(c *gin.Context) {
+		section, _ := rateLimitBucket(c.FullPath(), cfg)
+		sections[c.Request.Method+" "+c.Request.URL.Path] = section
+	}
+	router.POST("/api/secrets", record)
+	router.POST("/api/secrets/name/:name", record)
+	router.POST("/api/secrets/:id", record)
+	router.GET("/api/secrets/:id", record)
+	router.GET("/api/secrets/:id/status", record)
+
+	tests := []struct {
+		method, path, section string
+	}{
+		{"POST", "/api/secrets", "create_secret"},
+		{"POST", "/api/secrets/abc", "view_secret"},
+		{"GET", "/api/secrets/abc", "view_secret"},
+		{"POST", "/api/secrets/name/invoice", "view_secret_by_name"},
+		{"GET", "/api/secrets/abc/status", defaultRateLimitSection},
+	}
+	for _, tt := range tests {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.section, sections[tt.method+" "+tt.path], "%s %s", tt.method, tt.path)
+	}
+
+	// The limits come from the matched section
+	_, limits := rateLimitBucket("/api/secrets/name/:name", cfg)
+	assert.Equal(t, cfg.RateLimit.Routes["view_secret_by_name"], limits)
+
+	// The request path instead of the pattern is a common mistake and falls
+	// back to the default bucket
+	section, limits := rateLimitBucket("/api/secrets/abc", cfg)
+	assert.Equal(t, defaultRateLimitSection, section)
+	assert.Equal(t, cfg.RateLimit.Default, limits)
+
+	assert.Empty(t, rateLimitMismatches(router.Routes(), cfg))
+
+	// Misnamed sections and unregistered routes are reported
+	cfg.RateLimit.Routes["view_secrets"] = cfg.RateLimit.Routes["view_secret"]
+	delete(cfg.RateLimit.Routes, "view_secret")
+	bare := gin.New()
+	bare.POST("/api/secrets", record)
+	bare.POST("/api/secrets/:id", record)
+	assert.Equal(t, []string{
+		"rate_limit.routes.view_secret is not configured, /api/secrets/:id uses the default limits",
+		"rate_limit.routes.view_secrets does not match any route",
+		"route /api/secrets/name/:name for rate_limit.routes.view_secret_by_name is not registered",
+	}, rateLimitMismatches(bare.Routes(), cfg))
+}
+
+func TestRateLimitRoutesConfigured(t *testing.T) {
+	cfg, err := config.LoadConfig("../..")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Every mapped section is configured in the shipped config
+	for route, section := range rateLimitRoutes {
+		_, ok := cfg.RateLimit.Routes[section]
+		assert.True(t, ok, "rate_limit.routes.%s for %s is missing from config.yaml", section, route)
+	}
+
+	// Give each bucket distinct limits so a fallback to the default shows
+	cfg.RateLimit.Default = config.RouteRateLimit{RequestsPerHour: 999, RequestsPerMinute: 99}
+	cfg.RateLimit.Routes = map[string]config.RouteRateLimit{
+		"create_secret":       {RequestsPerHour: 1, RequestsPerMinute: 11},
+		"view_secret":         {RequestsPerHour: 2, RequestsPerMinute: 12},
+		"view_secret_by_name": {RequestsPerHour: 3, RequestsPerMinute: 13},
+	}
+
+	// Register the routes through nested groups the way main does, since the
+	// group prefixes are what make up c.FullPath()
+	limits := make(map[string][2]int)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	record := func(c *gin.Context) {
+		hour, minute := getRateLimits(c, cfg)
+		limits[c.Request.URL.Path] = [2]int{hour, minute}
+	}
+	secrets := router.Group("/api").Group("/secrets")
+	secrets.POST("", record)
+	secrets.POST("/name/:name", record)
+	secrets.POST("/:id", record)
+
+	tests := []struct {
+		path string
+		want [2]int
+	}{
+		{"/api/secrets", [2]int{1, 11}},
+		{"/api/secrets/abc", [2]int{2, 12}},
+		{"/api/secrets/name/invoice", [2]int{3, 13}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", tt.path, nil))
+		assert.Equal(t, tt.want, limits[tt.path], "POST %s", tt.path)
+	}
+	assert.Empty(t, rateLimitMismatches(router.Routes(), cfg))
+}
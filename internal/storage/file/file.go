@@ -1,34 +1,134 @@
 package file
 
 import (
-	"encoding/json"
+	"archive/tar"
+	"compress/gzip"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"secrets-share/internal/encryption"
 	"secrets-share/internal/logger"
 	"secrets-share/internal/models"
 )
 
+const (
+	secretFileExt  = ".json"
+	tempFileExt    = ".tmp"
+	reservationExt = ".reserved"
+	probeFileName  = ".write-probe" + tempFileExt
+)
+
+var (
+	// ErrIDExists is returned by Create when a secret with the same ID is already stored
+	ErrIDExists = errors.New("secret ID already exists")
+
+	// ErrDiskFull is returned when a secret can't be written because the disk is full
+	ErrDiskFull = errors.New("secret storage is full")
+
+	// ErrStorageUnwritable is returned when the storage directory can't be written to
+	ErrStorageUnwritable = errors.New("secret storage is not writable")
+
+	// ErrStoreAtCapacity is returned by Import when the store holds the maximum
+	// number of secrets
+	ErrStoreAtCapacity = errors.New("secret store is at capacity")
+
+	// ErrInvalidArchive is returned by Import when the archive can't be read
+	ErrInvalidArchive = errors.New("invalid archive")
+)
+
 type FileStore struct {
-	basePath string
-	mu       sync.RWMutex
+	basePath   string
+	mu         sync.RWMutex
+	totalViews int64
+	count      int                   // Number of stored secrets, maintained incrementally
+	dryRun     bool                  // Report expired secrets during cleanup without deleting them
+	burnGrace  time.Duration         // How long an exhausted secret can still be re-read
+	maxAge     time.Duration         // Cleanup deletes secrets older than this whatever their expiry
+	retention  time.Duration         // How long expired secrets are kept before cleanup deletes them
+	clock      models.Clock          // Source of the current time for expiry checks
+	records    *encryption.Encryptor // Encrypts whole secret files when set
+	writable   bool                  // Result of the last write or writability probe
+	scanned    bool                  // Whether a cleanup scan has completed successfully
+	writeFile  func(name string, data []byte, perm os.FileMode) error
+	viewAges   *Histogram // Secret age at each view
+	expiryAges *Histogram // Lifetime of secrets deleted because they expired
 	// Add metrics
 	cleanupStats struct {
 		lastRun        time.Time
 		secretsCleaned int
+		bytesCleaned   int64
+		wouldClean     int
 		errors         int
+		totalCleaned   int
+		totalBytes     int64
+		totalRuns      int
+		totalErrors    int
+	}
+}
+
+// Option configures optional FileStore behavior
+type Option func(*FileStore)
+
+// WithCleanupDryRun makes CleanExpired only report which expired secrets it
+// would delete, leaving them on disk
+func WithCleanupDryRun(dryRun bool) Option {
+	return func(s *FileStore) {
+		s.dryRun = dryRun
+	}
+}
+
+// WithBurnGrace keeps secrets that reach their view limit readable for the given
+// window, so an immediate re-fetch (e.g. a browser double request) still succeeds
+func WithBurnGrace(grace time.Duration) Option {
+	return func(s *FileStore) {
+		s.burnGrace = grace
 	}
 }
 
-// CleanupStats represents cleanup operation statistics
+// WithMaxAge makes cleanup delete any secret created longer ago than maxAge,
+// whether or not it has expired, as a backstop against secrets that never
+// expire. Zero disables the limit.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(s *FileStore) {
+		s.maxAge = maxAge
+	}
+}
+
+// WithExpiredRetention keeps expired secrets on disk for the given window
+// after their expiry before cleanup deletes them, e.g. for audits or to
+// recover from an accidental expiry. They can't be read in the meantime.
+func WithExpiredRetention(retention time.Duration) Option {
+	return func(s *FileStore) {
+		s.retention = retention
+	}
+}
+
+// WithClock sets the clock used for expiry, burn grace and cleanup
+func WithClock(clock models.Clock) Option {
+	return func(s *FileStore) {
+		s.clock = clock
+	}
+}
+
+// CleanupStats represents cleanup operation statistics. The first fields
+// describe the most recent run, the Total fields accumulate over all runs.
 type CleanupStats struct {
 	LastRun        time.Time
 	SecretsCleaned int
+	BytesCleaned   int64 // Size on disk of the secrets removed
+	WouldClean     int   // Expired secrets left in place by a dry run
 	Errors         int
+	TotalCleaned   int
+	TotalBytes     int64
+	TotalRuns      int
+	TotalErrors    int
 }
 
 // GetCleanupStats returns the current cleanup statistics
@@ -38,23 +138,132 @@ func (s *FileStore) GetCleanupStats() CleanupStats {
 	return CleanupStats{
 		LastRun:        s.cleanupStats.lastRun,
 		SecretsCleaned: s.cleanupStats.secretsCleaned,
+		BytesCleaned:   s.cleanupStats.bytesCleaned,
+		WouldClean:     s.cleanupStats.wouldClean,
 		Errors:         s.cleanupStats.errors,
+		TotalCleaned:   s.cleanupStats.totalCleaned,
+		TotalBytes:     s.cleanupStats.totalBytes,
+		TotalRuns:      s.cleanupStats.totalRuns,
+		TotalErrors:    s.cleanupStats.totalErrors,
 	}
 }
 
-func NewFileStore(basePath string) (*FileStore, error) {
+func NewFileStore(basePath string, opts ...Option) (*FileStore, error) {
 	// Ensure the base path exists
 	if err := os.MkdirAll(basePath, 0750); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	return &FileStore{
-		basePath: basePath,
-	}, nil
+	// Count existing secrets once so later changes can be tracked incrementally
+	files, err := os.ReadDir(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+	count := 0
+	for _, file := range files {
+		if isSecretFile(file) {
+			count++
+		} else if strings.HasSuffix(file.Name(), reservationExt) {
+			// Left behind by a writer that stopped before releasing its name
+			os.Remove(filepath.Join(basePath, file.Name()))
+		}
+	}
+
+	s := &FileStore{
+		basePath:   basePath,
+		count:      count,
+		writable:   true,
+		clock:      models.SystemClock{},
+		writeFile:  os.WriteFile,
+		viewAges:   newHistogram(ageBuckets),
+		expiryAges: newHistogram(ageBuckets),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ProbeWritable()
+	if s.records != nil {
+		if err := s.migrateRecords(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Count returns the number of secrets currently stored
+func (s *FileStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.count
 }
 
 func (s *FileStore) Store(secret *models.Secret) error {
-	// Check if custom name is taken before acquiring write lock
+	release, err := s.claimCustomName(secret)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writeSecret(secret)
+}
+
+// Create stores a new secret, returning ErrIDExists instead of overwriting an
+// existing secret with the same ID
+func (s *FileStore) Create(secret *models.Secret) error {
+	release, err := s.claimCustomName(secret)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filePath := filepath.Join(s.basePath, secret.ID+secretFileExt)
+	if _, err := os.Stat(filePath); err == nil {
+		return ErrIDExists
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check secret file: %w", err)
+	}
+
+	return s.writeSecret(secret)
+}
+
+// claimCustomName reserves a secret's custom name until the returned release
+// function is called, then checks that no stored secret already holds it.
+// Concurrent writers using the same name fail on the reservation, so at most
+// one of them can pass the check and commit. It must be called before
+// acquiring the write lock, and release only after the secret is written.
+func (s *FileStore) claimCustomName(secret *models.Secret) (func(), error) {
+	if secret.CustomName == "" {
+		return func() {}, nil
+	}
+
+	reservation := filepath.Join(s.basePath, s.nameKey(secret.CustomName)+reservationExt)
+	f, err := os.OpenFile(reservation, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("custom name %q is already taken", secret.CustomName)
+		}
+		return nil, fmt.Errorf("failed to reserve custom name: %w", classifyWriteError(err))
+	}
+	f.Close()
+	release := func() { os.Remove(reservation) }
+
+	if err := s.checkCustomName(secret); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// checkCustomName rejects a secret whose custom name belongs to another secret.
+// It must be called before acquiring the write lock.
+func (s *FileStore) checkCustomName(secret *models.Secret) error {
 	if secret.CustomName != "" {
 		taken, err := s.IsCustomNameTaken(secret.CustomName)
 		if err != nil {
@@ -71,33 +280,103 @@ func (s *FileStore) Store(secret *models.Secret) error {
 			}
 		}
 	}
+	return nil
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
+// writeSecret persists a secret; callers must hold the write lock
+func (s *FileStore) writeSecret(secret *models.Secret) error {
 	// Create file path
-	filePath := filepath.Join(s.basePath, secret.ID.String()+".json")
+	filePath := filepath.Join(s.basePath, secret.ID+secretFileExt)
 
-	// Marshal secret to JSON
-	data, err := json.Marshal(secret)
+	data, err := s.encodeSecret(secret)
 	if err != nil {
-		return fmt.Errorf("failed to marshal secret: %w", err)
+		return err
 	}
 
-	// Write to file
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
+	// Write to a temporary file first and rename it into place, so concurrent
+	// readers never observe a partially written secret
+	_, statErr := os.Stat(filePath)
+	isNew := os.IsNotExist(statErr)
+
+	tmpPath := filePath + tempFileExt
+	if err := s.writeFile(tmpPath, data, 0600); err != nil {
+		os.Remove(tmpPath)
+		err = classifyWriteError(err)
+		if errors.Is(err, ErrDiskFull) || errors.Is(err, ErrStorageUnwritable) {
+			s.writable = false
+		}
 		return fmt.Errorf("failed to write secret file: %w", err)
 	}
+	s.writable = true
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename secret file: %w", err)
+	}
+
+	if isNew {
+		s.count++
+	}
+	return s.indexName(secret)
+}
+
+// classifyWriteError marks errors caused by the storage itself rather than by
+// the secret being written
+func classifyWriteError(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		return fmt.Errorf("%w: %w", ErrDiskFull, err)
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM),
+		errors.Is(err, syscall.EROFS), errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%w: %w", ErrStorageUnwritable, err)
+	default:
+		return err
+	}
+}
+
+// Writable reports whether the last write or probe of the storage directory succeeded
+func (s *FileStore) Writable() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.writable
+}
+
+// ProbeWritable checks that the storage directory accepts writes by writing
+// and removing a small probe file, and records the result for Writable
+func (s *FileStore) ProbeWritable() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	probePath := filepath.Join(s.basePath, probeFileName)
+	err := s.writeFile(probePath, []byte("probe"), 0600)
+	if err == nil {
+		err = os.Remove(probePath)
+	}
+	if err != nil {
+		s.writable = false
+		return classifyWriteError(err)
+	}
 
+	s.writable = true
 	return nil
 }
 
+// isSecretFile reports whether a directory entry holds a committed secret,
+// skipping directories and in-flight temporary files
+func isSecretFile(entry os.DirEntry) bool {
+	return !entry.IsDir() && strings.HasSuffix(entry.Name(), secretFileExt)
+}
+
 func (s *FileStore) Get(id string) (*models.Secret, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	return s.readSecret(id)
+}
+
+// readSecret loads a secret by ID; callers must hold the lock
+func (s *FileStore) readSecret(id string) (*models.Secret, error) {
 	// Create file path
-	filePath := filepath.Join(s.basePath, id+".json")
+	filePath := filepath.Join(s.basePath, id+secretFileExt)
 
 	// Read file
 	data, err := os.ReadFile(filePath)
@@ -108,19 +387,17 @@ func (s *FileStore) Get(id string) (*models.Secret, error) {
 		return nil, fmt.Errorf("failed to read secret file: %w", err)
 	}
 
-	// Unmarshal JSON
-	var secret models.Secret
-	if err := json.Unmarshal(data, &secret); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal secret: %w", err)
-	}
-
-	return &secret, nil
+	return s.decodeSecret(data)
 }
 
 func (s *FileStore) GetByCustomName(name string) (*models.Secret, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	if s.records != nil {
+		return s.findIndexedName(name)
+	}
+
 	// List all files in the directory
 	files, err := os.ReadDir(s.basePath)
 	if err != nil {
@@ -129,7 +406,7 @@ func (s *FileStore) GetByCustomName(name string) (*models.Secret, error) {
 
 	// Search for a secret with matching custom name
 	for _, file := range files {
-		if file.IsDir() {
+		if !isSecretFile(file) {
 			continue
 		}
 
@@ -139,13 +416,13 @@ func (s *FileStore) GetByCustomName(name string) (*models.Secret, error) {
 			continue
 		}
 
-		var secret models.Secret
-		if err := json.Unmarshal(data, &secret); err != nil {
+		secret, err := s.decodeSecret(data)
+		if err != nil {
 			continue
 		}
 
 		if secret.CustomName == name {
-			return &secret, nil
+			return secret, nil
 		}
 	}
 
@@ -156,7 +433,20 @@ func (s *FileStore) Delete(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	filePath := filepath.Join(s.basePath, id+".json")
+	return s.removeSecret(id)
+}
+
+// removeSecret deletes a secret file; callers must hold the write lock
+func (s *FileStore) removeSecret(id string) error {
+	// The name index entry can only be found through the secret's name
+	var name string
+	if s.records != nil {
+		if secret, err := s.readSecret(id); err == nil && secret != nil {
+			name = secret.CustomName
+		}
+	}
+
+	filePath := filepath.Join(s.basePath, id+secretFileExt)
 	if err := os.Remove(filePath); err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -164,64 +454,246 @@ func (s *FileStore) Delete(id string) error {
 		return fmt.Errorf("failed to delete secret file: %w", err)
 	}
 
+	s.count--
+	return s.unindexName(name, id)
+}
+
+// RecordView atomically counts a view of a secret and deletes it once its view
+// limit is reached. It returns the updated secret, or nil if the secret no
+// longer exists (for example because a concurrent view already burned it).
+func (s *FileStore) RecordView(id string) (*models.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, err := s.readSecret(id)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+
+	// The grace window has passed, burn the secret instead of serving it
+	if secret.BurnDue(s.clock.Now(), s.burnGrace) {
+		return nil, s.removeSecret(id)
+	}
+
+	secret.ViewCount++
+	s.totalViews++
+	s.viewAges.Observe(s.clock.Now().Sub(secret.CreatedAt))
+
+	if secret.ViewsExhausted() {
+		if s.burnGrace > 0 {
+			// Keep serving the secret until the grace window elapses
+			if secret.BurnPendingSince == nil {
+				now := s.clock.Now()
+				secret.BurnPendingSince = &now
+			}
+			if err := s.writeSecret(secret); err != nil {
+				return nil, err
+			}
+			return secret, nil
+		}
+		if err := s.removeSecret(id); err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+
+	if err := s.writeSecret(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// TotalViews returns the number of views recorded since the store was created
+func (s *FileStore) TotalViews() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.totalViews
+}
+
+// DeleteExpired deletes a secret found to have expired and records its
+// lifetime in the age-at-expiry histogram
+func (s *FileStore) DeleteExpired(secret *models.Secret) error {
+	if err := s.Delete(secret.ID); err != nil {
+		return err
+	}
+	s.expiryAges.Observe(secret.Lifetime())
 	return nil
 }
 
+// ViewAges returns the distribution of secret ages at the time they were viewed
+func (s *FileStore) ViewAges() HistogramSnapshot {
+	return s.viewAges.Snapshot()
+}
+
+// ExpiryAges returns the distribution of lifetimes of secrets that expired
+func (s *FileStore) ExpiryAges() HistogramSnapshot {
+	return s.expiryAges.Snapshot()
+}
+
+// CleanExpired deletes expired and burn-due secrets. The directory is listed
+// and scanned without holding the store lock, so reads and writes continue
+// during a slow scan; each deletion re-checks its secret under the write lock.
+// Secrets removed or replaced by concurrent requests while the scan runs are
+// skipped rather than counted as errors.
 func (fs *FileStore) CleanExpired() error {
-	files, err := ioutil.ReadDir(fs.basePath)
+	files, err := os.ReadDir(fs.basePath)
 	if err != nil {
+		fs.recordCleanupRun(0, 0, 0, 1)
 		return fmt.Errorf("failed to read storage directory: %w", err)
 	}
 
-	var deletedCount int
+	var deletedCount, wouldCleanCount, errorCount int
+	var bytesCleaned int64
 	for _, file := range files {
-		if file.IsDir() {
+		if !isSecretFile(file) {
 			continue
 		}
 
 		filePath := filepath.Join(fs.basePath, file.Name())
-		data, err := ioutil.ReadFile(filePath)
+		data, err := os.ReadFile(filePath)
 		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Deleted since the directory was listed
+			}
 			logger.Error("Failed to read secret file", map[string]interface{}{
 				"file":  file.Name(),
 				"error": err.Error(),
 			})
+			errorCount++
 			continue
 		}
 
-		var secret models.Secret
-		if err := json.Unmarshal(data, &secret); err != nil {
+		secret, err := fs.decodeSecret(data)
+		if err != nil {
 			logger.Error("Failed to unmarshal secret", map[string]interface{}{
 				"file":  file.Name(),
 				"error": err.Error(),
 			})
+			errorCount++
 			continue
 		}
 
-		if secret.IsExpired() {
-			if err := os.Remove(filePath); err != nil {
-				logger.Error("Failed to delete expired secret", map[string]interface{}{
-					"file":  filePath,
-					"error": err.Error(),
-				})
-				continue
-			}
-			deletedCount++
+		if !fs.cleanupDue(secret) {
+			continue
+		}
+		if fs.dryRun {
+			logger.Info("Dry run: would delete expired secret", map[string]interface{}{
+				"file": file.Name(),
+			})
+			wouldCleanCount++
+			continue
+		}
+
+		removed, size, err := fs.removeIfDue(strings.TrimSuffix(file.Name(), secretFileExt))
+		if err != nil {
+			logger.Error("Failed to delete expired secret", map[string]interface{}{
+				"file":  filePath,
+				"error": err.Error(),
+			})
+			errorCount++
+			continue
+		}
+		if removed == nil {
+			continue
+		}
+		deletedCount++
+		bytesCleaned += size
+
+		now := fs.clock.Now()
+		if removed.IsExpired(now) {
+			fs.expiryAges.Observe(removed.Lifetime())
+			logger.AuditAge("expire", removed.ID, "", removed.Lifetime())
+		} else if fs.pastMaxAge(removed, now) {
+			age := now.Sub(removed.CreatedAt)
+			fs.expiryAges.Observe(age)
+			logger.AuditAge("expire", removed.ID, "", age)
+		} else {
+			logger.Audit("burn", removed.ID, "")
 		}
 	}
 
 	logger.Debug("Cleaned up expired secrets", map[string]interface{}{
-		"deleted_count": deletedCount,
+		"deleted_count":     deletedCount,
+		"bytes_cleaned":     bytesCleaned,
+		"would_clean_count": wouldCleanCount,
 	})
 
+	fs.recordCleanupRun(deletedCount, bytesCleaned, wouldCleanCount, errorCount)
+
 	fs.mu.Lock()
-	fs.cleanupStats.secretsCleaned = deletedCount
-	fs.cleanupStats.lastRun = time.Now()
+	fs.scanned = true
 	fs.mu.Unlock()
 
 	return nil
 }
 
+// Initialized reports whether CleanExpired has scanned the whole storage
+// directory successfully at least once
+func (s *FileStore) Initialized() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scanned
+}
+
+// cleanupDue reports whether cleanup should delete the secret
+func (fs *FileStore) cleanupDue(secret *models.Secret) bool {
+	now := fs.clock.Now()
+	return (secret.IsExpired(now) && !fs.RetainsExpired(secret)) || secret.BurnDue(now, fs.burnGrace) || fs.pastMaxAge(secret, now)
+}
+
+// RetainsExpired reports whether an expired secret is still inside the
+// expired retention window and must be kept rather than deleted
+func (fs *FileStore) RetainsExpired(secret *models.Secret) bool {
+	return fs.retention > 0 && !secret.IsExpired(fs.clock.Now().Add(-fs.retention))
+}
+
+// pastMaxAge reports whether the secret has outlived the store's maximum age
+func (fs *FileStore) pastMaxAge(secret *models.Secret, now time.Time) bool {
+	return fs.maxAge > 0 && now.Sub(secret.CreatedAt) > fs.maxAge
+}
+
+// removeIfDue deletes the secret under the write lock if it still exists and
+// is still due for cleanup, returning the removed secret and its size on disk.
+// A nil secret means there was nothing to remove.
+func (fs *FileStore) removeIfDue(id string) (*models.Secret, int64, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	secret, err := fs.readSecret(id)
+	if err != nil || secret == nil || !fs.cleanupDue(secret) {
+		return nil, 0, err
+	}
+
+	// Stat before removing so the freed space can be reported
+	info, err := os.Stat(filepath.Join(fs.basePath, id+secretFileExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to stat secret file: %w", err)
+	}
+	if err := fs.removeSecret(id); err != nil {
+		return nil, 0, err
+	}
+	return secret, info.Size(), nil
+}
+
+// recordCleanupRun replaces the last-run statistics and adds to the totals
+func (fs *FileStore) recordCleanupRun(cleaned int, bytes int64, wouldClean, errors int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.cleanupStats.lastRun = fs.clock.Now()
+	fs.cleanupStats.secretsCleaned = cleaned
+	fs.cleanupStats.bytesCleaned = bytes
+	fs.cleanupStats.wouldClean = wouldClean
+	fs.cleanupStats.errors = errors
+	fs.cleanupStats.totalCleaned += cleaned
+	fs.cleanupStats.totalBytes += bytes
+	fs.cleanupStats.totalRuns++
+	fs.cleanupStats.totalErrors += errors
+}
+
 // IsCustomNameTaken checks if a custom name is already in use
 func (s *FileStore) IsCustomNameTaken(name string) (bool, error) {
 	s.mu.RLock()
@@ -232,6 +704,11 @@ func (s *FileStore) IsCustomNameTaken(name string) (bool, error) {
 		return false, nil
 	}
 
+	if s.records != nil {
+		secret, err := s.findIndexedName(name)
+		return secret != nil, err
+	}
+
 	// List all files in the directory
 	files, err := os.ReadDir(s.basePath)
 	if err != nil {
@@ -240,7 +717,7 @@ func (s *FileStore) IsCustomNameTaken(name string) (bool, error) {
 
 	// Search for a secret with matching custom name
 	for _, file := range files {
-		if file.IsDir() {
+		if !isSecretFile(file) {
 			continue
 		}
 
@@ -250,8 +727,8 @@ func (s *FileStore) IsCustomNameTaken(name string) (bool, error) {
 			continue
 		}
 
-		var secret models.Secret
-		if err := json.Unmarshal(data, &secret); err != nil {
+		secret, err := s.decodeSecret(data)
+		if err != nil {
 			continue
 		}
 
@@ -262,3 +739,146 @@ func (s *FileStore) IsCustomNameTaken(name string) (bool, error) {
 
 	return false, nil
 }
+
+// Export writes all current (non-expired) secrets to w as a gzipped tar of
+// their JSON files. Secrets stay server-side encrypted inside the archive.
+func (s *FileStore) Export(w io.Writer) (int, error) {
+	files, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	exported := 0
+	for _, file := range files {
+		if !isSecretFile(file) {
+			continue
+		}
+
+		s.mu.RLock()
+		data, err := os.ReadFile(filepath.Join(s.basePath, file.Name()))
+		s.mu.RUnlock()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Deleted since the directory was listed
+			}
+			return exported, fmt.Errorf("failed to read secret file: %w", err)
+		}
+
+		secret, err := s.decodeSecret(data)
+		if err != nil || secret.IsExpired(s.clock.Now()) {
+			continue
+		}
+
+		header := &tar.Header{
+			Name:    file.Name(),
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: secret.CreatedAt,
+		}
+		if s.records != nil {
+			header.ModTime = time.Unix(0, 0) // Don't reveal encrypted metadata
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return exported, fmt.Errorf("failed to write archive header: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return exported, fmt.Errorf("failed to write archive entry: %w", err)
+		}
+		exported++
+	}
+
+	if err := tw.Close(); err != nil {
+		return exported, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return exported, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return exported, nil
+}
+
+// ImportResult reports the outcome of an Import
+type ImportResult struct {
+	Imported int
+	Skipped  int // Expired, unreadable, or colliding with an existing ID or name
+}
+
+// ImportLimits bounds what an Import accepts. Zero values mean no limit.
+type ImportLimits struct {
+	MaxTotal      int   // Stop with ErrStoreAtCapacity once the store holds this many secrets
+	MaxEntryBytes int64 // Reject archive entries larger than this as an invalid archive
+}
+
+// Import restores secrets from an archive produced by Export. Secrets whose ID
+// or custom name is already in use are skipped rather than overwritten. When
+// a limit stops the import, what was imported until then is kept.
+func (s *FileStore) Import(r io.Reader, limits ImportLimits) (ImportResult, error) {
+	var result ImportResult
+
+	if !s.Writable() {
+		return result, ErrStorageUnwritable
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return result, fmt.Errorf("%w: failed to open archive: %w", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("%w: failed to read archive: %w", ErrInvalidArchive, err)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, secretFileExt) {
+			result.Skipped++
+			continue
+		}
+
+		// Check the declared size first, and don't trust it while reading
+		entry := io.Reader(tr)
+		if limits.MaxEntryBytes > 0 {
+			if header.Size > limits.MaxEntryBytes {
+				return result, fmt.Errorf("%w: entry %s is larger than %d bytes", ErrInvalidArchive, header.Name, limits.MaxEntryBytes)
+			}
+			entry = io.LimitReader(tr, limits.MaxEntryBytes+1)
+		}
+		data, err := io.ReadAll(entry)
+		if err != nil {
+			return result, fmt.Errorf("%w: failed to read archive entry: %w", ErrInvalidArchive, err)
+		}
+		if limits.MaxEntryBytes > 0 && int64(len(data)) > limits.MaxEntryBytes {
+			return result, fmt.Errorf("%w: entry %s is larger than %d bytes", ErrInvalidArchive, header.Name, limits.MaxEntryBytes)
+		}
+
+		// Only accept entries whose name matches the secret they contain, so an
+		// archive can't write outside the storage directory
+		secret, err := s.decodeSecret(data)
+		if err != nil ||
+			secret.ID == "" || header.Name != secret.ID+secretFileExt ||
+			filepath.Base(header.Name) != header.Name || secret.IsExpired(s.clock.Now()) {
+			result.Skipped++
+			continue
+		}
+
+		if limits.MaxTotal > 0 && s.Count() >= limits.MaxTotal {
+			return result, ErrStoreAtCapacity
+		}
+		if err := s.Create(secret); err != nil {
+			if errors.Is(err, ErrIDExists) || strings.Contains(err.Error(), "already taken") {
+				result.Skipped++
+				continue
+			}
+			return result, err
+		}
+		result.Imported++
+	}
+
+	return result, nil
+}
@@ -1,11 +1,19 @@
 package file
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"secrets-share/internal/encryption"
 	"secrets-share/internal/logger"
 	"secrets-share/internal/models"
 
@@ -24,7 +32,8 @@ func setupTestDir(t *testing.T) (string, func()) {
 		},
 	}
 
-	// Initialize logger
+	// Initialize logger, replacing one configured by an earlier test
+	logger.Reset()
 	err := logger.Init(cfg, false)
 	if err != nil {
 		t.Fatalf("Failed to initialize logger: %v", err)
@@ -53,7 +62,7 @@ func TestFileStore(t *testing.T) {
 
 	// Create a test secret
 	secret := &models.Secret{
-		ID:         uuid.New(),
+		ID:         uuid.NewString(),
 		CustomName: "test-secret",
 		CreatedAt:  time.Now(),
 	}
@@ -66,7 +75,7 @@ func TestFileStore(t *testing.T) {
 		}
 
 		// Verify file exists
-		filePath := filepath.Join(testDir, secret.ID.String()+".json")
+		filePath := filepath.Join(testDir, secret.ID+".json")
 		if _, err := os.Stat(filePath); os.IsNotExist(err) {
 			t.Error("Secret file was not created")
 		}
@@ -74,7 +83,7 @@ func TestFileStore(t *testing.T) {
 
 	// Test retrieving a secret by ID
 	t.Run("Get secret by ID", func(t *testing.T) {
-		retrieved, err := store.Get(secret.ID.String())
+		retrieved, err := store.Get(secret.ID)
 		if err != nil {
 			t.Fatalf("Failed to get secret: %v", err)
 		}
@@ -105,19 +114,19 @@ func TestFileStore(t *testing.T) {
 
 	// Test deleting a secret
 	t.Run("Delete secret", func(t *testing.T) {
-		err := store.Delete(secret.ID.String())
+		err := store.Delete(secret.ID)
 		if err != nil {
 			t.Fatalf("Failed to delete secret: %v", err)
 		}
 
 		// Verify file is deleted
-		filePath := filepath.Join(testDir, secret.ID.String()+".json")
+		filePath := filepath.Join(testDir, secret.ID+".json")
 		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
 			t.Error("Secret file was not deleted")
 		}
 
 		// Try to get deleted secret
-		retrieved, err := store.Get(secret.ID.String())
+		retrieved, err := store.Get(secret.ID)
 		if err != nil {
 			t.Fatalf("Unexpected error when getting deleted secret: %v", err)
 		}
@@ -139,7 +148,7 @@ func TestExpiredSecrets(t *testing.T) {
 	// Create an expired secret
 	expiredTime := time.Now().Add(-1 * time.Hour)
 	expiredSecret := &models.Secret{
-		ID:        uuid.New(),
+		ID:        uuid.NewString(),
 		CreatedAt: time.Now(),
 		ExpiresAt: &expiredTime,
 	}
@@ -157,7 +166,7 @@ func TestExpiredSecrets(t *testing.T) {
 		}
 
 		// Verify expired secret is deleted
-		retrieved, err := store.Get(expiredSecret.ID.String())
+		retrieved, err := store.Get(expiredSecret.ID)
 		if err != nil {
 			t.Fatalf("Unexpected error when getting expired secret: %v", err)
 		}
@@ -177,7 +186,7 @@ func TestBurnAfterReading(t *testing.T) {
 	}
 
 	secret := &models.Secret{
-		ID:                 uuid.New(),
+		ID:                 uuid.NewString(),
 		CreatedAt:          time.Now(),
 		IsBurnAfterReading: true,
 		EncryptedData:      []byte("test-data"),
@@ -189,7 +198,7 @@ func TestBurnAfterReading(t *testing.T) {
 	}
 
 	// First read should succeed
-	retrieved, err := store.Get(secret.ID.String())
+	retrieved, err := store.Get(secret.ID)
 	if err != nil {
 		t.Fatalf("Failed to get secret: %v", err)
 	}
@@ -198,12 +207,12 @@ func TestBurnAfterReading(t *testing.T) {
 	}
 
 	// Delete the secret immediately after reading
-	if err := store.Delete(secret.ID.String()); err != nil {
+	if err := store.Delete(secret.ID); err != nil {
 		t.Fatalf("Failed to delete secret: %v", err)
 	}
 
 	// Second read should return nil (secret should be deleted)
-	retrieved, err = store.Get(secret.ID.String())
+	retrieved, err = store.Get(secret.ID)
 	if err != nil {
 		t.Fatalf("Unexpected error when getting deleted secret: %v", err)
 	}
@@ -223,7 +232,7 @@ func TestCustomNameUniqueness(t *testing.T) {
 
 	// Create first secret with custom name
 	firstSecret := &models.Secret{
-		ID:         uuid.New(),
+		ID:         uuid.NewString(),
 		CustomName: "unique-name",
 		CreatedAt:  time.Now(),
 	}
@@ -244,7 +253,7 @@ func TestCustomNameUniqueness(t *testing.T) {
 
 	// Try to store second secret with same custom name
 	secondSecret := &models.Secret{
-		ID:         uuid.New(),
+		ID:         uuid.NewString(),
 		CustomName: "unique-name",
 		CreatedAt:  time.Now(),
 	}
@@ -259,7 +268,7 @@ func TestCustomNameUniqueness(t *testing.T) {
 
 	// Verify empty custom name is allowed
 	emptyNameSecret := &models.Secret{
-		ID:        uuid.New(),
+		ID:        uuid.NewString(),
 		CreatedAt: time.Now(),
 	}
 
@@ -269,7 +278,7 @@ func TestCustomNameUniqueness(t *testing.T) {
 
 	// Verify different custom names are allowed
 	differentNameSecret := &models.Secret{
-		ID:         uuid.New(),
+		ID:         uuid.NewString(),
 		CustomName: "different-name",
 		CreatedAt:  time.Now(),
 	}
@@ -278,3 +287,1220 @@ func TestCustomNameUniqueness(t *testing.T) {
 		t.Errorf("Failed to store secret with different custom name: %v", err)
 	}
 }
+
+func TestConcurrentCustomName(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("race%d", i)
+		start := make(chan struct{})
+		errs := make([]error, 2)
+		var wg sync.WaitGroup
+		for j := range errs {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				<-start
+				errs[j] = store.Create(&models.Secret{ID: uuid.NewString(), CustomName: name, CreatedAt: time.Now()})
+			}(j)
+		}
+		close(start)
+		wg.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+			} else if !strings.Contains(err.Error(), "already taken") {
+				t.Errorf("Unexpected error creating %s: %v", name, err)
+			}
+		}
+		if succeeded != 1 {
+			t.Fatalf("Expected exactly one creator of %s to succeed, got %d", name, succeeded)
+		}
+	}
+
+	if store.Count() != 50 {
+		t.Errorf("Expected count 50, got %d", store.Count())
+	}
+	entries, err := os.ReadDir(testDir)
+	if err != nil {
+		t.Fatalf("Failed to read storage directory: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), reservationExt) {
+			t.Errorf("Reservation %s was not released", entry.Name())
+		}
+	}
+
+	// A reservation left by a stopped writer is cleared when the store opens
+	stale := filepath.Join(testDir, store.nameKey("stale")+reservationExt)
+	if err := os.WriteFile(stale, nil, 0600); err != nil {
+		t.Fatalf("Failed to write reservation: %v", err)
+	}
+	reopened, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	if err := reopened.Create(&models.Secret{ID: uuid.NewString(), CustomName: "stale", CreatedAt: time.Now()}); err != nil {
+		t.Errorf("Expected a stale reservation to be cleared, got %v", err)
+	}
+}
+
+func TestAtomicStore(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	oldData := []byte(strings.Repeat("a", 64*1024))
+	newData := []byte(strings.Repeat("b", 64*1024))
+
+	secret := &models.Secret{
+		ID:            uuid.NewString(),
+		CreatedAt:     time.Now(),
+		EncryptedData: oldData,
+	}
+	if err := store.Store(secret); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	t.Run("Readers never observe partial writes", func(t *testing.T) {
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				updated := *secret
+				if i%2 == 0 {
+					updated.EncryptedData = newData
+				} else {
+					updated.EncryptedData = oldData
+				}
+				if err := store.Store(&updated); err != nil {
+					t.Errorf("Failed to store secret: %v", err)
+					return
+				}
+			}
+		}()
+
+		for i := 0; i < 200; i++ {
+			retrieved, err := store.Get(secret.ID)
+			if err != nil {
+				t.Fatalf("Failed to get secret: %v", err)
+			}
+			if retrieved == nil {
+				t.Fatal("Secret should always be retrievable")
+			}
+			if !bytes.Equal(retrieved.EncryptedData, oldData) && !bytes.Equal(retrieved.EncryptedData, newData) {
+				t.Fatal("Read a partially written secret")
+			}
+		}
+		close(done)
+		wg.Wait()
+	})
+
+	t.Run("No temporary files are left behind", func(t *testing.T) {
+		entries, err := os.ReadDir(testDir)
+		if err != nil {
+			t.Fatalf("Failed to read directory: %v", err)
+		}
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), tempFileExt) {
+				t.Errorf("Unexpected temporary file %s", entry.Name())
+			}
+		}
+	})
+
+	t.Run("Temporary files are ignored by lookups", func(t *testing.T) {
+		named := &models.Secret{
+			ID:         uuid.NewString(),
+			CustomName: "pending",
+			CreatedAt:  time.Now(),
+		}
+		data, err := json.Marshal(named)
+		if err != nil {
+			t.Fatalf("Failed to marshal secret: %v", err)
+		}
+		tmpPath := filepath.Join(testDir, named.ID+secretFileExt+tempFileExt)
+		if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+			t.Fatalf("Failed to write temp file: %v", err)
+		}
+
+		taken, err := store.IsCustomNameTaken("pending")
+		if err != nil {
+			t.Fatalf("Failed to check custom name: %v", err)
+		}
+		if taken {
+			t.Error("Custom name of an uncommitted secret should not be taken")
+		}
+	})
+}
+
+func TestRecordView(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	maxViews := 2
+	secret := &models.Secret{
+		ID:        uuid.NewString(),
+		CreatedAt: time.Now(),
+		MaxViews:  &maxViews,
+	}
+	if err := store.Store(secret); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	for i := 1; i <= maxViews; i++ {
+		viewed, err := store.RecordView(secret.ID)
+		if err != nil {
+			t.Fatalf("Failed to record view: %v", err)
+		}
+		if viewed == nil || viewed.ViewCount != i {
+			t.Fatalf("Expected view count %d, got %+v", i, viewed)
+		}
+	}
+
+	// The secret is gone once its view limit is reached
+	retrieved, err := store.Get(secret.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error when getting secret: %v", err)
+	}
+	if retrieved != nil {
+		t.Error("Secret should have been deleted after its last view")
+	}
+
+	viewed, err := store.RecordView(secret.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error recording view of deleted secret: %v", err)
+	}
+	if viewed != nil {
+		t.Error("Expected no secret after its views were exhausted")
+	}
+
+	if store.TotalViews() != int64(maxViews) {
+		t.Errorf("Expected %d total views, got %d", maxViews, store.TotalViews())
+	}
+}
+
+func TestCount(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	secret := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now()}
+	if err := store.Store(secret); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	expiredTime := time.Now().Add(-time.Hour)
+	expired := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now(), ExpiresAt: &expiredTime}
+	if err := store.Store(expired); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	if store.Count() != 2 {
+		t.Fatalf("Expected count 2, got %d", store.Count())
+	}
+
+	// Overwriting an existing secret doesn't change the count
+	if err := store.Store(secret); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	if store.Count() != 2 {
+		t.Errorf("Expected count 2 after overwrite, got %d", store.Count())
+	}
+
+	// A new store picks up existing secrets
+	reopened, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen file store: %v", err)
+	}
+	if reopened.Count() != 2 {
+		t.Errorf("Expected reopened count 2, got %d", reopened.Count())
+	}
+
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+	if store.Count() != 1 {
+		t.Errorf("Expected count 1 after cleanup, got %d", store.Count())
+	}
+
+	if err := store.Delete(secret.ID); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+	// Deleting a missing secret doesn't change the count
+	if err := store.Delete(secret.ID); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+	if store.Count() != 0 {
+		t.Errorf("Expected count 0 after delete, got %d", store.Count())
+	}
+}
+
+func TestCleanupDryRun(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir, WithCleanupDryRun(true))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	expiredTime := time.Now().Add(-time.Hour)
+	var expiredIDs []string
+	for i := 0; i < 2; i++ {
+		secret := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now(), ExpiresAt: &expiredTime}
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+		expiredIDs = append(expiredIDs, secret.ID)
+	}
+	if err := store.Store(&models.Secret{ID: uuid.NewString(), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+
+	for _, id := range expiredIDs {
+		retrieved, err := store.Get(id)
+		if err != nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		if retrieved == nil {
+			t.Errorf("Dry run should not delete expired secret %s", id)
+		}
+	}
+
+	stats := store.GetCleanupStats()
+	if stats.WouldClean != 2 {
+		t.Errorf("Expected 2 would-clean secrets, got %d", stats.WouldClean)
+	}
+	if stats.SecretsCleaned != 0 {
+		t.Errorf("Expected 0 cleaned secrets, got %d", stats.SecretsCleaned)
+	}
+	if store.Count() != 3 {
+		t.Errorf("Expected count 3, got %d", store.Count())
+	}
+}
+
+func TestBurnGrace(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir, WithBurnGrace(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	newBurnSecret := func() string {
+		secret := &models.Secret{
+			ID:                 uuid.NewString(),
+			CreatedAt:          time.Now(),
+			IsBurnAfterReading: true,
+		}
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+		return secret.ID
+	}
+
+	// expireGrace moves a pending burn back past the grace window
+	expireGrace := func(id string) {
+		secret, err := store.Get(id)
+		if err != nil || secret == nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		past := time.Now().Add(-2 * time.Minute)
+		secret.BurnPendingSince = &past
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+	}
+
+	t.Run("Re-read inside the grace window", func(t *testing.T) {
+		id := newBurnSecret()
+
+		for i := 1; i <= 2; i++ {
+			viewed, err := store.RecordView(id)
+			if err != nil {
+				t.Fatalf("Failed to record view: %v", err)
+			}
+			if viewed == nil {
+				t.Fatalf("Expected secret to be served on read %d", i)
+			}
+			if viewed.BurnPendingSince == nil {
+				t.Error("Expected secret to be pending burn")
+			}
+		}
+	})
+
+	t.Run("Re-read outside the grace window", func(t *testing.T) {
+		id := newBurnSecret()
+
+		if viewed, err := store.RecordView(id); err != nil || viewed == nil {
+			t.Fatalf("Expected first read to succeed: %v", err)
+		}
+		expireGrace(id)
+
+		viewed, err := store.RecordView(id)
+		if err != nil {
+			t.Fatalf("Failed to record view: %v", err)
+		}
+		if viewed != nil {
+			t.Error("Expected secret to be burned after the grace window")
+		}
+		if retrieved, _ := store.Get(id); retrieved != nil {
+			t.Error("Secret should have been deleted")
+		}
+	})
+
+	t.Run("Cleanup burns elapsed secrets", func(t *testing.T) {
+		id := newBurnSecret()
+		if _, err := store.RecordView(id); err != nil {
+			t.Fatalf("Failed to record view: %v", err)
+		}
+		expireGrace(id)
+
+		if err := store.CleanExpired(); err != nil {
+			t.Fatalf("Failed to clean expired secrets: %v", err)
+		}
+		if retrieved, _ := store.Get(id); retrieved != nil {
+			t.Error("Cleanup should have deleted the burned secret")
+		}
+	})
+}
+
+func TestCreateIDCollision(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	secret := &models.Secret{ID: "shortid", CreatedAt: time.Now()}
+	if err := store.Create(secret); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	duplicate := &models.Secret{ID: "shortid", CreatedAt: time.Now(), CustomName: "other"}
+	if err := store.Create(duplicate); !errors.Is(err, ErrIDExists) {
+		t.Fatalf("Expected ErrIDExists, got %v", err)
+	}
+
+	// The original secret was not overwritten
+	retrieved, err := store.Get("shortid")
+	if err != nil || retrieved == nil {
+		t.Fatalf("Failed to get secret: %v", err)
+	}
+	if retrieved.CustomName != "" {
+		t.Error("Create overwrote an existing secret")
+	}
+	if store.Count() != 1 {
+		t.Errorf("Expected count 1, got %d", store.Count())
+	}
+}
+
+func TestCleanupTotals(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	storeExpired := func(n int) {
+		expiredTime := time.Now().Add(-time.Hour)
+		for i := 0; i < n; i++ {
+			secret := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now(), ExpiresAt: &expiredTime}
+			if err := store.Store(secret); err != nil {
+				t.Fatalf("Failed to store secret: %v", err)
+			}
+		}
+	}
+
+	storeExpired(3)
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+
+	// An unreadable secret file counts as a cleanup error
+	storeExpired(2)
+	if err := os.WriteFile(filepath.Join(testDir, "corrupt"+secretFileExt), []byte("not json"), 0600); err != nil {
+		t.Fatalf("Failed to write corrupt file: %v", err)
+	}
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+
+	stats := store.GetCleanupStats()
+	if stats.SecretsCleaned != 2 || stats.Errors != 1 {
+		t.Errorf("Expected last run to clean 2 with 1 error, got %d cleaned and %d errors", stats.SecretsCleaned, stats.Errors)
+	}
+	if stats.TotalCleaned != 5 {
+		t.Errorf("Expected 5 secrets cleaned in total, got %d", stats.TotalCleaned)
+	}
+	if stats.TotalRuns != 2 {
+		t.Errorf("Expected 2 runs, got %d", stats.TotalRuns)
+	}
+	if stats.TotalErrors != 1 {
+		t.Errorf("Expected 1 error in total, got %d", stats.TotalErrors)
+	}
+}
+
+func TestCleanupBytes(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	expiredTime := time.Now().Add(-time.Hour)
+	var expected int64
+	for _, size := range []int{10, 1000, 50000} {
+		secret := &models.Secret{
+			ID:            uuid.NewString(),
+			EncryptedData: bytes.Repeat([]byte("x"), size),
+			CreatedAt:     time.Now(),
+			ExpiresAt:     &expiredTime,
+		}
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+		info, err := os.Stat(filepath.Join(testDir, secret.ID+secretFileExt))
+		if err != nil {
+			t.Fatalf("Failed to stat secret file: %v", err)
+		}
+		expected += info.Size()
+	}
+
+	// A live secret is not counted
+	if err := store.Store(&models.Secret{ID: uuid.NewString(), EncryptedData: []byte("live"), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+
+	stats := store.GetCleanupStats()
+	if stats.BytesCleaned != expected {
+		t.Errorf("Expected %d bytes cleaned, got %d", expected, stats.BytesCleaned)
+	}
+	if stats.TotalBytes != expected {
+		t.Errorf("Expected %d bytes cleaned in total, got %d", expected, stats.TotalBytes)
+	}
+
+	// A run with nothing to remove resets the last-run figure but keeps the total
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+	stats = store.GetCleanupStats()
+	if stats.BytesCleaned != 0 || stats.TotalBytes != expected {
+		t.Errorf("Expected 0 bytes last run and %d in total, got %d and %d", expected, stats.BytesCleaned, stats.TotalBytes)
+	}
+}
+
+func TestConcurrentCleanup(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	// Churn expired and live secrets while cleanup scans the directory
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			expiredTime := time.Now().Add(-time.Hour)
+			for i := 0; i < 200; i++ {
+				secret := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now()}
+				if i%2 == 0 {
+					secret.ExpiresAt = &expiredTime
+				}
+				if err := store.Store(secret); err != nil {
+					t.Errorf("Failed to store secret: %v", err)
+					return
+				}
+				// Deleting expired secrets races with cleanup removing them
+				if i%4 == 0 {
+					if err := store.Delete(secret.ID); err != nil {
+						t.Errorf("Failed to delete secret: %v", err)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if err := store.CleanExpired(); err != nil {
+			t.Fatalf("Failed to clean expired secrets: %v", err)
+		}
+	}
+
+	stats := store.GetCleanupStats()
+	if stats.TotalErrors != 0 {
+		t.Errorf("Expected no cleanup errors, got %d", stats.TotalErrors)
+	}
+
+	// Every expired secret is gone and the count matches the directory
+	files, err := os.ReadDir(testDir)
+	if err != nil {
+		t.Fatalf("Failed to read storage directory: %v", err)
+	}
+	remaining := 0
+	for _, file := range files {
+		if !isSecretFile(file) {
+			continue
+		}
+		remaining++
+		secret, err := store.Get(strings.TrimSuffix(file.Name(), secretFileExt))
+		if err != nil || secret == nil {
+			t.Fatalf("Failed to get secret: %v", err)
+		}
+		if secret.IsExpired(time.Now()) {
+			t.Errorf("Expired secret %s survived the final cleanup", secret.ID)
+		}
+	}
+	if store.Count() != remaining {
+		t.Errorf("Expected count %d, got %d", remaining, store.Count())
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	sourceDir, cleanupSource := setupTestDir(t)
+	defer cleanupSource()
+
+	source, err := NewFileStore(sourceDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	expiredTime := time.Now().Add(-time.Hour)
+	live := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now(), CustomName: "backup", EncryptedData: []byte("ciphertext")}
+	expired := &models.Secret{ID: uuid.NewString(), CreatedAt: time.Now(), ExpiresAt: &expiredTime}
+	for _, secret := range []*models.Secret{live, expired} {
+		if err := source.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+	}
+
+	var archive bytes.Buffer
+	exported, err := source.Export(&archive)
+	if err != nil {
+		t.Fatalf("Failed to export secrets: %v", err)
+	}
+	if exported != 1 {
+		t.Errorf("Expected 1 exported secret, got %d", exported)
+	}
+
+	targetDir, cleanupTarget := setupTestDir(t)
+	defer cleanupTarget()
+
+	target, err := NewFileStore(targetDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	result, err := target.Import(bytes.NewReader(archive.Bytes()), ImportLimits{})
+	if err != nil {
+		t.Fatalf("Failed to import secrets: %v", err)
+	}
+	if result.Imported != 1 || result.Skipped != 0 {
+		t.Errorf("Expected 1 imported and 0 skipped, got %+v", result)
+	}
+
+	restored, err := target.GetByCustomName("backup")
+	if err != nil || restored == nil {
+		t.Fatalf("Failed to get restored secret: %v", err)
+	}
+	if restored.ID != live.ID || !bytes.Equal(restored.EncryptedData, live.EncryptedData) {
+		t.Errorf("Restored secret does not match the original: %+v", restored)
+	}
+	if target.Count() != 1 {
+		t.Errorf("Expected count 1, got %d", target.Count())
+	}
+
+	// Importing the same archive again skips the existing ID
+	result, err = target.Import(bytes.NewReader(archive.Bytes()), ImportLimits{})
+	if err != nil {
+		t.Fatalf("Failed to import secrets: %v", err)
+	}
+	if result.Imported != 0 || result.Skipped != 1 {
+		t.Errorf("Expected 0 imported and 1 skipped, got %+v", result)
+	}
+
+	if _, err := target.Import(strings.NewReader("not an archive"), ImportLimits{}); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("Expected ErrInvalidArchive importing invalid data, got %v", err)
+	}
+
+	// Entries over the size limit are rejected before they are read
+	if _, err := target.Import(bytes.NewReader(archive.Bytes()), ImportLimits{MaxEntryBytes: 16}); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("Expected ErrInvalidArchive for an oversized entry, got %v", err)
+	}
+
+	// A store already at max_total refuses further imports
+	fullDir, cleanupFull := setupTestDir(t)
+	defer cleanupFull()
+
+	full, err := NewFileStore(fullDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	if err := full.Store(&models.Secret{ID: uuid.NewString(), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	result, err = full.Import(bytes.NewReader(archive.Bytes()), ImportLimits{MaxTotal: 1})
+	if !errors.Is(err, ErrStoreAtCapacity) {
+		t.Errorf("Expected ErrStoreAtCapacity, got %v", err)
+	}
+	if result.Imported != 0 || full.Count() != 1 {
+		t.Errorf("Expected nothing imported into a full store, got %+v and count %d", result, full.Count())
+	}
+
+	// An unwritable store refuses imports up front
+	full.writeFile = func(name string, data []byte, perm os.FileMode) error {
+		return &os.PathError{Op: "open", Path: name, Err: syscall.EACCES}
+	}
+	if err := full.ProbeWritable(); err == nil {
+		t.Fatal("Expected the write probe to fail")
+	}
+	if _, err := full.Import(bytes.NewReader(archive.Bytes()), ImportLimits{}); !errors.Is(err, ErrStorageUnwritable) {
+		t.Errorf("Expected ErrStorageUnwritable, got %v", err)
+	}
+}
+
+func TestStorageWriteFailures(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	if !store.Writable() {
+		t.Fatal("Expected a fresh store to be writable")
+	}
+
+	failWith := func(errno syscall.Errno) {
+		store.writeFile = func(name string, data []byte, perm os.FileMode) error {
+			return &os.PathError{Op: "open", Path: name, Err: errno}
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		errno    syscall.Errno
+		expected error
+	}{
+		{name: "Disk full", errno: syscall.ENOSPC, expected: ErrDiskFull},
+		{name: "Permission denied", errno: syscall.EACCES, expected: ErrStorageUnwritable},
+		{name: "Read-only filesystem", errno: syscall.EROFS, expected: ErrStorageUnwritable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			failWith(tc.errno)
+
+			err := store.Store(&models.Secret{ID: uuid.NewString(), CreatedAt: time.Now()})
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+			if store.Writable() {
+				t.Error("Expected store to be marked unwritable")
+			}
+
+			// A successful probe marks the store writable again
+			store.writeFile = os.WriteFile
+			if err := store.ProbeWritable(); err != nil {
+				t.Fatalf("Probe failed: %v", err)
+			}
+			if !store.Writable() {
+				t.Error("Expected store to be writable after a successful probe")
+			}
+		})
+	}
+
+	t.Run("Probe detects failures", func(t *testing.T) {
+		failWith(syscall.EACCES)
+		defer func() { store.writeFile = os.WriteFile }()
+
+		if err := store.ProbeWritable(); !errors.Is(err, ErrStorageUnwritable) {
+			t.Errorf("Expected ErrStorageUnwritable, got %v", err)
+		}
+		if store.Writable() {
+			t.Error("Expected store to be marked unwritable")
+		}
+	})
+}
+
+// fakeClock is a Clock that only moves when the test advances it
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClockBoundaries(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store, err := NewFileStore(testDir, WithBurnGrace(time.Minute), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	t.Run("Expiry", func(t *testing.T) {
+		expiresAt := clock.Now().Add(time.Hour)
+		secret := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.Now(), ExpiresAt: &expiresAt}
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+
+		// A secret is still valid at the exact expiry time
+		clock.Advance(time.Hour)
+		if secret.IsExpired(clock.Now()) {
+			t.Error("Secret should not be expired at its expiry time")
+		}
+		if err := store.CleanExpired(); err != nil {
+			t.Fatalf("Failed to clean expired secrets: %v", err)
+		}
+		if retrieved, _ := store.Get(secret.ID); retrieved == nil {
+			t.Fatal("Cleanup deleted a secret at its expiry time")
+		}
+
+		clock.Advance(time.Nanosecond)
+		if !secret.IsExpired(clock.Now()) {
+			t.Error("Secret should be expired just after its expiry time")
+		}
+		if err := store.CleanExpired(); err != nil {
+			t.Fatalf("Failed to clean expired secrets: %v", err)
+		}
+		if retrieved, _ := store.Get(secret.ID); retrieved != nil {
+			t.Error("Cleanup should have deleted the expired secret")
+		}
+		if stats := store.GetCleanupStats(); !stats.LastRun.Equal(clock.Now()) {
+			t.Errorf("Expected last run at %v, got %v", clock.Now(), stats.LastRun)
+		}
+	})
+
+	t.Run("Burn grace", func(t *testing.T) {
+		secret := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.Now(), IsBurnAfterReading: true}
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+		viewed, err := store.RecordView(secret.ID)
+		if err != nil || viewed == nil {
+			t.Fatalf("Expected first read to succeed: %v", err)
+		}
+		if !viewed.BurnPendingSince.Equal(clock.Now()) {
+			t.Errorf("Expected burn pending since %v, got %v", clock.Now(), viewed.BurnPendingSince)
+		}
+
+		// Re-reads are served until the grace window has fully elapsed
+		clock.Advance(time.Minute)
+		if viewed, err := store.RecordView(secret.ID); err != nil || viewed == nil {
+			t.Fatalf("Expected re-read at the end of the grace window to succeed: %v", err)
+		}
+
+		clock.Advance(time.Nanosecond)
+		if viewed, err := store.RecordView(secret.ID); err != nil || viewed != nil {
+			t.Errorf("Expected secret to be burned after the grace window, got %v (err %v)", viewed, err)
+		}
+	})
+}
+
+func TestRecordEncryption(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	encryptor := encryption.NewEncryptor("test-server-key")
+	createdAt := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+	expiresAt := createdAt.Add(48 * time.Hour)
+
+	// A secret stored before record encryption was turned on
+	legacy := &models.Secret{ID: uuid.NewString(), CustomName: "legacy-name", CreatedAt: createdAt, EncryptedData: []byte("old")}
+	plainStore, err := NewFileStore(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	if err := plainStore.Store(legacy); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	store, err := NewFileStore(testDir, WithRecordEncryption(encryptor))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	secret := &models.Secret{
+		ID:            uuid.NewString(),
+		CustomName:    "private-name",
+		CreatedAt:     createdAt,
+		ExpiresAt:     &expiresAt,
+		EncryptedData: []byte("ciphertext"),
+	}
+	if err := store.Create(secret); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	// Nothing on disk reveals names or timestamps, including the migrated secret
+	files, err := os.ReadDir(testDir)
+	if err != nil {
+		t.Fatalf("Failed to read storage directory: %v", err)
+	}
+	for _, file := range files {
+		if strings.Contains(file.Name(), "private") || strings.Contains(file.Name(), "legacy") {
+			t.Errorf("File name %q reveals a custom name", file.Name())
+		}
+		data, err := os.ReadFile(filepath.Join(testDir, file.Name()))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.Name(), err)
+		}
+		for _, leak := range []string{"private-name", "legacy-name", "2025", "created_at", "expires_at"} {
+			if bytes.Contains(data, []byte(leak)) {
+				t.Errorf("File %s contains %q", file.Name(), leak)
+			}
+		}
+	}
+
+	retrieved, err := store.Get(secret.ID)
+	if err != nil || retrieved == nil {
+		t.Fatalf("Failed to get secret: %v", err)
+	}
+	if !retrieved.CreatedAt.Equal(createdAt) || retrieved.ExpiresAt == nil || !retrieved.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Decrypted secret has the wrong timestamps: %+v", retrieved)
+	}
+
+	for _, want := range []*models.Secret{secret, legacy} {
+		byName, err := store.GetByCustomName(want.CustomName)
+		if err != nil || byName == nil || byName.ID != want.ID {
+			t.Errorf("Expected %q to resolve to %s, got %+v (err %v)", want.CustomName, want.ID, byName, err)
+		}
+	}
+	if taken, err := store.IsCustomNameTaken("private-name"); err != nil || !taken {
+		t.Errorf("Expected private-name to be taken, got %v (err %v)", taken, err)
+	}
+	if err := store.Create(&models.Secret{ID: uuid.NewString(), CustomName: "private-name", CreatedAt: createdAt}); err == nil {
+		t.Error("Expected an error reusing a taken custom name")
+	}
+	if store.Count() != 2 {
+		t.Errorf("Expected count 2, got %d", store.Count())
+	}
+
+	// Deleting a secret frees its name
+	if err := store.Delete(secret.ID); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+	if taken, err := store.IsCustomNameTaken("private-name"); err != nil || taken {
+		t.Errorf("Expected private-name to be free, got %v (err %v)", taken, err)
+	}
+	if _, err := os.Stat(store.nameIndexPath("private-name")); !os.IsNotExist(err) {
+		t.Errorf("Expected the name index entry to be removed, got %v", err)
+	}
+
+	// Encrypted records survive an export and import
+	var archive bytes.Buffer
+	if _, err := store.Export(&archive); err != nil {
+		t.Fatalf("Failed to export secrets: %v", err)
+	}
+	targetDir, cleanupTarget := setupTestDir(t)
+	defer cleanupTarget()
+	target, err := NewFileStore(targetDir, WithRecordEncryption(encryptor))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	if result, err := target.Import(&archive, ImportLimits{}); err != nil || result.Imported != 1 {
+		t.Fatalf("Expected 1 imported secret, got %+v (err %v)", result, err)
+	}
+	if restored, err := target.GetByCustomName("legacy-name"); err != nil || restored == nil || restored.ID != legacy.ID {
+		t.Errorf("Failed to get restored secret by name: %+v (err %v)", restored, err)
+	}
+}
+
+func TestSecretAgeHistograms(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store, err := NewFileStore(testDir, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	expiresAt := clock.Now().Add(time.Hour)
+	viewed := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.Now(), ExpiresAt: &expiresAt}
+	expiring := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.Now(), ExpiresAt: &expiresAt}
+	for _, secret := range []*models.Secret{viewed, expiring} {
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+	}
+
+	// A view ten minutes after creation
+	clock.Advance(10 * time.Minute)
+	if _, err := store.RecordView(viewed.ID); err != nil {
+		t.Fatalf("Failed to record view: %v", err)
+	}
+	ages := store.ViewAges()
+	if ages.Count != 1 || ages.Sum != 600 {
+		t.Errorf("Expected one view at 600s, got count %d sum %v", ages.Count, ages.Sum)
+	}
+	for _, bucket := range ages.Buckets {
+		want := int64(0)
+		if bucket.UpperBound >= 600 {
+			want = 1
+		}
+		if bucket.Count != want {
+			t.Errorf("Expected %d views at most %vs, got %d", want, bucket.UpperBound, bucket.Count)
+		}
+	}
+
+	// Cleanup records the lifetime of each expired secret, not when it ran
+	clock.Advance(2 * time.Hour)
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+	if ages := store.ExpiryAges(); ages.Count != 2 || ages.Sum != 7200 {
+		t.Errorf("Expected two expiries at 3600s, got count %d sum %v", ages.Count, ages.Sum)
+	}
+}
+
+func TestReconcileNames(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store, err := NewFileStore(testDir, WithRecordEncryption(encryption.NewEncryptor("test-server-key")))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	live := &models.Secret{ID: uuid.NewString(), CustomName: "live", CreatedAt: time.Now(), EncryptedData: []byte("data")}
+	gone := &models.Secret{ID: uuid.NewString(), CustomName: "gone", CreatedAt: time.Now(), EncryptedData: []byte("data")}
+	for _, secret := range []*models.Secret{live, gone} {
+		if err := store.Create(secret); err != nil {
+			t.Fatalf("Failed to create secret: %v", err)
+		}
+	}
+
+	// A crash can leave index entries without their secret and reservations
+	// that are never released
+	if err := os.Remove(filepath.Join(testDir, gone.ID+secretFileExt)); err != nil {
+		t.Fatalf("Failed to remove secret file: %v", err)
+	}
+	if err := os.WriteFile(store.nameIndexPath("ghost"), []byte(uuid.NewString()), 0600); err != nil {
+		t.Fatalf("Failed to write index entry: %v", err)
+	}
+	stale := filepath.Join(testDir, store.nameKey("stuck")+reservationExt)
+	fresh := filepath.Join(testDir, store.nameKey("busy")+reservationExt)
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatalf("Failed to write reservation: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * staleReservationAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Failed to age reservation: %v", err)
+	}
+
+	stuck := &models.Secret{ID: uuid.NewString(), CustomName: "stuck", CreatedAt: time.Now(), EncryptedData: []byte("data")}
+	if err := store.Create(stuck); err == nil {
+		t.Fatal("Expected the stale reservation to block the name")
+	}
+
+	removed, err := store.ReconcileNames()
+	if err != nil {
+		t.Fatalf("Failed to reconcile names: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Expected 3 orphaned entries to be removed, got %d", removed)
+	}
+
+	for _, path := range []string{store.nameIndexPath("gone"), store.nameIndexPath("ghost"), stale} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", filepath.Base(path))
+		}
+	}
+	for _, path := range []string{store.nameIndexPath("live"), fresh} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", filepath.Base(path), err)
+		}
+	}
+
+	// The names can be used again, and the live one still resolves
+	if err := store.Create(stuck); err != nil {
+		t.Errorf("Expected the reclaimed name to be usable: %v", err)
+	}
+	if secret, err := store.GetByCustomName("live"); err != nil || secret == nil || secret.ID != live.ID {
+		t.Errorf("Expected the live name to resolve to its secret, got %v, %v", secret, err)
+	}
+
+	// Nothing is left to remove on a second pass
+	if removed, err := store.ReconcileNames(); err != nil || removed != 0 {
+		t.Errorf("Expected nothing to reconcile, got %d, %v", removed, err)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store, err := NewFileStore(testDir, WithMaxAge(time.Hour), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	// Written directly, as if it had been stored before the limit existed
+	farFuture := clock.now.Add(30 * 24 * time.Hour)
+	longLived := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.now, ExpiresAt: &farFuture, EncryptedData: []byte("data")}
+	burn := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.now, IsBurnAfterReading: true, EncryptedData: []byte("data")}
+	for _, secret := range []*models.Secret{longLived, burn} {
+		if err := store.Store(secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+	}
+
+	clock.Advance(30 * time.Minute)
+	young := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.now, IsBurnAfterReading: true, EncryptedData: []byte("data")}
+	if err := store.Store(young); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	// At exactly the maximum age nothing is removed yet
+	clock.Advance(30 * time.Minute)
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+	if count := store.Count(); count != 3 {
+		t.Errorf("Expected 3 secrets before the maximum age, got %d", count)
+	}
+
+	clock.Advance(time.Second)
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+	for _, secret := range []*models.Secret{longLived, burn} {
+		if got, err := store.Get(secret.ID); err != nil || got != nil {
+			t.Errorf("Expected secret older than the maximum age to be cleaned, got %v, %v", got, err)
+		}
+	}
+	if got, err := store.Get(young.ID); err != nil || got == nil {
+		t.Errorf("Expected younger secret to be kept, got %v, %v", got, err)
+	}
+
+	if stats := store.GetCleanupStats(); stats.SecretsCleaned != 2 {
+		t.Errorf("Expected 2 secrets cleaned, got %d", stats.SecretsCleaned)
+	}
+	if ages := store.ExpiryAges(); ages.Count != 2 {
+		t.Errorf("Expected 2 expiry ages recorded, got %d", ages.Count)
+	}
+}
+
+func TestExpiredRetention(t *testing.T) {
+	testDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store, err := NewFileStore(testDir, WithExpiredRetention(time.Hour), WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	expiresAt := clock.now.Add(10 * time.Minute)
+	secret := &models.Secret{ID: uuid.NewString(), CreatedAt: clock.now, ExpiresAt: &expiresAt, EncryptedData: []byte("data")}
+	if err := store.Store(secret); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	// Expired, but kept through the whole retention window
+	for _, offset := range []time.Duration{time.Minute, time.Hour} {
+		clock.now = expiresAt.Add(offset)
+		if !store.RetainsExpired(secret) {
+			t.Errorf("Expected secret to be retained %s after expiry", offset)
+		}
+		if err := store.CleanExpired(); err != nil {
+			t.Fatalf("Failed to clean expired secrets: %v", err)
+		}
+		if got, err := store.Get(secret.ID); err != nil || got == nil {
+			t.Fatalf("Expected secret to survive cleanup %s after expiry, got %v, %v", offset, got, err)
+		}
+	}
+
+	clock.Advance(time.Second)
+	if store.RetainsExpired(secret) {
+		t.Error("Expected retention to end after the window")
+	}
+	if err := store.CleanExpired(); err != nil {
+		t.Fatalf("Failed to clean expired secrets: %v", err)
+	}
+	if got, err := store.Get(secret.ID); err != nil || got != nil {
+		t.Errorf("Expected secret to be cleaned after the retention window, got %v, %v", got, err)
+	}
+
+	// Without a window expired secrets are never retained
+	plain, err := NewFileStore(testDir, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	if plain.RetainsExpired(secret) {
+		t.Error("Expected no retention without a window")
+	}
+}
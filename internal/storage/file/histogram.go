@@ -0,0 +1,78 @@
+package file
+
+import (
+	"sync"
+	"time"
+)
+
+// ageBuckets are the upper bounds, in seconds, of the secret age histograms:
+// one minute, five minutes, fifteen minutes, one hour, six hours, one day,
+// three days and one week
+var ageBuckets = []float64{60, 300, 900, 3600, 21600, 86400, 259200, 604800}
+
+// Histogram counts observed durations in fixed buckets
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []int64 // Per bucket, with a final bucket for values above every bound
+	sum    float64
+	count  int64
+}
+
+// HistogramBucket is a cumulative bucket: Count observations were at most
+// UpperBound seconds
+type HistogramBucket struct {
+	UpperBound float64
+	Count      int64
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram
+type HistogramSnapshot struct {
+	Buckets []HistogramBucket
+	Sum     float64 // Total of all observations in seconds
+	Count   int64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe records a duration. Negative durations, from clock skew, count as zero.
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := 0
+	for i < len(h.bounds) && seconds > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// Snapshot returns the current bucket counts
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := HistogramSnapshot{
+		Buckets: make([]HistogramBucket, len(h.bounds)),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+	var cumulative int64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		snapshot.Buckets[i] = HistogramBucket{UpperBound: bound, Count: cumulative}
+	}
+	return snapshot
+}
@@ -0,0 +1,289 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"secrets-share/internal/encryption"
+	"secrets-share/internal/logger"
+	"secrets-share/internal/models"
+)
+
+// nameIndexExt marks the custom name index files kept with record encryption
+const nameIndexExt = ".name"
+
+// staleReservationAge is how old a custom name reservation must be before
+// ReconcileNames treats it as abandoned
+const staleReservationAge = time.Minute
+
+// WithRecordEncryption encrypts each secret file as a whole, so metadata such
+// as custom names and timestamps can't be read from the storage directory.
+// Custom names are then found through an index keyed by a hash of the name,
+// and secrets still stored as plaintext are encrypted when the store opens.
+func WithRecordEncryption(encryptor *encryption.Encryptor) Option {
+	return func(s *FileStore) {
+		s.records = encryptor
+	}
+}
+
+// encodeSecret serializes a secret for storage, encrypting it when record
+// encryption is on
+func (s *FileStore) encodeSecret(secret *models.Secret) ([]byte, error) {
+	data, err := json.Marshal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal secret: %w", err)
+	}
+	if s.records == nil {
+		return data, nil
+	}
+
+	encrypted, err := s.records.Encrypt(data, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	return encrypted, nil
+}
+
+// decodeSecret parses a stored secret. Plaintext JSON is always accepted, so
+// secrets written before record encryption was turned on stay readable.
+func (s *FileStore) decodeSecret(data []byte) (*models.Secret, error) {
+	if s.records != nil && !isPlaintextRecord(data) {
+		decrypted, err := s.records.Decrypt(data, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+		}
+		data = decrypted
+	}
+
+	var secret models.Secret
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret: %w", err)
+	}
+	return &secret, nil
+}
+
+// isPlaintextRecord reports whether stored data is an unencrypted JSON secret.
+// Encrypted records start with the encryption header, which is never '{'.
+func isPlaintextRecord(data []byte) bool {
+	return len(data) > 0 && data[0] == '{'
+}
+
+// nameKey returns a filename-safe key for a custom name. With record
+// encryption it is keyed, so names can't be recovered by hashing guesses.
+func (s *FileStore) nameKey(name string) string {
+	if s.records != nil {
+		return s.records.KeyedHash([]byte(name))
+	}
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// nameIndexPath returns the index file for a custom name
+func (s *FileStore) nameIndexPath(name string) string {
+	return filepath.Join(s.basePath, s.nameKey(name)+nameIndexExt)
+}
+
+// indexName records which secret holds its custom name; callers must hold the
+// write lock
+func (s *FileStore) indexName(secret *models.Secret) error {
+	if s.records == nil || secret.CustomName == "" {
+		return nil
+	}
+
+	indexPath := s.nameIndexPath(secret.CustomName)
+	tmpPath := indexPath + tempFileExt
+	if err := s.writeFile(tmpPath, []byte(secret.ID), 0600); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write name index: %w", classifyWriteError(err))
+	}
+	if err := os.Rename(tmpPath, indexPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename name index: %w", err)
+	}
+	return nil
+}
+
+// unindexName removes a custom name's index entry if it still points at id;
+// callers must hold the write lock
+func (s *FileStore) unindexName(name, id string) error {
+	if s.records == nil || name == "" {
+		return nil
+	}
+
+	indexPath := s.nameIndexPath(name)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read name index: %w", err)
+	}
+	if string(data) != id {
+		return nil
+	}
+	if err := os.Remove(indexPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete name index: %w", err)
+	}
+	return nil
+}
+
+// findIndexedName looks up a custom name through the index, returning nil if
+// no live secret holds it; callers must hold the lock
+func (s *FileStore) findIndexedName(name string) (*models.Secret, error) {
+	data, err := os.ReadFile(s.nameIndexPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read name index: %w", err)
+	}
+
+	id := string(data)
+	if id == "" || filepath.Base(id) != id {
+		return nil, nil
+	}
+	secret, err := s.readSecret(id)
+	if err != nil || secret == nil || secret.CustomName != name {
+		// Entries can outlive their secret if removing them failed
+		return nil, err
+	}
+	return secret, nil
+}
+
+// ReconcileNames removes custom name index entries whose secret no longer
+// exists or no longer holds the name, and reservations older than
+// staleReservationAge. Either can be left behind by a crash between claiming
+// a name and writing the secret, and would otherwise block the name. It
+// returns the number of entries removed.
+func (s *FileStore) ReconcileNames() (int, error) {
+	files, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, file := range files {
+		path := filepath.Join(s.basePath, file.Name())
+		switch {
+		case strings.HasSuffix(file.Name(), reservationExt):
+			// Live reservations are only held while a secret is written
+			info, err := file.Info()
+			if err != nil || time.Since(info.ModTime()) < staleReservationAge {
+				continue
+			}
+		case strings.HasSuffix(file.Name(), nameIndexExt):
+			orphaned, err := s.orphanedIndexEntry(path)
+			if err != nil {
+				return removed, err
+			}
+			if !orphaned {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, fmt.Errorf("failed to remove orphaned name entry: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// orphanedIndexEntry reports whether the name index file at path points at a
+// secret that doesn't exist or holds a different name; callers must hold the
+// lock
+func (s *FileStore) orphanedIndexEntry(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read name index: %w", err)
+	}
+
+	id := string(data)
+	if id == "" || filepath.Base(id) != id {
+		return true, nil
+	}
+	secret, err := s.readSecret(id)
+	if err != nil {
+		// Unreadable secrets are reported elsewhere, keep their names reserved
+		return false, nil
+	}
+	if secret == nil || secret.CustomName == "" {
+		return true, nil
+	}
+	return s.nameKey(secret.CustomName)+nameIndexExt != filepath.Base(path), nil
+}
+
+// migrateRecords encrypts secrets still stored as plaintext and indexes
+// custom names missing from the index. Secrets that can't be read are logged
+// and left in place.
+func (s *FileStore) migrateRecords() error {
+	files, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	migrated := 0
+	for _, file := range files {
+		if !isSecretFile(file) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.basePath, file.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read secret file: %w", err)
+		}
+		secret, err := s.decodeSecret(data)
+		if err != nil {
+			logger.Warn("Failed to read secret for record encryption", map[string]interface{}{
+				"file":  file.Name(),
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		if isPlaintextRecord(data) {
+			if err := s.writeSecret(secret); err != nil {
+				return err
+			}
+			migrated++
+			continue
+		}
+		if indexed, err := s.findIndexedName(secret.CustomName); err != nil {
+			return err
+		} else if secret.CustomName != "" && indexed == nil {
+			if err := s.indexName(secret); err != nil {
+				return err
+			}
+		}
+	}
+
+	if migrated > 0 {
+		logger.Info("Encrypted plaintext secret records", map[string]interface{}{
+			"count": migrated,
+		})
+	}
+	return nil
+}
@@ -3,36 +3,97 @@ package redis
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 const (
-	rateLimitPrefix = "rate_limit:"
+	rateLimitPrefix    = "rate_limit:"
+	failedLookupPrefix = "failed_lookup:"
+	creatorPrefix      = "creator:"
+
+	// startupPingTimeout bounds the connection check in NewRedisStore, so an
+	// unreachable or stalled Redis can't hang startup
+	startupPingTimeout = 5 * time.Second
 )
 
 type RedisStore struct {
-	client *redis.Client
+	client  *redis.Client
+	healthy atomic.Bool // Result of the last health check
+}
+
+// Option configures the Redis client created by NewRedisStore
+type Option func(*redis.Options)
+
+// WithPoolSize sets the maximum number of connections. Zero keeps the go-redis
+// default of 10 per CPU.
+func WithPoolSize(size int) Option {
+	return func(o *redis.Options) {
+		if size > 0 {
+			o.PoolSize = size
+		}
+	}
 }
 
-func NewRedisStore(host string, port int, password string, username string, db int) (*RedisStore, error) {
-	client := redis.NewClient(&redis.Options{
+// WithTimeouts sets the dial, read and write timeouts. Zero values keep the
+// go-redis defaults.
+func WithTimeouts(dial, read, write time.Duration) Option {
+	return func(o *redis.Options) {
+		if dial > 0 {
+			o.DialTimeout = dial
+		}
+		if read > 0 {
+			o.ReadTimeout = read
+		}
+		if write > 0 {
+			o.WriteTimeout = write
+		}
+	}
+}
+
+func NewRedisStore(host string, port int, password string, username string, db int, opts ...Option) (*RedisStore, error) {
+	options := &redis.Options{
 		Addr:     fmt.Sprintf("%s:%d", host, port),
 		Password: password,
 		Username: username,
 		DB:       db,
-	})
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	client := redis.NewClient(options)
 
 	// Verify Redis connection is working by sending a PING command
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), startupPingTimeout)
+	defer cancel()
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisStore{
+	store := &RedisStore{
 		client: client,
-	}, nil
+	}
+	store.healthy.Store(true)
+	return store, nil
+}
+
+// CheckHealth pings Redis and records the result for Healthy
+func (s *RedisStore) CheckHealth(ctx context.Context) error {
+	err := s.client.Ping(ctx).Err()
+	s.healthy.Store(err == nil)
+	if err != nil {
+		return fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return nil
+}
+
+// Healthy reports whether the last health check (or the connection check in
+// NewRedisStore) reached Redis
+func (s *RedisStore) Healthy() bool {
+	return s.healthy.Load()
 }
 
 func (s *RedisStore) CheckRateLimit(ctx context.Context, ip string, route string, requestsPerHour, requestsPerMinute int) (bool, error) {
@@ -79,6 +140,87 @@ func (s *RedisStore) CheckRateLimit(ctx context.Context, ip string, route string
 	return true, nil
 }
 
+// FailedLookupsExceeded reports whether the client has reached either limit on
+// failed lookups of the given kind. A zero limit disables that window.
+func (s *RedisStore) FailedLookupsExceeded(ctx context.Context, ip string, kind string, perHour, perMinute int) (bool, error) {
+	limits := []struct {
+		window string
+		limit  int
+	}{{"hour", perHour}, {"minute", perMinute}}
+	for _, l := range limits {
+		if l.limit <= 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s%s:%s:%s", failedLookupPrefix, ip, kind, l.window)
+		count, err := s.client.Get(ctx, key).Int64()
+		if err != nil && err != redis.Nil {
+			return false, fmt.Errorf("failed to get failed lookup count: %w", err)
+		}
+		if count >= int64(l.limit) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RecordFailedLookup counts a failed lookup of the given kind for the client
+func (s *RedisStore) RecordFailedLookup(ctx context.Context, ip string, kind string) error {
+	windows := []struct {
+		window string
+		ttl    time.Duration
+	}{{"hour", time.Hour}, {"minute", time.Minute}}
+	for _, w := range windows {
+		key := fmt.Sprintf("%s%s:%s:%s", failedLookupPrefix, ip, kind, w.window)
+		count, err := s.client.Incr(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to increment failed lookup count: %w", err)
+		}
+		if count == 1 {
+			if err := s.client.Expire(ctx, key, w.ttl).Err(); err != nil {
+				return fmt.Errorf("failed to set failed lookup expiry: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// AddCreatorSecret associates a secret ID with a creator session, keeping the
+// association for at least ttl
+func (s *RedisStore) AddCreatorSecret(ctx context.Context, session string, id string, ttl time.Duration) error {
+	key := creatorPrefix + session
+	pipe := s.client.TxPipeline()
+	pipe.SAdd(ctx, key, id)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record creator secret: %w", err)
+	}
+	return nil
+}
+
+// CreatorSecrets returns the secret IDs associated with a creator session
+func (s *RedisStore) CreatorSecrets(ctx context.Context, session string) ([]string, error) {
+	ids, err := s.client.SMembers(ctx, creatorPrefix+session).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get creator secrets: %w", err)
+	}
+	return ids, nil
+}
+
+// RemoveCreatorSecrets drops secret IDs that no longer exist from a creator session
+func (s *RedisStore) RemoveCreatorSecrets(ctx context.Context, session string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+	if err := s.client.SRem(ctx, creatorPrefix+session, members...).Err(); err != nil {
+		return fmt.Errorf("failed to remove creator secrets: %w", err)
+	}
+	return nil
+}
+
 func (s *RedisStore) Close() error {
 	return s.client.Close()
 }
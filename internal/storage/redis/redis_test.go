@@ -2,6 +2,8 @@ package redis
 
 import (
 	"context"
+	"net"
+	"sort"
 	"strconv"
 	"testing"
 	"time"
@@ -119,3 +121,215 @@ func TestRateLimit(t *testing.T) {
 		}
 	})
 }
+
+func TestCreatorSecrets(t *testing.T) {
+	store, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := context.Background()
+
+	if err := store.AddCreatorSecret(ctx, "session", "id-1", time.Hour); err != nil {
+		t.Fatalf("AddCreatorSecret failed: %v", err)
+	}
+	if err := store.AddCreatorSecret(ctx, "session", "id-2", time.Hour); err != nil {
+		t.Fatalf("AddCreatorSecret failed: %v", err)
+	}
+
+	ids, err := store.CreatorSecrets(ctx, "session")
+	if err != nil {
+		t.Fatalf("CreatorSecrets failed: %v", err)
+	}
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "id-1" || ids[1] != "id-2" {
+		t.Errorf("Expected both IDs, got %v", ids)
+	}
+	if ttl := mr.TTL(creatorPrefix + "session"); ttl != time.Hour {
+		t.Errorf("Expected the session to expire after an hour, got %v", ttl)
+	}
+
+	if err := store.RemoveCreatorSecrets(ctx, "session", "id-1"); err != nil {
+		t.Fatalf("RemoveCreatorSecrets failed: %v", err)
+	}
+	ids, _ = store.CreatorSecrets(ctx, "session")
+	if len(ids) != 1 || ids[0] != "id-2" {
+		t.Errorf("Expected only id-2 to remain, got %v", ids)
+	}
+
+	ids, err = store.CreatorSecrets(ctx, "other-session")
+	if err != nil || len(ids) != 0 {
+		t.Errorf("Expected no IDs for an unknown session, got %v (%v)", ids, err)
+	}
+}
+
+func TestRedisOptions(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	port, _ := strconv.Atoi(mr.Port())
+	store, err := NewRedisStore(mr.Host(), port, "", "", 0,
+		WithPoolSize(7),
+		WithTimeouts(time.Second, 2*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer store.Close()
+
+	options := store.client.Options()
+	if options.PoolSize != 7 {
+		t.Errorf("Expected pool size 7, got %d", options.PoolSize)
+	}
+	if options.DialTimeout != time.Second || options.ReadTimeout != 2*time.Second || options.WriteTimeout != 3*time.Second {
+		t.Errorf("Unexpected timeouts: dial %v, read %v, write %v", options.DialTimeout, options.ReadTimeout, options.WriteTimeout)
+	}
+
+	// Zero values keep the client defaults
+	defaults, err := NewRedisStore(mr.Host(), port, "", "", 0, WithPoolSize(0), WithTimeouts(0, 0, 0))
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer defaults.Close()
+	if defaults.client.Options().PoolSize <= 0 || defaults.client.Options().ReadTimeout != 3*time.Second {
+		t.Error("Zero options should keep the client defaults")
+	}
+}
+
+func TestRedisStartupFailsFast(t *testing.T) {
+	t.Run("Unreachable", func(t *testing.T) {
+		// Reserve a port and close it so nothing is listening
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+
+		start := time.Now()
+		if _, err := NewRedisStore("127.0.0.1", port, "", "", 0, WithTimeouts(200*time.Millisecond, 0, 0)); err == nil {
+			t.Fatal("Expected an error connecting to a closed port")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Startup took %v with Redis unreachable", elapsed)
+		}
+	})
+
+	t.Run("Stalled", func(t *testing.T) {
+		// Accepts connections but never answers
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer listener.Close()
+		go func() {
+			var conns []net.Conn
+			defer func() {
+				for _, conn := range conns {
+					conn.Close()
+				}
+			}()
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conns = append(conns, conn)
+			}
+		}()
+
+		port := listener.Addr().(*net.TCPAddr).Port
+		start := time.Now()
+		if _, err := NewRedisStore("127.0.0.1", port, "", "", 0, WithTimeouts(0, 200*time.Millisecond, 200*time.Millisecond)); err == nil {
+			t.Fatal("Expected an error from a Redis that never answers")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Startup took %v with Redis stalled", elapsed)
+		}
+	})
+}
+
+func TestHealthCheck(t *testing.T) {
+	store, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := context.Background()
+	if !store.Healthy() {
+		t.Fatal("A newly connected store should be healthy")
+	}
+
+	// Redis goes down
+	mr.SetError("server unavailable")
+	if err := store.CheckHealth(ctx); err == nil {
+		t.Error("Expected a health check error while Redis is down")
+	}
+	if store.Healthy() {
+		t.Error("Expected the store to be unhealthy after a failed check")
+	}
+
+	// And comes back
+	mr.SetError("")
+	if err := store.CheckHealth(ctx); err != nil {
+		t.Errorf("Unexpected health check error: %v", err)
+	}
+	if !store.Healthy() {
+		t.Error("Expected the store to be healthy after a successful check")
+	}
+
+	// A restarted server on the same address is picked up again
+	mr.Close()
+	if err := store.CheckHealth(ctx); err == nil || store.Healthy() {
+		t.Error("Expected the store to be unhealthy while Redis is stopped")
+	}
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("Failed to restart miniredis: %v", err)
+	}
+	if err := store.CheckHealth(ctx); err != nil || !store.Healthy() {
+		t.Errorf("Expected the store to recover after a restart, got %v", err)
+	}
+}
+
+func TestFailedLookups(t *testing.T) {
+	store, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := context.Background()
+	ip := "127.0.0.1"
+
+	exceeded := func(kind string, perHour, perMinute int) bool {
+		t.Helper()
+		blocked, err := store.FailedLookupsExceeded(ctx, ip, kind, perHour, perMinute)
+		if err != nil {
+			t.Fatalf("Failed to check failed lookups: %v", err)
+		}
+		return blocked
+	}
+
+	for i := 0; i < 3; i++ {
+		if exceeded("name", 10, 3) {
+			t.Fatalf("Lookup %d should not be blocked", i+1)
+		}
+		if err := store.RecordFailedLookup(ctx, ip, "name"); err != nil {
+			t.Fatalf("Failed to record failed lookup: %v", err)
+		}
+	}
+	if !exceeded("name", 10, 3) {
+		t.Error("Expected lookups to be blocked after 3 failures")
+	}
+	if exceeded("other", 10, 3) {
+		t.Error("Failures of one kind should not count towards another")
+	}
+	if exceeded("name", 10, 0) {
+		t.Error("A zero minute limit should disable that window")
+	}
+
+	// Failures count towards the hour after the minute window resets
+	mr.FastForward(time.Minute)
+	if exceeded("name", 10, 3) {
+		t.Error("Expected the minute window to have reset")
+	}
+	if !exceeded("name", 3, 3) {
+		t.Error("Expected failures to still count towards the hour limit")
+	}
+}